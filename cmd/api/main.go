@@ -1,33 +1,90 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os/signal"
 	"strconv"
+	"syscall"
 
 	"github.com/krishnaGauss/SoCode/internal/api"
+	"github.com/krishnaGauss/SoCode/internal/archive"
 	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/retention"
 	"github.com/krishnaGauss/SoCode/internal/storage"
 )
 
 func main() {
-    cfg := config.Load()
-
-    // Initialize storage
-    postgres, err := storage.NewPostgresStorage(&cfg.Database)
-    if err != nil {
-        log.Fatalf("Failed to initialize PostgreSQL: %v", err)
-    }
-    defer postgres.Close()
-
-    // Create API server
-    server := api.NewServer(postgres)
-    handler := server.SetupRoutes()
-
-    addr := cfg.Server.Host + ":" + strconv.Itoa(cfg.Server.Port)
-    log.Printf("API server listening on %s", addr)
-    
-    if err := http.ListenAndServe(addr, handler); err != nil {
-        log.Fatalf("Failed to start server: %v", err)
-    }
-}
\ No newline at end of file
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Initialize storage
+	store, err := storage.NewLogStore(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s storage: %v", cfg.Database.Driver, err)
+	}
+
+	// Read archived logs alongside the database
+	var logArchive *archive.Archive
+	if cfg.Archive.Dir != "" {
+		logArchive, err = archive.Open(cfg.Archive.Dir)
+		if err != nil {
+			log.Fatalf("Failed to open archive: %v", err)
+		}
+		if cfg.Archive.Query {
+			store = archive.NewTieredStore(store, logArchive, cfg.Archive.QueryMaxSegments)
+		}
+	}
+
+	policies, err := retention.Load(cfg.Retention.File)
+	if err != nil {
+		log.Fatalf("Failed to load retention policies: %v", err)
+	}
+
+	// Create API server
+	server := api.NewServer(store, policies, &cfg.Query)
+	if logArchive != nil {
+		server.KeepUnarchived(logArchive)
+	}
+	handler := server.SetupRoutes()
+
+	addr := cfg.Server.Host + ":" + strconv.Itoa(cfg.Server.Port)
+	httpServer := &http.Server{Addr: addr, Handler: handler}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	log.Printf("API server listening on %s", addr)
+
+	select {
+	case err := <-serveErr:
+		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Failed to shut down HTTP server: %v", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to close websockets: %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close storage: %v", err)
+	}
+
+	log.Println("Shutdown complete")
+}
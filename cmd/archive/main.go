@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/archive"
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/server"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+const usage = `usage: archive <command>
+
+commands:
+  run                 archive every closed time range now
+  list                list the segments of the archive
+  verify              check every segment against its checksum
+  restore <from> <to> store the archived logs with from <= timestamp < to
+                      back into the database (RFC 3339 times)`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Archive.Dir == "" {
+		log.Fatal("ARCHIVE_DIR is not set")
+	}
+
+	a, err := archive.Open(cfg.Archive.Dir)
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "run":
+		store := openStore(cfg)
+		defer store.Close()
+
+		segments, err := server.NewArchiver(store, a, &cfg.Archive).Archive(time.Now())
+		for _, seg := range segments {
+			fmt.Printf("archived %s (%d logs)\n", seg.File, seg.Rows)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(segments) == 0 {
+			fmt.Println("nothing to archive")
+		}
+
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tFROM\tTO\tROWS\tBYTES\tSERVICES")
+		for _, seg := range a.Segments() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", seg.File,
+				seg.From.Format(time.RFC3339), seg.To.Format(time.RFC3339),
+				seg.Rows, seg.Bytes, strings.Join(seg.Services, ","))
+		}
+		w.Flush()
+
+	case "verify":
+		failed := 0
+		for _, seg := range a.Segments() {
+			if err := a.Verify(seg); err != nil {
+				fmt.Printf("FAIL %v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("ok   %s\n", seg.File)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+
+	case "restore":
+		if len(os.Args) != 4 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		from, err := time.Parse(time.RFC3339, os.Args[2])
+		if err != nil {
+			log.Fatalf("Invalid start time: %v", err)
+		}
+		to, err := time.Parse(time.RFC3339, os.Args[3])
+		if err != nil {
+			log.Fatalf("Invalid end time: %v", err)
+		}
+		if !from.Before(to) {
+			log.Fatal("The start time must be before the end time")
+		}
+
+		store := openStore(cfg)
+		defer store.Close()
+
+		restored, err := archive.Restore(store, a, from, to, cfg.Archive.BatchSize)
+		fmt.Printf("restored %d logs\n", restored)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func openStore(cfg *config.Config) storage.LogStore {
+	store, err := storage.NewLogStore(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s storage: %v", cfg.Database.Driver, err)
+	}
+	return store
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/migrate"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up        apply every pending migration
+  down [-allow-data-loss] [n]
+            revert the last n migrations (default 1); migrations whose
+            down file drops tables or columns need -allow-data-loss
+  status    list migrations and whether they are applied`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Database.Driver != "" && cfg.Database.Driver != "postgres" {
+		log.Fatalf("Migrations only apply to postgres, DB_DRIVER is %s", cfg.Database.Driver)
+	}
+
+	db, err := storage.OpenPostgres(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := storage.NewMigrator(db.DB)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("applied %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+
+	case "down":
+		flags := flag.NewFlagSet("down", flag.ExitOnError)
+		allowDataLoss := flags.Bool("allow-data-loss", false, "revert migrations that drop tables or columns")
+		flags.Parse(os.Args[2:])
+
+		steps := 1
+		if flags.NArg() > 0 {
+			steps, err = strconv.Atoi(flags.Arg(0))
+			if err != nil || steps <= 0 {
+				log.Fatalf("Invalid number of migrations %q", flags.Arg(0))
+			}
+		}
+		reverted, err := migrator.Down(steps, *allowDataLoss)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d %s\n", m.Version, m.Name)
+		}
+		if errors.Is(err, migrate.ErrDataLoss) {
+			log.Fatalf("%v, rerun with -allow-data-loss to revert it anyway", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			if s.Missing {
+				state = "unknown"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
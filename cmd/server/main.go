@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
+	"os/signal"
 	"strconv"
+	"syscall"
 
+	"github.com/krishnaGauss/SoCode/internal/archive"
 	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/retention"
 	"github.com/krishnaGauss/SoCode/internal/server"
 	"github.com/krishnaGauss/SoCode/internal/storage"
 	"github.com/krishnaGauss/SoCode/proto/SoCode/proto"
@@ -13,38 +18,165 @@ import (
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := storage.NewLogStore(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s storage: %v", cfg.Database.Driver, err)
+	}
+
+	queue, err := storage.NewQueue(&cfg.Queue, &cfg.Redis)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s queue: %v", cfg.Queue.Backend, err)
+	}
+
+	// Keep future partitions of the logs table created ahead of time
+	var partitions *storage.PartitionManager
+	if postgres, ok := store.(*storage.PostgresStorage); ok {
+		partitions = storage.NewPartitionManager(postgres, &cfg.Database)
+		go partitions.Start()
+	}
 
-	postgres, err:=storage.NewPostgresStorage(&cfg.Database)
+	policies, err := retention.Load(cfg.Retention.File)
 	if err != nil {
-        log.Fatalf("Failed to initialize PostgreSQL: %v", err)
-    }
-    defer postgres.Close()
+		log.Fatalf("Failed to load retention policies: %v", err)
+	}
 
-	 redis, err := storage.NewRedisQueue(&cfg.Redis)
-    if err != nil {
-        log.Fatalf("Failed to initialize Redis: %v", err)
-    }
-    defer redis.Close()
+	// Copy closed time ranges to the archive before retention deletes them
+	var archiver *server.Archiver
+	var logArchive *archive.Archive
+	if cfg.Archive.Dir != "" {
+		logArchive, err = archive.Open(cfg.Archive.Dir)
+		if err != nil {
+			log.Fatalf("Failed to open archive: %v", err)
+		}
+		archiver = server.NewArchiver(store, logArchive, &cfg.Archive)
+		go archiver.Start()
+	}
+
+	var enforcer *server.RetentionEnforcer
+	if retentionStore, ok := store.(storage.RetentionStore); ok && len(policies) > 0 {
+		enforcer = server.NewRetentionEnforcer(retentionStore, policies, &cfg.Retention)
+		if archiver != nil {
+			enforcer.KeepUnarchived(archiver)
+		}
+		go enforcer.Start()
+	} else if len(policies) > 0 {
+		log.Printf("Storage driver %s does not support retention, policies ignored", cfg.Database.Driver)
+	}
+
+	pipeline, err := server.LoadPipeline(cfg.Processor.PipelineFile)
+	if err != nil {
+		log.Fatalf("Failed to load pipeline: %v", err)
+	}
+
+	// Parse stack traces and mine message templates after the pipeline has
+	// shaped the messages, issues are fingerprinted from both
+	processors := server.Processors{pipeline}
+	if cfg.Processor.ParseStackTraces {
+		processors = append(processors, server.StackTraceParser{})
+	}
+	if patternStore, ok := store.(storage.PatternStore); ok && cfg.Patterns.Enabled {
+		miner, err := server.NewPatternMiner(patternStore, &cfg.Patterns)
+		if err != nil {
+			log.Fatalf("Failed to start pattern mining: %v", err)
+		}
+		processors = append(processors, miner)
+	}
+	var tracker *server.IssueTracker
+	if issueStore, ok := store.(storage.IssueStore); ok && cfg.Issues.Enabled {
+		tracker = server.NewIssueTracker(issueStore)
+		processors = append(processors, tracker)
+	}
 
 	// Start log processor
-    processor := server.NewLogProcessor(redis, postgres)
-    go processor.Start()
-    defer processor.Stop()
-
-    // Start gRPC server
-    lis, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.Server.GRPCPort))
-    if err != nil {
-        log.Fatalf("Failed to listen: %v", err)
-    }
-
-    grpcServer := grpc.NewServer()
-    logServer := server.NewLogServer(redis, postgres)
-    proto.RegisterLogServiceServer(grpcServer, logServer)
-
-    log.Printf("gRPC server listening on port %d", cfg.Server.GRPCPort)
-    
-    if err := grpcServer.Serve(lis); err != nil {
-        log.Fatalf("Failed to serve gRPC: %v", err)
-    }
-}
\ No newline at end of file
+	processor := server.NewLogProcessor(queue, store, processors, &cfg.Processor)
+	if tracker != nil {
+		processor.Observe(tracker)
+	}
+	go processor.Start()
+
+	// Start gRPC server
+	lis, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.Server.GRPCPort))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	queryStore := store
+	if logArchive != nil && cfg.Archive.Query {
+		queryStore = archive.NewTieredStore(store, logArchive, cfg.Archive.QueryMaxSegments)
+	}
+	logServer := server.NewLogServer(queue, queryStore, &cfg.Query)
+	proto.RegisterLogServiceServer(grpcServer, logServer)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(lis)
+	}()
+
+	log.Printf("gRPC server listening on port %d", cfg.Server.GRPCPort)
+
+	select {
+	case err := <-serveErr:
+		log.Fatalf("Failed to serve gRPC: %v", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for requests and %s for the queue", cfg.Server.ShutdownTimeout, cfg.Server.DrainTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Reject new logs first so nothing lands in the queue after it is drained.
+	logServer.StopAccepting()
+	gracefulStop(shutdownCtx, grpcServer)
+
+	// the drain has its own deadline, idle streams may have used up the first
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+	defer cancelDrain()
+	if err := processor.Shutdown(drainCtx); err != nil {
+		log.Printf("Failed to drain queue: %v", err)
+	}
+
+	if archiver != nil {
+		archiver.Stop()
+	}
+	if enforcer != nil {
+		enforcer.Stop()
+	}
+	if partitions != nil {
+		partitions.Stop()
+	}
+
+	if err := queue.Close(); err != nil {
+		log.Printf("Failed to close queue: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close storage: %v", err)
+	}
+
+	log.Println("Shutdown complete")
+}
+
+// gracefulStop waits for in-flight RPCs to finish, forcibly closing whatever
+// is still open when ctx expires.
+func gracefulStop(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Println("gRPC graceful stop timed out, closing open connections")
+		s.Stop()
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// aggregateLogs counts logs per time bucket and group, for charts and
+// dashboards.
+func (s *Server) aggregateLogs(w http.ResponseWriter, r *http.Request) {
+	var query models.AggregateQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := s.storage.AggregateLogs(query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	if buckets == nil {
+		buckets = []models.AggregateBucket{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"buckets": buckets,
+	})
+}
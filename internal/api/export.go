@@ -0,0 +1,121 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/krishnaGauss/SoCode/internal/export"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// Trailers of an export telling whether it finished and, if not, the cursor
+// to resume it from.
+const (
+	exportCompleteTrailer = "X-Export-Complete"
+	exportCursorTrailer   = "X-Export-Cursor"
+)
+
+// exportLogs streams every log matching the query string, oldest first
+// unless order=desc, in the format asked for and gzipped with gzip=true. The
+// download resumes from cursor, or from the log with the ID in resume_after.
+// When it runs out of time it ends early, with the cursor to resume from in
+// a trailer. Only the database is exported, never the archive.
+func (s *Server) exportLogs(w http.ResponseWriter, r *http.Request) {
+	query, err := parseLogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("order") == "" {
+		query.Order = models.OrderAsc
+	}
+	if id := r.URL.Query().Get("resume_after"); id != "" {
+		log, err := storage.GetLog(s.storage, id)
+		if err != nil {
+			writeQueryError(w, err)
+			return
+		}
+		query.Cursor = &models.Cursor{Timestamp: log.Timestamp, ID: log.ID}
+	}
+
+	format := r.URL.Query().Get("format")
+	columns, err := export.ParseColumns(r.URL.Query().Get("columns"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	compress := false
+	if v := r.URL.Query().Get("gzip"); v != "" {
+		if compress, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "gzip must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	// errors can only be reported before anything is written
+	if err := export.Validate(query); err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	if _, err := export.NewWriter(format, io.Discard, columns); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if s.exportTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.exportTimeout)
+		defer cancel()
+	}
+
+	filename := "logs." + export.Extension(format)
+	var out io.Writer = w
+	if compress {
+		filename += ".gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	} else {
+		w.Header().Set("Content-Type", export.ContentType(format))
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Trailer", exportCompleteTrailer+", "+exportCursorTrailer)
+
+	writer, err := export.NewWriter(format, out, columns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	flush := func() error {
+		if gz, ok := out.(*gzip.Writer); ok {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	// exports read the database alone: paging through the archive reads a
+	// whole segment for every batch, so archived ranges are restored first
+	cursor, err := export.Run(ctx, storage.Unwrap(s.storage), query, writer, flush)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil && ctx.Err() == nil {
+		slog.Warn("export failed", "error", err)
+	}
+
+	w.Header().Set(exportCompleteTrailer, strconv.FormatBool(err == nil))
+	if cursor != nil {
+		w.Header().Set(exportCursorTrailer, cursor.String())
+	}
+}
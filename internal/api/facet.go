@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// listFacets lists the values of each field asked for in field, with their
+// counts among the logs matching the rest of the query string.
+func (s *Server) listFacets(w http.ResponseWriter, r *http.Request) {
+	query, err := parseLogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := storage.DefaultFacetLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	// limit is the number of values per facet here, not of logs
+	query.Limit = 0
+
+	fields := r.URL.Query()["field"]
+	if len(fields) == 0 {
+		fields = storage.FacetFields
+	}
+
+	facets := make([]models.Facet, 0, len(fields))
+	for _, field := range fields {
+		facet, err := s.storage.FacetLogs(models.FacetQuery{
+			Query:  query,
+			Field:  field,
+			Prefix: r.URL.Query().Get("prefix"),
+			Limit:  min(limit, s.facetLimit),
+		})
+		if err != nil {
+			writeQueryError(w, err)
+			return
+		}
+		facets = append(facets, facet)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"facets": facets,
+	})
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// issueStore returns the store of issues, replying with an error when the
+// backend has none.
+func (s *Server) issueStore(w http.ResponseWriter) (storage.IssueStore, bool) {
+	store, ok := storage.Unwrap(s.storage).(storage.IssueStore)
+	if !ok {
+		http.Error(w, "storage backend does not support issues", http.StatusNotImplemented)
+	}
+	return store, ok
+}
+
+// listIssues lists issues, most recently seen first, filtered by the
+// repeatable status and service parameters and by regressed=true.
+func (s *Server) listIssues(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.issueStore(w)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	var query models.IssueQuery
+	for _, value := range params["status"] {
+		status, err := models.ParseIssueStatus(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Status = append(query.Status, status)
+	}
+	query.Service = params["service"]
+
+	var err error
+	if regressed := params.Get("regressed"); regressed != "" {
+		if query.Regressed, err = strconv.ParseBool(regressed); err != nil {
+			http.Error(w, "regressed must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	if offset := params.Get("offset"); offset != "" {
+		if query.Offset, err = strconv.Atoi(offset); err != nil {
+			http.Error(w, "offset must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	issues, err := store.ListIssues(query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issues": issues,
+	})
+}
+
+func (s *Server) getIssue(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.issueStore(w)
+	if !ok {
+		return
+	}
+
+	issue, err := store.GetIssue(mux.Vars(r)["id"])
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(issue)
+}
+
+// updateIssue sets the status of an issue from a body such as
+// {"status": "resolved"}.
+func (s *Server) updateIssue(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.issueStore(w)
+	if !ok {
+		return
+	}
+
+	var update struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status, err := models.ParseIssueStatus(update.Status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	issue, err := store.SetIssueStatus(mux.Vars(r)["id"], status)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(issue)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+const (
+	defaultContextLines = 10
+	maxContextLines     = 1000
+	// contextWindow bounds how far from a log its context is looked for.
+	contextWindow = time.Hour
+)
+
+func (s *Server) getLog(w http.ResponseWriter, r *http.Request) {
+	log, err := storage.GetLog(s.storage, mux.Vars(r)["id"])
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(log)
+}
+
+// logContext returns the logs around one, like grep -C: before and after
+// lines (10 by default) from the same host and source, or the fields listed
+// in scope, within contextWindow of it.
+func (s *Server) logContext(w http.ResponseWriter, r *http.Request) {
+	lines := map[string]int{"before": defaultContextLines, "after": defaultContextLines}
+	for name := range lines {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, name+" must be a number", http.StatusBadRequest)
+				return
+			}
+			lines[name] = min(n, maxContextLines)
+		}
+	}
+
+	scope := []string{"host", "source"}
+	if v := r.URL.Query()["scope"]; len(v) > 0 {
+		scope = nil
+		for _, value := range v {
+			for _, field := range strings.Split(value, ",") {
+				if field = strings.TrimSpace(field); field != "" {
+					scope = append(scope, field)
+				}
+			}
+		}
+	}
+
+	log, err := storage.GetLog(s.storage, mux.Vars(r)["id"])
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	before, after, err := storage.LogContext(s.storage, log, scope, lines["before"], lines["after"], contextWindow)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"log":    log,
+		"before": before,
+		"after":  after,
+		"scope":  scope,
+	})
+}
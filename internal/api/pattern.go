@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+const (
+	defaultPatternExamples = 3
+	maxPatternExamples     = 20
+)
+
+// listPatterns lists the message templates covering the most logs matching
+// the query string, with a few example logs each.
+func (s *Server) listPatterns(w http.ResponseWriter, r *http.Request) {
+	store, ok := storage.Unwrap(s.storage).(storage.PatternStore)
+	if !ok {
+		http.Error(w, "storage backend does not support patterns", http.StatusNotImplemented)
+		return
+	}
+
+	query, err := parseLogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := storage.DefaultPatternLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	examples := defaultPatternExamples
+	if e := r.URL.Query().Get("examples"); e != "" {
+		if examples, err = strconv.Atoi(e); err != nil || examples < 0 {
+			http.Error(w, "examples must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	// limit is the number of patterns here, not of logs
+	query.Limit = 0
+
+	patterns, err := store.TopPatterns(models.PatternQuery{
+		Query:    query,
+		Limit:    limit,
+		Examples: min(examples, maxPatternExamples),
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"patterns": patterns,
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/retention"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+func (s *Server) listRetentionPolicies(w http.ResponseWriter, r *http.Request) {
+	policies := s.retention
+	if policies == nil {
+		policies = []models.RetentionPolicy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+// previewRetention reports how many logs each policy would delete if it were
+// enforced now, and which partitions would be dropped outright. Like the
+// enforcer, logs that have not been archived yet are never counted.
+func (s *Server) previewRetention(w http.ResponseWriter, r *http.Request) {
+	store, ok := storage.Unwrap(s.storage).(storage.RetentionStore)
+	if !ok {
+		http.Error(w, "storage backend does not support retention", http.StatusNotImplemented)
+		return
+	}
+
+	now := time.Now()
+	var archived time.Time
+	if s.archive != nil {
+		archived = s.archive.ArchivedUntil()
+	}
+
+	previews := []models.RetentionPreview{}
+	for _, scope := range retention.Scopes(s.retention, now) {
+		if s.archive != nil {
+			scope.Before = retention.KeepUnarchived(scope.Before, archived)
+		}
+		count, err := store.CountExpired(scope)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		previews = append(previews, models.RetentionPreview{
+			Policy:  scope.Policy,
+			Cutoff:  scope.Before,
+			Expired: count,
+		})
+	}
+
+	partitions := []map[string]interface{}{}
+	if postgres, ok := store.(*storage.PostgresStorage); ok {
+		if cutoff, ok := retention.PartitionCutoff(s.retention, now); ok {
+			if s.archive != nil {
+				cutoff = retention.KeepUnarchived(cutoff, archived)
+			}
+			expired, err := postgres.ExpiredPartitions(cutoff)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, p := range expired {
+				partitions = append(partitions, map[string]interface{}{
+					"name": p.Name,
+					"from": p.From,
+					"to":   p.To,
+				})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"previews":   previews,
+		"partitions": partitions,
+	})
+}
@@ -1,31 +1,93 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/krishnaGauss/SoCode/internal/archive"
+	"github.com/krishnaGauss/SoCode/internal/config"
 	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
 	"github.com/krishnaGauss/SoCode/internal/storage"
 	"github.com/rs/cors"
 )
 
 type Server struct {
-	storage  *storage.PostgresStorage
-	upgrader websocket.Upgrader
+	storage    storage.LogStore
+	retention  []models.RetentionPolicy
+	countLimit int
+	facetLimit int
+	// correlationKeys are the keys timelines can be built on.
+	correlationKeys []string
+	// traceWindow bounds traces and timelines without a closed time range.
+	traceWindow   time.Duration
+	exportTimeout time.Duration
+	upgrader      websocket.Upgrader
+
+	// archive, when set, holds back retention previews like the enforcer
+	archive *archive.Archive
+
+	shutdown chan struct{}
+	// mu guards closing, so no socket is added to sockets once Shutdown
+	// waits on it
+	mu      sync.Mutex
+	closing bool
+	sockets sync.WaitGroup
 }
 
-func NewServer(storage *storage.PostgresStorage) *Server {
+func NewServer(storage storage.LogStore, retention []models.RetentionPolicy, cfg *config.QueryConfig) *Server {
 	return &Server{
-		storage: storage,
+		storage:         storage,
+		retention:       retention,
+		countLimit:      cfg.CountLimit,
+		facetLimit:      cfg.FacetLimit,
+		correlationKeys: cfg.CorrelationKeys,
+		traceWindow:     cfg.TraceWindow,
+		exportTimeout:   cfg.ExportTimeout,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins in development
 			},
 		},
+		shutdown: make(chan struct{}),
+	}
+}
+
+// KeepUnarchived makes retention previews leave out logs newer than the end
+// of a, matching what the enforcer deletes.
+func (s *Server) KeepUnarchived(a *archive.Archive) {
+	s.archive = a
+}
+
+// Shutdown sends a close frame to every open websocket and waits for their
+// handlers to return. http.Server.Shutdown does not track hijacked
+// connections, so this must be called alongside it.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closing {
+		s.closing = true
+		close(s.shutdown)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.sockets.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -34,7 +96,20 @@ func (s *Server) SetupRoutes() http.Handler {
 
 	r.HandleFunc("/api/logs", s.queryLogs).Methods("GET")
 	r.HandleFunc("/api/logs/search", s.searchLogs).Methods("POST")
+	r.HandleFunc("/api/logs/aggregate", s.aggregateLogs).Methods("POST")
 	r.HandleFunc("/api/logs/ws", s.handleWebSocket)
+	r.HandleFunc("/api/logs/export", s.exportLogs).Methods("GET")
+	r.HandleFunc("/api/logs/{id}", s.getLog).Methods("GET")
+	r.HandleFunc("/api/logs/{id}/context", s.logContext).Methods("GET")
+	r.HandleFunc("/api/facets", s.listFacets).Methods("GET")
+	r.HandleFunc("/api/patterns", s.listPatterns).Methods("GET")
+	r.HandleFunc("/api/issues", s.listIssues).Methods("GET")
+	r.HandleFunc("/api/issues/{id}", s.getIssue).Methods("GET")
+	r.HandleFunc("/api/issues/{id}", s.updateIssue).Methods("PATCH")
+	r.HandleFunc("/api/traces/{traceID}/logs", s.traceLogs).Methods("GET")
+	r.HandleFunc("/api/timeline/{key}/{value}", s.timeline).Methods("GET")
+	r.HandleFunc("/api/retention/policies", s.listRetentionPolicies).Methods("GET")
+	r.HandleFunc("/api/retention/preview", s.previewRetention).Methods("GET")
 	r.HandleFunc("/health", s.healthCheck).Methods("GET")
 
 	// Serve static files
@@ -43,7 +118,7 @@ func (s *Server) SetupRoutes() http.Handler {
 	// Setup CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
 	})
 
@@ -51,6 +126,17 @@ func (s *Server) SetupRoutes() http.Handler {
 }
 
 func (s *Server) queryLogs(w http.ResponseWriter, r *http.Request) {
+	query, err := parseLogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.writeLogs(w, query, r.URL.Query().Get("total"))
+}
+
+// parseLogQuery reads the filters of a query from the query string.
+func parseLogQuery(r *http.Request) (models.LogQuery, error) {
 	query := models.LogQuery{}
 
 	if startTime := r.URL.Query().Get("start_time"); startTime != "" {
@@ -78,6 +164,10 @@ func (s *Server) queryLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query.Search = r.URL.Query().Get("search")
+	query.Expr = r.URL.Query().Get("q")
+	query.SearchMode = models.SearchMode(r.URL.Query().Get("search_mode"))
+	query.Sort = r.URL.Query().Get("sort")
+	query.Order = r.URL.Query().Get("order")
 	query.Source = r.URL.Query()["source"]
 	query.Service = r.URL.Query()["service"]
 	query.Host = r.URL.Query()["host"]
@@ -86,46 +176,164 @@ func (s *Server) queryLogs(w http.ResponseWriter, r *http.Request) {
 		query.Level = append(query.Level, models.LogLevel(level))
 	}
 
-	logs, err := s.storage.QueryLogs(query)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	for _, filter := range r.URL.Query()["filter"] {
+		f, err := models.ParseFilter(filter)
+		if err != nil {
+			return models.LogQuery{}, err
+		}
+		query.Filters = append(query.Filters, f)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"logs":  logs,
-		"count": len(logs),
-	})
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		c, err := models.ParseCursor(cursor)
+		if err != nil {
+			return models.LogQuery{}, err
+		}
+		query.Cursor = c
+	}
+
+	return query, nil
 }
 
 func (s *Server) searchLogs(w http.ResponseWriter, r *http.Request) {
-	var query models.LogQuery
-	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+	var body struct {
+		models.LogQuery
+		Total string `json:"total"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.writeLogs(w, body.LogQuery, body.Total)
+}
+
+// writeLogs runs query and writes a page of logs along with the cursors of
+// the neighbouring pages and the total, counted as totalMode asks: "auto"
+// (the default) counts exactly up to the count limit and estimates beyond
+// it, "exact" always counts exactly and "none" skips counting.
+// Expressions ending in a count pipeline are answered with counts instead.
+func (s *Server) writeLogs(w http.ResponseWriter, query models.LogQuery, totalMode string) {
+	filter, pipeline, err := querylang.SplitPipeline(query.Expr)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if pipeline != nil {
+		query.Expr = filter
+		s.writeCounts(w, query, pipeline)
+		return
+	}
+
+	countLimit := s.countLimit
+	switch totalMode {
+	case "", "auto":
+	case "exact":
+		countLimit = 0
+	case "none":
+	default:
+		http.Error(w, "total must be auto, exact or none", http.StatusBadRequest)
+		return
+	}
 
 	logs, err := s.storage.QueryLogs(query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeQueryError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"logs":  logs,
 		"count": len(logs),
+	}
+	if totalMode != "none" {
+		total, err := s.storage.CountLogs(query, countLimit)
+		if err != nil {
+			writeQueryError(w, err)
+			return
+		}
+		response["total"] = total
+	}
+	next, prev := models.PageCursors(query, logs)
+	if next != nil {
+		response["next_cursor"] = next
+	}
+	if prev != nil {
+		response["prev_cursor"] = prev
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeCounts runs the count pipeline of a query language expression.
+func (s *Server) writeCounts(w http.ResponseWriter, query models.LogQuery, pipeline *querylang.Pipeline) {
+	buckets, err := s.storage.AggregateLogs(models.AggregateQuery{Query: query, GroupBy: pipeline.GroupBy})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	counts := []models.AggregateCount{}
+	for _, bucket := range buckets {
+		counts = append(counts, bucket.Groups...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"counts": counts,
 	})
 }
 
+// writeQueryError reports invalid queries as bad requests, missing issues and
+// logs as not found and anything else as an internal error.
+func writeQueryError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, storage.ErrIssueNotFound) || errors.Is(err, storage.ErrLogNotFound) {
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// trackSocket counts a new websocket for Shutdown to wait on, unless the
+// server is already shutting down.
+func (s *Server) trackSocket() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.sockets.Add(1)
+	return true
+}
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.trackSocket() {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.sockets.Done()
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
 	defer conn.Close()
 
+	// The client never sends data, but reading is required to process its
+	// close frame and to notice when it goes away.
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
 	// Send real-time logs (simplified implementation)
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
@@ -148,11 +356,25 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if len(logs) > 0 {
-				conn.WriteJSON(map[string]interface{}{
+				err := conn.WriteJSON(map[string]interface{}{
 					"type": "new_logs",
 					"logs": logs,
 				})
+				if err != nil {
+					return
+				}
+			}
+		case <-clientGone:
+			return
+		case <-s.shutdown:
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			// give the client a moment to answer with its own close frame
+			select {
+			case <-clientGone:
+			case <-time.After(time.Second):
 			}
+			return
 		}
 	}
 }
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+	"github.com/krishnaGauss/SoCode/internal/correlation"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// timeline follows a request, session or user across services: the logs
+// whose tags or metadata hold value under one of the correlation keys, in
+// time order, with the time spent in each service, the gaps between them and
+// the first error.
+func (s *Server) timeline(w http.ResponseWriter, r *http.Request) {
+	key, value := mux.Vars(r)["key"], mux.Vars(r)["value"]
+	if !slices.Contains(s.correlationKeys, key) {
+		http.Error(w, "unknown correlation key "+key, http.StatusNotFound)
+		return
+	}
+
+	limit, err := timelineLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the rest of the query string narrows the timeline down
+	query, err := parseLogQuery(r)
+	if err == nil {
+		err = s.window(r, &query)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expr := correlation.Expr(key, value)
+	if query.Expr != "" {
+		expr = "(" + expr + ") AND (" + query.Expr + ")"
+	}
+	query.Expr, query.Order, query.Sort = expr, models.OrderAsc, ""
+	query.Limit, query.Offset, query.Cursor = limit+1, 0, nil
+
+	logs, err := s.storage.QueryLogs(query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	truncated := len(logs) > limit
+	if truncated {
+		logs = logs[:limit]
+	}
+
+	timeline := correlation.Build(key, value, logs)
+	timeline.Truncated = truncated
+	timeline.WindowStart, timeline.WindowEnd = *query.StartTime, *query.EndTime
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
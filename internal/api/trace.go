@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// Traces and timelines return up to this many logs by default, and at most
+// the maximum.
+const (
+	defaultTimelineLogs = 1000
+	maxTimelineLogs     = 10000
+)
+
+// timelineLimit reads the limit of a trace or timeline from the query string.
+func timelineLimit(r *http.Request) (int, error) {
+	limit := defaultTimelineLogs
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			return 0, errors.New("limit must be a positive number")
+		}
+	}
+	return min(limit, maxTimelineLogs), nil
+}
+
+// window bounds a trace or timeline in time so it reads part of the store
+// rather than all of it. around asks for the trace window either side of a
+// time, such as that of one of its logs. Otherwise an open end is the window
+// after start_time, an open start the window before end_time, and with
+// neither the window runs up to now.
+func (s *Server) window(r *http.Request, query *models.LogQuery) error {
+	around, err := queryTime(r, "around")
+	if err != nil {
+		return err
+	}
+	if around != nil {
+		if query.StartTime != nil || query.EndTime != nil {
+			return errors.New("around cannot be used with start_time or end_time")
+		}
+		start, end := around.Add(-s.traceWindow), around.Add(s.traceWindow)
+		query.StartTime, query.EndTime = &start, &end
+		return nil
+	}
+
+	switch {
+	case query.StartTime == nil && query.EndTime == nil:
+		end := time.Now().UTC()
+		start := end.Add(-s.traceWindow)
+		query.StartTime, query.EndTime = &start, &end
+	case query.StartTime == nil:
+		start := query.EndTime.Add(-s.traceWindow)
+		query.StartTime = &start
+	case query.EndTime == nil:
+		end := query.StartTime.Add(s.traceWindow)
+		query.EndTime = &end
+	}
+	return nil
+}
+
+// queryTime reads an optional time from the query string.
+func queryTime(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(name + " must be an RFC 3339 time")
+	}
+	return &t, nil
+}
+
+// traceLogs returns the logs of a trace from every service in time order,
+// marking where the trace moves from one service to another.
+func (s *Server) traceLogs(w http.ResponseWriter, r *http.Request) {
+	traceID := mux.Vars(r)["traceID"]
+
+	limit, err := timelineLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// one more than the limit tells whether the trace was cut short
+	query := models.LogQuery{
+		Filters: []models.FieldFilter{{Field: "trace_id", Op: models.FilterEquals, Value: traceID}},
+		Order:   models.OrderAsc,
+		Limit:   limit + 1,
+	}
+	if query.StartTime, err = queryTime(r, "start_time"); err == nil {
+		if query.EndTime, err = queryTime(r, "end_time"); err == nil {
+			err = s.window(r, &query)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logs, err := s.storage.QueryLogs(query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	truncated := len(logs) > limit
+	if truncated {
+		logs = logs[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"trace_id":     traceID,
+		"logs":         models.MarkTransitions(logs),
+		"count":        len(logs),
+		"services":     models.TraceServices(logs),
+		"truncated":    truncated,
+		"window_start": query.StartTime,
+		"window_end":   query.EndTime,
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestWindow(t *testing.T) {
+	s := &Server{traceWindow: time.Hour}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		url        string
+		start, end *time.Time
+		wantStart  time.Time
+		wantEnd    time.Time
+	}{
+		{"around", "/?around=2024-05-01T12:00:00Z", nil, nil, at.Add(-time.Hour), at.Add(time.Hour)},
+		{"after the start", "/", &at, nil, at, at.Add(time.Hour)},
+		{"before the end", "/", nil, &at, at.Add(-time.Hour), at},
+		{"closed range", "/", &at, ptr(at.Add(48 * time.Hour)), at, at.Add(48 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := models.LogQuery{StartTime: tt.start, EndTime: tt.end}
+			if err := s.window(httptest.NewRequest("GET", tt.url, nil), &query); err != nil {
+				t.Fatal(err)
+			}
+			if !query.StartTime.Equal(tt.wantStart) || !query.EndTime.Equal(tt.wantEnd) {
+				t.Errorf("got %s to %s, want %s to %s", query.StartTime, query.EndTime, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	query := models.LogQuery{}
+	if err := s.window(httptest.NewRequest("GET", "/", nil), &query); err != nil {
+		t.Fatal(err)
+	}
+	if d := query.EndTime.Sub(*query.StartTime); d != time.Hour || time.Since(*query.EndTime) > time.Minute {
+		t.Errorf("got %s to %s, want the last hour", query.StartTime, query.EndTime)
+	}
+
+	for _, url := range []string{"/?around=noon", "/?around=2024-05-01T12:00:00Z&x=1"} {
+		query := models.LogQuery{StartTime: &at}
+		if err := s.window(httptest.NewRequest("GET", url, nil), &query); err == nil {
+			t.Errorf("%s: got no error", url)
+		}
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,384 @@
+// Package archive keeps copies of old logs in compressed segment files, each
+// covering a closed time range, described by a manifest.
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+const manifestFile = "manifest.json"
+
+// FormatNDJSONGzip is gzip-compressed JSON, one log per line.
+const FormatNDJSONGzip = "ndjson.gz"
+
+// ErrChecksum is returned when a segment file does not match its manifest.
+var ErrChecksum = errors.New("segment checksum mismatch")
+
+// Segment describes one file of the archive, holding the logs with
+// From <= timestamp < To.
+type Segment struct {
+	File   string    `json:"file"`
+	Format string    `json:"format"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// First and Last are the timestamps of the oldest and newest log.
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+	Services  []string  `json:"services"`
+	Rows      int64     `json:"rows"`
+	Bytes     int64     `json:"bytes"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Manifest struct {
+	Segments []Segment `json:"segments"`
+}
+
+// Archive is a directory of segments and their manifest.
+type Archive struct {
+	dir string
+
+	mu       sync.RWMutex
+	manifest Manifest
+	// modTime is the modification time of the manifest when it was loaded
+	modTime time.Time
+}
+
+// Open opens the archive in dir, creating the directory if needed.
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	a := &Archive{dir: dir}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Archive) load() error {
+	path := filepath.Join(a.dir, manifestFile)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		a.manifest = Manifest{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive manifest: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read archive manifest: %w", err)
+	}
+	a.modTime = info.ModTime()
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	a.manifest = manifest
+	return nil
+}
+
+// refresh reloads the manifest when another process, such as the log server
+// archiving in the background, changed it.
+func (a *Archive) refresh() {
+	info, err := os.Stat(filepath.Join(a.dir, manifestFile))
+	if err != nil {
+		return
+	}
+
+	a.mu.RLock()
+	stale := !info.ModTime().Equal(a.modTime)
+	a.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.load(); err != nil {
+		slog.Warn("failed to reload archive manifest", slog.String("error", err.Error()))
+	}
+}
+
+// Segments lists the segments of the archive, oldest first.
+func (a *Archive) Segments() []Segment {
+	a.refresh()
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Clone(a.manifest.Segments)
+}
+
+// Overlapping lists the segments that may hold logs between from and to,
+// oldest first. Nil bounds are open.
+func (a *Archive) Overlapping(from, to *time.Time) []Segment {
+	var segments []Segment
+	for _, seg := range a.Segments() {
+		if from != nil && seg.Last.Before(*from) {
+			continue
+		}
+		if to != nil && seg.First.After(*to) {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// ArchivedUntil returns the end of the newest segment, or the zero time when
+// the archive is empty.
+func (a *Archive) ArchivedUntil() time.Time {
+	a.refresh()
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var until time.Time
+	for _, seg := range a.manifest.Segments {
+		if seg.To.After(until) {
+			until = seg.To
+		}
+	}
+	return until
+}
+
+// add records a segment in the manifest, replacing any segment over the same
+// range, and saves it.
+func (a *Archive) add(seg Segment) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// another process, such as the archive command, may have added segments
+	if err := a.load(); err != nil {
+		return err
+	}
+
+	segments := slices.DeleteFunc(a.manifest.Segments, func(s Segment) bool {
+		return s.From.Equal(seg.From) && s.To.Equal(seg.To)
+	})
+	segments = append(segments, seg)
+	slices.SortFunc(segments, func(a, b Segment) int {
+		return a.From.Compare(b.From)
+	})
+
+	data, err := json.MarshalIndent(Manifest{Segments: segments}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(a.dir, manifestFile), data); err != nil {
+		return fmt.Errorf("failed to save archive manifest: %w", err)
+	}
+	a.manifest.Segments = segments
+	return nil
+}
+
+// writeFile replaces path atomically.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Writer writes the logs of one segment. Logs must be written in timestamp
+// order and the segment only appears in the manifest once committed.
+type Writer struct {
+	archive *Archive
+	seg     Segment
+	file    *os.File
+	hash    hash.Hash
+	counter *countingWriter
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+
+	services map[string]bool
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Create starts a segment covering from <= timestamp < to.
+func (a *Archive) Create(from, to time.Time) (*Writer, error) {
+	from, to = from.UTC(), to.UTC()
+	name := fmt.Sprintf("logs-%s-%s.%s", from.Format("20060102T150405Z"), to.Format("20060102T150405Z"), FormatNDJSONGzip)
+
+	file, err := os.CreateTemp(a.dir, name+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	w := &Writer{
+		archive:  a,
+		seg:      Segment{File: name, Format: FormatNDJSONGzip, From: from, To: to},
+		file:     file,
+		hash:     sha256.New(),
+		services: make(map[string]bool),
+	}
+	w.counter = &countingWriter{w: io.MultiWriter(file, w.hash)}
+	w.gzip = gzip.NewWriter(w.counter)
+	w.encoder = json.NewEncoder(w.gzip)
+	return w, nil
+}
+
+func (w *Writer) Write(log models.LogEntry) error {
+	if log.Timestamp.Before(w.seg.From) || !log.Timestamp.Before(w.seg.To) {
+		return fmt.Errorf("log %s at %s is outside the segment", log.ID, log.Timestamp.Format(time.RFC3339Nano))
+	}
+	if err := w.encoder.Encode(log); err != nil {
+		return err
+	}
+
+	if w.seg.Rows == 0 {
+		w.seg.First = log.Timestamp
+	}
+	w.seg.Last = log.Timestamp
+	w.seg.Rows++
+	if !w.services[log.Service] {
+		w.services[log.Service] = true
+		w.seg.Services = append(w.seg.Services, log.Service)
+	}
+	return nil
+}
+
+// Commit closes the file and records the segment in the manifest. Empty
+// segments are discarded and reported with zero rows.
+func (w *Writer) Commit() (Segment, error) {
+	if err := w.gzip.Close(); err != nil {
+		w.Abort()
+		return Segment{}, err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return Segment{}, err
+	}
+	if err := w.file.Close(); err != nil {
+		w.Abort()
+		return Segment{}, err
+	}
+	if w.seg.Rows == 0 {
+		os.Remove(w.file.Name())
+		return w.seg, nil
+	}
+
+	slices.Sort(w.seg.Services)
+	w.seg.Bytes = w.counter.n
+	w.seg.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	w.seg.CreatedAt = time.Now().UTC()
+
+	if err := os.Rename(w.file.Name(), filepath.Join(w.archive.dir, w.seg.File)); err != nil {
+		os.Remove(w.file.Name())
+		return Segment{}, err
+	}
+	if err := w.archive.add(w.seg); err != nil {
+		return Segment{}, err
+	}
+	return w.seg, nil
+}
+
+// Abort discards the segment. It is safe to call after Commit.
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Read calls fn for every log of seg in timestamp order. The checksum is
+// verified once the whole file is read, so fn may see logs of a corrupt
+// segment before ErrChecksum is returned; use Verify first where that
+// matters.
+func (a *Archive) Read(seg Segment, fn func(models.LogEntry) error) error {
+	if seg.Format != FormatNDJSONGzip {
+		return fmt.Errorf("unsupported segment format %q", seg.Format)
+	}
+
+	file, err := os.Open(filepath.Join(a.dir, seg.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	tee := io.TeeReader(file, h)
+	gz, err := gzip.NewReader(tee)
+	if err != nil {
+		return fmt.Errorf("%s: %w", seg.File, err)
+	}
+
+	decoder := json.NewDecoder(gz)
+	for {
+		var log models.LogEntry
+		if err := decoder.Decode(&log); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", seg.File, err)
+		}
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+
+	// drain whatever gzip did not need, so the hash covers the whole file
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != seg.SHA256 {
+		return fmt.Errorf("%s: %w", seg.File, ErrChecksum)
+	}
+	return nil
+}
+
+// Verify checks the file of seg against its checksum.
+func (a *Archive) Verify(seg Segment) error {
+	file, err := os.Open(filepath.Join(a.dir, seg.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != seg.SHA256 {
+		return fmt.Errorf("%s: %w", seg.File, ErrChecksum)
+	}
+	return nil
+}
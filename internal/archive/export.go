@@ -0,0 +1,205 @@
+package archive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// Export copies the logs with from <= timestamp < to from store into a new
+// segment, reading batchSize logs at a time. Segments without logs are not
+// recorded.
+func Export(store storage.LogStore, a *Archive, from, to time.Time, batchSize int) (Segment, error) {
+	w, err := a.Create(from, to)
+	if err != nil {
+		return Segment{}, err
+	}
+	defer w.Abort()
+
+	p := newPager(store, from, to, batchSize)
+	for {
+		log, ok, err := p.peek()
+		if err != nil {
+			return Segment{}, err
+		}
+		if !ok {
+			break
+		}
+		if err := w.Write(log); err != nil {
+			return Segment{}, err
+		}
+		p.next()
+	}
+
+	return w.Commit()
+}
+
+// Reconcile adds the logs of store in the range of seg that seg does not
+// hold, such as logs that arrived after it was written, by rewriting seg
+// with them. It returns the segment, rewritten or not, and how many logs it
+// added. Logs are told apart by ID and timestamp. seg is read once, or twice
+// when logs are missing, and the logs of store batchSize at a time.
+func Reconcile(store storage.LogStore, a *Archive, seg Segment, batchSize int) (Segment, int64, error) {
+	var missing int64
+	err := merge(store, a, seg, batchSize, func(_ models.LogEntry, archived bool) error {
+		if !archived {
+			missing++
+		}
+		return nil
+	})
+	if err != nil || missing == 0 {
+		return seg, 0, err
+	}
+
+	w, err := a.Create(seg.From, seg.To)
+	if err != nil {
+		return seg, 0, err
+	}
+	defer w.Abort()
+	if err := merge(store, a, seg, batchSize, func(log models.LogEntry, _ bool) error {
+		return w.Write(log)
+	}); err != nil {
+		return seg, 0, err
+	}
+	rewritten, err := w.Commit()
+	if err != nil {
+		return seg, 0, err
+	}
+	return rewritten, rewritten.Rows - seg.Rows, nil
+}
+
+// merge calls fn, in timestamp order, for every log of seg and every log of
+// store in its range that seg does not hold, telling which is which.
+func merge(store storage.LogStore, a *Archive, seg Segment, batchSize int, fn func(log models.LogEntry, archived bool) error) error {
+	p := newPager(store, seg.From, seg.To, batchSize)
+
+	// the IDs of the archived logs at the newest timestamp read so far; the
+	// order of IDs within a timestamp is left to the store
+	var at time.Time
+	ids := make(map[string]bool)
+	// flush passes on the logs of store before ts, or all of them when ts
+	// is nil
+	flush := func(ts *time.Time) error {
+		for {
+			log, ok, err := p.peek()
+			if err != nil || !ok || (ts != nil && !log.Timestamp.Before(*ts)) {
+				return err
+			}
+			if !log.Timestamp.Equal(at) || !ids[log.ID] {
+				if err := fn(log, false); err != nil {
+					return err
+				}
+			}
+			p.next()
+		}
+	}
+
+	err := a.Read(seg, func(log models.LogEntry) error {
+		if err := flush(&log.Timestamp); err != nil {
+			return err
+		}
+		if !log.Timestamp.Equal(at) {
+			at = log.Timestamp
+			clear(ids)
+		}
+		ids[log.ID] = true
+		return fn(log, true)
+	})
+	if err != nil {
+		return err
+	}
+	return flush(nil)
+}
+
+// pager reads the logs of a store with from <= timestamp < to in timestamp
+// order, batchSize at a time.
+type pager struct {
+	store     storage.LogStore
+	query     models.LogQuery
+	batchSize int
+	logs      []models.LogEntry
+	done      bool
+}
+
+func newPager(store storage.LogStore, from, to time.Time, batchSize int) *pager {
+	// the end time of a query is inclusive, timestamps are never finer than
+	// a nanosecond
+	end := to.Add(-time.Nanosecond)
+	return &pager{
+		store:     store,
+		query:     models.LogQuery{StartTime: &from, EndTime: &end, Order: models.OrderAsc, Limit: batchSize},
+		batchSize: batchSize,
+	}
+}
+
+// peek returns the next log, reading a batch when needed, and false once
+// every log was read.
+func (p *pager) peek() (models.LogEntry, bool, error) {
+	if len(p.logs) == 0 && !p.done {
+		logs, err := p.store.QueryLogs(p.query)
+		if err != nil {
+			return models.LogEntry{}, false, fmt.Errorf("failed to read logs: %w", err)
+		}
+		p.logs = logs
+		p.done = len(logs) < p.batchSize
+		if len(logs) > 0 {
+			last := logs[len(logs)-1]
+			p.query.Cursor = &models.Cursor{Timestamp: last.Timestamp, ID: last.ID}
+		}
+	}
+	if len(p.logs) == 0 {
+		return models.LogEntry{}, false, nil
+	}
+	return p.logs[0], true, nil
+}
+
+func (p *pager) next() {
+	p.logs = p.logs[1:]
+}
+
+// Restore stores the archived logs with from <= timestamp < to back into
+// store, batchSize at a time, and returns how many it read. Segments are
+// verified before any of their logs are stored. Logs already present are
+// left alone by stores that ignore duplicates, as PostgreSQL does.
+func Restore(store storage.LogStore, a *Archive, from, to time.Time, batchSize int) (int64, error) {
+	write := store.StoreLogs
+	if bulk, ok := store.(storage.BulkLogStore); ok {
+		write = bulk.StoreLogsCopy
+	}
+
+	var restored int64
+	last := to.Add(-time.Nanosecond)
+	for _, seg := range a.Overlapping(&from, &last) {
+		if err := a.Verify(seg); err != nil {
+			return restored, err
+		}
+
+		batch := make([]models.LogEntry, 0, batchSize)
+		err := a.Read(seg, func(log models.LogEntry) error {
+			if log.Timestamp.Before(from) || !log.Timestamp.Before(to) {
+				return nil
+			}
+			batch = append(batch, log)
+			if len(batch) < batchSize {
+				return nil
+			}
+			if err := write(batch); err != nil {
+				return err
+			}
+			restored += int64(len(batch))
+			batch = batch[:0]
+			return nil
+		})
+		if err == nil && len(batch) > 0 {
+			if err = write(batch); err == nil {
+				restored += int64(len(batch))
+			}
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", seg.File, err)
+		}
+	}
+	return restored, nil
+}
@@ -0,0 +1,109 @@
+package archive
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage/storagetest"
+)
+
+func readIDs(t *testing.T, a *Archive, seg Segment) []string {
+	t.Helper()
+	var logs []models.LogEntry
+	if err := a.Read(seg, func(log models.LogEntry) error {
+		logs = append(logs, log)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return ids(logs)
+}
+
+func TestExport(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := dayLogs(0, 5)
+	// pages end in the middle of logs sharing a timestamp
+	logs[2].Timestamp = logs[1].Timestamp
+	logs[3].Timestamp = logs[1].Timestamp
+	store := storagetest.NewMemoryStore(append(logs, dayLogs(1, 2)...)...)
+
+	seg, err := Export(store, a, day, day.AddDate(0, 0, 1), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seg.Rows != 5 || !seg.First.Equal(logs[0].Timestamp) || !seg.Last.Equal(logs[4].Timestamp) {
+		t.Errorf("got %d rows from %s to %s", seg.Rows, seg.First, seg.Last)
+	}
+	if got := readIDs(t, a, seg); !slices.Equal(got, ids(logs)) {
+		t.Errorf("got %v, want %v", got, ids(logs))
+	}
+
+	// ranges without logs get no segment
+	seg, err = Export(store, a, day.AddDate(0, 0, 5), day.AddDate(0, 0, 6), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seg.Rows != 0 || len(a.Segments()) != 1 {
+		t.Errorf("got %d rows and %d segments", seg.Rows, len(a.Segments()))
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := dayLogs(0, 4)
+	store := storagetest.NewMemoryStore(logs...)
+	seg, err := Export(store, a, day, day.AddDate(0, 0, 1), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing arrived since
+	got, added, err := Reconcile(store, a, seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 0 || got.SHA256 != seg.SHA256 {
+		t.Errorf("added %d logs and rewrote the segment", added)
+	}
+
+	// late logs before, between, at the timestamp of and after the archived
+	// ones, while retention already deleted one of those
+	late := []models.LogEntry{
+		{ID: "late-0", Timestamp: day.Add(time.Minute), Service: "api"},
+		{ID: "late-1", Timestamp: logs[1].Timestamp, Service: "api"},
+		{ID: "late-2", Timestamp: logs[2].Timestamp.Add(time.Minute), Service: "api"},
+		{ID: "late-3", Timestamp: day.Add(23 * time.Hour), Service: "api"},
+	}
+	if err := store.StoreLogs(late); err != nil {
+		t.Fatal(err)
+	}
+	store.Delete(func(log models.LogEntry) bool { return log.ID == logs[0].ID })
+
+	got, added, err = Reconcile(store, a, seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 4 || got.Rows != 8 || !got.First.Equal(late[0].Timestamp) || !got.Last.Equal(late[3].Timestamp) {
+		t.Errorf("added %d logs, got %d rows from %s to %s", added, got.Rows, got.First, got.Last)
+	}
+	want := []string{"late-0", "d0-00", "d0-01", "late-1", "d0-02", "late-2", "d0-03", "late-3"}
+	if ids := readIDs(t, a, got); !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+	if segs := a.Segments(); len(segs) != 1 || segs[0].SHA256 != got.SHA256 {
+		t.Errorf("the manifest holds %+v", segs)
+	}
+
+	// once reconciled, there is nothing left to add
+	if _, added, err = Reconcile(store, a, got, 3); err != nil || added != 0 {
+		t.Errorf("added %d logs again, err %v", added, err)
+	}
+}
@@ -0,0 +1,336 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// TieredStore answers QueryLogs and CountLogs from the hot store and the
+// archive together, for queries with a time range. Everything else,
+// including relevance-sorted searches, is left to the hot store.
+type TieredStore struct {
+	storage.LogStore
+	archive     *Archive
+	maxSegments int
+
+	mu sync.Mutex
+	// counts caches the number of archived logs matching a query per
+	// segment, segments never change once written
+	counts map[countKey]int64
+}
+
+// countKey identifies the count of a segment for a query.
+type countKey struct {
+	segment string
+	query   string
+}
+
+// maxCachedCounts bounds the count cache, which is cleared when full.
+const maxCachedCounts = 4096
+
+func NewTieredStore(hot storage.LogStore, a *Archive, maxSegments int) *TieredStore {
+	return &TieredStore{LogStore: hot, archive: a, maxSegments: maxSegments, counts: make(map[countKey]int64)}
+}
+
+func (t *TieredStore) Unwrap() storage.LogStore {
+	return t.LogStore
+}
+
+// tiered reports whether query reads the archive. Queries without a time
+// range only read the hot store.
+func tiered(query models.LogQuery) bool {
+	return query.Sort != models.SortRelevance && (query.StartTime != nil || query.EndTime != nil)
+}
+
+// segments lists the segments that may hold logs matching query, pruned by
+// their time range, services and the cursor, in the order a page of query is
+// read in.
+func (t *TieredStore) segments(query models.LogQuery) []Segment {
+	from, to := query.StartTime, query.EndTime
+	desc := storage.Descending(query)
+	if c := query.Cursor; c != nil {
+		ts := c.Timestamp
+		if desc && (to == nil || ts.Before(*to)) {
+			to = &ts
+		}
+		if !desc && (from == nil || ts.After(*from)) {
+			from = &ts
+		}
+	}
+
+	var segments []Segment
+	for _, seg := range t.archive.Overlapping(from, to) {
+		if len(query.Service) > 0 && !slices.ContainsFunc(query.Service, func(s string) bool {
+			_, found := slices.BinarySearch(seg.Services, s)
+			return found
+		}) {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	if desc {
+		slices.Reverse(segments)
+	}
+	return segments
+}
+
+func (t *TieredStore) tooManySegments(query models.LogQuery) error {
+	return fmt.Errorf("%w: the query needs more than %d archive segments; narrow the time range or services",
+		storage.ErrInvalidQuery, t.maxSegments)
+}
+
+// QueryLogs merges the page of the hot store with the matching archived logs
+// in timestamp order. Archived logs are marked Cold, and those still in the
+// hot store are skipped. Segments are read in page order until the page is
+// full.
+func (t *TieredStore) QueryLogs(query models.LogQuery) ([]models.LogEntry, error) {
+	return t.QueryLogsContext(context.Background(), query)
+}
+
+// QueryLogsContext is QueryLogs stopping once ctx is done, checked before
+// each segment is read.
+func (t *TieredStore) QueryLogsContext(ctx context.Context, query models.LogQuery) ([]models.LogEntry, error) {
+	if !tiered(query) {
+		return storage.QueryLogsContext(ctx, t.LogStore, query)
+	}
+	segments := t.segments(query)
+	if len(segments) == 0 {
+		return storage.QueryLogsContext(ctx, t.LogStore, query)
+	}
+
+	matcher, err := storage.NewMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hot store cannot skip the offset for us, as archived logs may come
+	// first
+	keep := 0
+	hotQuery := query
+	if query.Limit > 0 {
+		keep = query.Offset + query.Limit
+		hotQuery.Limit, hotQuery.Offset = keep, 0
+	}
+	hot, err := storage.QueryLogsContext(ctx, t.LogStore, hotQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(hot))
+	for _, log := range hot {
+		seen[log.ID] = true
+	}
+
+	compare := readOrder(query)
+	desc := storage.Descending(query)
+	logs := hot
+	for i, seg := range segments {
+		// later segments only hold logs past the last one kept
+		if keep > 0 && len(logs) >= keep && pastSegment(seg, logs[keep-1], desc) {
+			break
+		}
+		if t.maxSegments > 0 && i == t.maxSegments {
+			return nil, t.tooManySegments(query)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		err := t.archive.Read(seg, func(log models.LogEntry) error {
+			if seen[log.ID] || !matcher.Match(log) {
+				return nil
+			}
+			log.Cold = true
+			logs = append(logs, log)
+			// only the first keep logs can make it to the page
+			if keep > 0 && len(logs) >= 2*keep {
+				slices.SortFunc(logs, compare)
+				logs = logs[:keep]
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(logs, compare)
+		if keep > 0 && len(logs) > keep {
+			logs = logs[:keep]
+		}
+	}
+
+	if query.Offset > 0 {
+		logs = logs[min(query.Offset, len(logs)):]
+	}
+	if query.Limit > 0 && len(logs) > query.Limit {
+		logs = logs[:query.Limit]
+	}
+	if query.Cursor != nil && query.Cursor.Before {
+		slices.Reverse(logs)
+	}
+	return logs, nil
+}
+
+// pastSegment reports whether every log of seg comes after last in the read
+// order.
+func pastSegment(seg Segment, last models.LogEntry, desc bool) bool {
+	if desc {
+		return seg.Last.Before(last.Timestamp)
+	}
+	return seg.First.After(last.Timestamp)
+}
+
+// readOrder compares logs in the order a page of query is read in.
+func readOrder(query models.LogQuery) func(a, b models.LogEntry) int {
+	desc := storage.Descending(query)
+	return func(a, b models.LogEntry) int {
+		c := a.Timestamp.Compare(b.Timestamp)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+}
+
+// CountLogs adds the archived logs to the total of the hot store, as a lower
+// bound once limit is exceeded when it is positive. Within the range of each
+// segment, the hot store and the segment share the logs retention has not
+// deleted yet, and only the larger of the two counts is kept. This is exact
+// as long as one holds the other, which holds until logs arrive in a range
+// retention already deleted from: those are counted once the enforcer added
+// them to the segment, before deleting them. Archived counts come from the
+// manifest when the query keeps every log of a segment, and are cached
+// otherwise. When more than the segment limit would have to be read, the
+// total is reported as a lower bound.
+func (t *TieredStore) CountLogs(query models.LogQuery, limit int) (models.Total, error) {
+	total, err := t.LogStore.CountLogs(query, limit)
+	if err != nil || !tiered(query) || total.Relation == models.TotalAtLeast {
+		return total, err
+	}
+
+	query.Cursor = nil
+	query.Sort, query.Order, query.Limit, query.Offset = "", "", 0, 0
+	var matcher *storage.Matcher
+	read := 0
+	for _, seg := range t.segments(query) {
+		if limit > 0 && total.Value > int64(limit) {
+			total.Relation = models.TotalAtLeast
+			return total, nil
+		}
+
+		segQuery := segmentQuery(query, seg)
+		archived, ok := t.cachedCount(seg, segQuery)
+		if !ok {
+			if t.maxSegments > 0 && read == t.maxSegments {
+				total.Relation = models.TotalAtLeast
+				return total, nil
+			}
+			read++
+			if matcher == nil {
+				if matcher, err = storage.NewMatcher(query); err != nil {
+					return models.Total{}, err
+				}
+			}
+			if archived, err = t.countSegment(seg, segQuery, matcher); err != nil {
+				return models.Total{}, err
+			}
+		}
+		if archived == 0 {
+			continue
+		}
+
+		hotQuery := query
+		from, end := seg.From, seg.To.Add(-time.Nanosecond)
+		if hotQuery.StartTime == nil || hotQuery.StartTime.Before(from) {
+			hotQuery.StartTime = &from
+		}
+		if hotQuery.EndTime == nil || hotQuery.EndTime.After(end) {
+			hotQuery.EndTime = &end
+		}
+		// past the archived count, or limit, the hot count changes nothing
+		bound := archived
+		if limit > 0 && int64(limit) < bound {
+			bound = int64(limit)
+		}
+		hot, err := t.LogStore.CountLogs(hotQuery, int(bound))
+		if err != nil {
+			return models.Total{}, err
+		}
+		if hot.Relation == models.TotalExact && archived > hot.Value {
+			total.Value += archived - hot.Value
+		}
+	}
+	if limit > 0 && total.Value > int64(limit) {
+		total.Relation = models.TotalAtLeast
+	}
+	return total, nil
+}
+
+// segmentQuery narrows query to seg, dropping the bounds and services that
+// every log of seg meets, so queries counting the same logs of a segment
+// look the same.
+func segmentQuery(query models.LogQuery, seg Segment) models.LogQuery {
+	if query.StartTime != nil && !query.StartTime.After(seg.First) {
+		query.StartTime = nil
+	}
+	if query.EndTime != nil && !query.EndTime.Before(seg.Last) {
+		query.EndTime = nil
+	}
+	if len(query.Service) > 0 && !slices.ContainsFunc(seg.Services, func(s string) bool {
+		return !slices.Contains(query.Service, s)
+	}) {
+		query.Service = nil
+	}
+	return query
+}
+
+// cachedCount returns the number of logs of seg matching query, a query
+// narrowed by segmentQuery, when it is known without reading the segment.
+func (t *TieredStore) cachedCount(seg Segment, query models.LogQuery) (int64, bool) {
+	if reflect.ValueOf(query).IsZero() {
+		return seg.Rows, true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count, ok := t.counts[countKeyOf(seg, query)]
+	return count, ok
+}
+
+// countSegment reads seg and caches the number of its logs matching query.
+func (t *TieredStore) countSegment(seg Segment, query models.LogQuery, matcher *storage.Matcher) (int64, error) {
+	var count int64
+	err := t.archive.Read(seg, func(log models.LogEntry) error {
+		if matcher.Match(log) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.counts) >= maxCachedCounts {
+		clear(t.counts)
+	}
+	t.counts[countKeyOf(seg, query)] = count
+	return count, nil
+}
+
+func countKeyOf(seg Segment, query models.LogQuery) countKey {
+	query.ExprNode = nil
+	data, _ := json.Marshal(query)
+	return countKey{segment: seg.SHA256, query: string(data)}
+}
@@ -0,0 +1,280 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+	"github.com/krishnaGauss/SoCode/internal/storage/storagetest"
+)
+
+var day = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// dayLogs returns n logs spread over day d after the test day, alternating
+// between the api and worker services.
+func dayLogs(d, n int) []models.LogEntry {
+	logs := make([]models.LogEntry, n)
+	for i := range logs {
+		service := "api"
+		if i%2 == 1 {
+			service = "worker"
+		}
+		logs[i] = models.LogEntry{
+			ID:        fmt.Sprintf("d%d-%02d", d, i),
+			Timestamp: day.AddDate(0, 0, d).Add(time.Duration(i+1) * time.Hour),
+			Level:     models.INFO,
+			Message:   "request handled",
+			Service:   service,
+		}
+	}
+	return logs
+}
+
+// newTestStore archives days 0 to 2 and keeps day 2 in the hot store as
+// well, along with day 3.
+func newTestStore(t *testing.T, maxSegments int) (*TieredStore, *Archive) {
+	t.Helper()
+	a, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d := 0; d < 3; d++ {
+		w, err := a.Create(day.AddDate(0, 0, d), day.AddDate(0, 0, d+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, log := range dayLogs(d, 4) {
+			if err := w.Write(log); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := w.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hot := storagetest.NewMemoryStore(append(dayLogs(2, 4), dayLogs(3, 4)...)...)
+	return NewTieredStore(hot, a, maxSegments), a
+}
+
+// corrupt makes reading the segment of day d fail.
+func corrupt(t *testing.T, a *Archive, d int) {
+	t.Helper()
+	for _, seg := range a.Segments() {
+		if seg.From.Equal(day.AddDate(0, 0, d)) {
+			if err := os.WriteFile(filepath.Join(a.dir, seg.File), []byte("not gzip"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no segment for day %d", d)
+}
+
+func ids(logs []models.LogEntry) []string {
+	var ids []string
+	for _, log := range logs {
+		id := log.ID
+		if log.Cold {
+			id += "*"
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestTieredQueryLogs(t *testing.T) {
+	start, end := day, day.AddDate(0, 0, 4)
+	tests := []struct {
+		name  string
+		query models.LogQuery
+		want  []string
+	}{
+		{
+			name:  "no time range reads the hot store only",
+			query: models.LogQuery{Limit: 100},
+			want:  []string{"d3-03", "d3-02", "d3-01", "d3-00", "d2-03", "d2-02", "d2-01", "d2-00"},
+		},
+		{
+			name:  "page across hot and cold logs",
+			query: models.LogQuery{StartTime: &start, Limit: 3, Offset: 3},
+			want:  []string{"d3-00", "d2-03", "d2-02"},
+		},
+		{
+			name:  "archived logs are marked cold",
+			query: models.LogQuery{StartTime: &start, EndTime: &end, Service: []string{"worker"}, Limit: 5},
+			want:  []string{"d3-03", "d3-01", "d2-03", "d2-01", "d1-03*"},
+		},
+		{
+			name:  "ascending",
+			query: models.LogQuery{StartTime: &start, Order: models.OrderAsc, Limit: 3},
+			want:  []string{"d0-00*", "d0-01*", "d0-02*"},
+		},
+		{
+			name: "cursor",
+			query: models.LogQuery{StartTime: &start, Limit: 2,
+				Cursor: &models.Cursor{Timestamp: day.AddDate(0, 0, 1).Add(2 * time.Hour), ID: "d1-01"}},
+			want: []string{"d1-00*", "d0-03*"},
+		},
+		{
+			name: "previous page",
+			query: models.LogQuery{StartTime: &start, Limit: 2,
+				Cursor: &models.Cursor{Timestamp: day.AddDate(0, 0, 1).Add(2 * time.Hour), ID: "d1-01", Before: true}},
+			want: []string{"d1-03*", "d1-02*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestStore(t, 0)
+			logs, err := store.QueryLogs(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(logs); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTieredQueryLogsStopsOnceFull(t *testing.T) {
+	store, a := newTestStore(t, 2)
+	// reading the oldest segment would fail
+	corrupt(t, a, 0)
+
+	start := day
+	logs, err := store.QueryLogs(models.LogQuery{StartTime: &start, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"d3-03", "d3-02", "d3-01", "d3-00", "d2-03", "d2-02", "d2-01", "d2-00", "d1-03*", "d1-02*"}
+	if got := ids(logs); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// without a limit every segment is needed, which is more than allowed
+	_, err = store.QueryLogs(models.LogQuery{StartTime: &start})
+	if !errors.Is(err, storage.ErrInvalidQuery) {
+		t.Errorf("got %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestTieredCountLogs(t *testing.T) {
+	store, a := newTestStore(t, 0)
+	start := day
+	tests := []struct {
+		name  string
+		query models.LogQuery
+		want  int64
+	}{
+		{"hot store only", models.LogQuery{}, 8},
+		{"every log", models.LogQuery{StartTime: &start}, 16},
+		{"service", models.LogQuery{StartTime: &start, Service: []string{"api"}}, 8},
+		{"message", models.LogQuery{StartTime: &start, Expr: "msg:handled"}, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := store.CountLogs(tt.query, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total.Value != tt.want || total.Relation != models.TotalExact {
+				t.Errorf("got %+v, want %d", total, tt.want)
+			}
+		})
+	}
+
+	// counts now come from the manifest or the cache
+	for d := 0; d < 3; d++ {
+		corrupt(t, a, d)
+	}
+	for _, tt := range tests {
+		total, err := store.CountLogs(tt.query, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if total.Value != tt.want {
+			t.Errorf("%s: got %+v from the cache, want %d", tt.name, total, tt.want)
+		}
+	}
+}
+
+func TestTieredCountLogsLowerBound(t *testing.T) {
+	store, _ := newTestStore(t, 1)
+	start := day
+	total, err := store.CountLogs(models.LogQuery{StartTime: &start, Service: []string{"api"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hot store and the newest segment
+	if total.Value != 4 || total.Relation != models.TotalAtLeast {
+		t.Errorf("got %+v, want at least 4", total)
+	}
+}
+
+func TestTieredCountLogsLimit(t *testing.T) {
+	store, _ := newTestStore(t, 0)
+	start := day
+	tests := []struct {
+		limit    int
+		relation string
+	}{
+		{5, models.TotalAtLeast},  // the hot store alone is past the limit
+		{10, models.TotalAtLeast}, // the hot store and a segment are
+		{16, models.TotalExact},
+		{0, models.TotalExact},
+	}
+	for _, tt := range tests {
+		total, err := store.CountLogs(models.LogQuery{StartTime: &start}, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total.Relation != tt.relation || (tt.relation == models.TotalExact && total.Value != 16) ||
+			(tt.relation == models.TotalAtLeast && total.Value < int64(tt.limit)) {
+			t.Errorf("limit %d: got %+v", tt.limit, total)
+		}
+	}
+}
+
+func TestTieredCountLogsLateLogs(t *testing.T) {
+	store, a := newTestStore(t, 0)
+	hot := store.LogStore.(*storagetest.MemoryStore)
+	start := day
+	count := func() int64 {
+		t.Helper()
+		total, err := store.CountLogs(models.LogQuery{StartTime: &start}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return total.Value
+	}
+
+	// a late log in a range still in the hot store is counted there
+	if err := hot.StoreLogs([]models.LogEntry{{ID: "late-2", Timestamp: day.AddDate(0, 0, 2), Service: "api"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 17 {
+		t.Errorf("got %d, want 17", got)
+	}
+
+	// one in a range retention deleted from is counted once archived
+	if err := hot.StoreLogs([]models.LogEntry{{ID: "late-1", Timestamp: day.AddDate(0, 0, 1), Service: "api"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 17 {
+		t.Errorf("got %d before reconciling, want 17", got)
+	}
+	for _, seg := range a.Segments() {
+		if _, _, err := Reconcile(hot, a, seg, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := count(); got != 18 {
+		t.Errorf("got %d, want 18", got)
+	}
+}
@@ -1,32 +1,54 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Queue     QueueConfig
+	Processor ProcessorConfig
+	Retention RetentionConfig
+	Query     QueryConfig
+	Archive   ArchiveConfig
+	Patterns  PatternsConfig
+	Issues    IssuesConfig
 }
 
 type ServerConfig struct {
-	Host     string
-	Port     int
-	GRPCPort int
+	Host            string
+	Port            int
+	GRPCPort        int
+	ShutdownTimeout time.Duration
+	// DrainTimeout bounds flushing the queue into storage on shutdown. It
+	// starts once in-flight requests are done, so a slow client cannot use
+	// it up.
+	DrainTimeout time.Duration
 }
 
 type DatabaseConfig struct {
+	Driver   string
+	Path     string
 	Host     string
 	Port     int
 	Database string
 	Username string
 	Password string
 	SSLMode  string
+
+	AutoMigrate       bool
+	PartitionInterval string
+	PartitionPremake  int
+	MigratePartitions bool
 }
 
 type RedisConfig struct {
@@ -36,20 +58,107 @@ type RedisConfig struct {
 	DB       int
 }
 
-func Load() *Config {
-	return &Config{
+type QueueConfig struct {
+	Backend           string
+	Capacity          int
+	Dir               string
+	SegmentSize       int
+	VisibilityTimeout time.Duration
+}
+
+type ProcessorConfig struct {
+	BatchSize     int
+	Interval      time.Duration
+	CopyThreshold int
+	PipelineFile  string
+	LaneWeights   map[string]int
+	// ParseStackTraces stores the stack traces found in messages in their
+	// metadata.
+	ParseStackTraces bool
+}
+
+type QueryConfig struct {
+	// CountLimit bounds exact counting of query totals, beyond it totals
+	// are estimated.
+	CountLimit int
+	// FacetLimit caps the values returned per facet.
+	FacetLimit int
+	// CorrelationKeys are the tag and metadata keys timelines can follow.
+	CorrelationKeys []string
+	// TraceWindow is how far traces and timelines look from the time they
+	// are asked for when their range is open.
+	TraceWindow time.Duration
+	// ExportTimeout bounds a single export download, which can be resumed
+	// from its cursor afterwards.
+	ExportTimeout time.Duration
+}
+
+type RetentionConfig struct {
+	File      string
+	Interval  time.Duration
+	ChunkSize int
+}
+
+type ArchiveConfig struct {
+	// Dir holds the segment files and their manifest, archiving is disabled
+	// when empty.
+	Dir string
+	// Segment is the time range covered by each file.
+	Segment time.Duration
+	// Delay is how long a range must have been over before it is archived,
+	// so late logs still make it in.
+	Delay     time.Duration
+	Interval  time.Duration
+	BatchSize int
+	// Query makes log queries read the archive too, scanning at most
+	// QueryMaxSegments segments per query.
+	Query            bool
+	QueryMaxSegments int
+}
+
+type PatternsConfig struct {
+	Enabled bool
+	// Similarity is the share of tokens a message must have in common with
+	// a template to join it.
+	Similarity float64
+	// Depth is the depth of the template tree, messages are routed on their
+	// first Depth-2 tokens.
+	Depth       int
+	MaxChildren int
+	// MaxClusters caps the templates kept in memory, messages matching none
+	// of them are left without a template once it is reached.
+	MaxClusters int
+}
+
+type IssuesConfig struct {
+	Enabled bool
+}
+
+// Load reads the configuration from the environment and rejects values the
+// services cannot run with.
+func Load() (*Config, error) {
+	cfg := &Config{
 		Server: ServerConfig{
-			Host:     getEnv("SERVER_HOST", "localhost"),
-			Port:     getEnvInt("SERVER_PORT", 8080),
-			GRPCPort: getEnvInt("GRPC_PORT", 9090),
+			Host:            getEnv("SERVER_HOST", "localhost"),
+			Port:            getEnvInt("SERVER_PORT", 8080),
+			GRPCPort:        getEnvInt("GRPC_PORT", 9090),
+			ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+			DrainTimeout:    getEnvDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second),
 		},
 		Database: DatabaseConfig{
+			Driver:   getEnv("DB_DRIVER", "postgres"),
+			Path:     getEnv("DB_PATH", "./data/socode.db"),
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnvInt("DB_PORT", 5432),
 			Database: getEnv("DB_NAME", "logs"),
 			Username: getEnv("DB_USER", "postgres"),
 			Password: getEnv("DB_PASSWORD", "password"),
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+
+			AutoMigrate:       getEnvBool("DB_AUTO_MIGRATE", true),
+			PartitionInterval: getEnv("DB_PARTITION_INTERVAL", "daily"),
+			PartitionPremake:  getEnvInt("DB_PARTITION_PREMAKE", 3),
+			MigratePartitions: getEnvBool("DB_MIGRATE_PARTITIONS", false),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -57,7 +166,99 @@ func Load() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvInt("REDIS_DB", 0),
 		},
+		Queue: QueueConfig{
+			Backend:           getEnv("QUEUE_BACKEND", "redis"),
+			Capacity:          getEnvInt("QUEUE_CAPACITY", 100000),
+			Dir:               getEnv("QUEUE_DIR", "./data/queue"),
+			SegmentSize:       getEnvInt("QUEUE_SEGMENT_SIZE", 10000),
+			VisibilityTimeout: getEnvDuration("QUEUE_VISIBILITY_TIMEOUT", 5*time.Minute),
+		},
+		Processor: ProcessorConfig{
+			BatchSize:     getEnvInt("PROCESSOR_BATCH_SIZE", 1000),
+			Interval:      getEnvDuration("PROCESSOR_INTERVAL", 5*time.Second),
+			CopyThreshold: getEnvInt("PROCESSOR_COPY_THRESHOLD", 500),
+			PipelineFile:  getEnv("PIPELINE_CONFIG", ""),
+			LaneWeights:   getEnvWeights("PROCESSOR_LANE_WEIGHTS", "critical=6,default=3,debug=1"),
+
+			ParseStackTraces: getEnvBool("PROCESSOR_PARSE_STACKTRACES", true),
+		},
+		Retention: RetentionConfig{
+			File:      getEnv("RETENTION_CONFIG", ""),
+			Interval:  getEnvDuration("RETENTION_INTERVAL", time.Hour),
+			ChunkSize: getEnvInt("RETENTION_CHUNK_SIZE", 10000),
+		},
+		Query: QueryConfig{
+			CountLimit:      getEnvInt("QUERY_COUNT_LIMIT", 10000),
+			FacetLimit:      getEnvInt("QUERY_FACET_LIMIT", 1000),
+			CorrelationKeys: getEnvList("QUERY_CORRELATION_KEYS", "request_id,session_id,user_id"),
+			TraceWindow:     getEnvDuration("QUERY_TRACE_WINDOW", 6*time.Hour),
+			ExportTimeout:   getEnvDuration("QUERY_EXPORT_TIMEOUT", 10*time.Minute),
+		},
+		Archive: ArchiveConfig{
+			Dir:       getEnv("ARCHIVE_DIR", ""),
+			Segment:   getEnvDuration("ARCHIVE_SEGMENT", 24*time.Hour),
+			Delay:     getEnvDuration("ARCHIVE_DELAY", time.Hour),
+			Interval:  getEnvDuration("ARCHIVE_INTERVAL", time.Hour),
+			BatchSize: getEnvInt("ARCHIVE_BATCH_SIZE", 5000),
+
+			Query:            getEnvBool("ARCHIVE_QUERY", true),
+			QueryMaxSegments: getEnvInt("ARCHIVE_QUERY_MAX_SEGMENTS", 31),
+		},
+		Patterns: PatternsConfig{
+			Enabled:     getEnvBool("PATTERNS_ENABLED", true),
+			Similarity:  getEnvFloat("PATTERNS_SIMILARITY", 0.5),
+			Depth:       getEnvInt("PATTERNS_DEPTH", 4),
+			MaxChildren: getEnvInt("PATTERNS_MAX_CHILDREN", 100),
+			MaxClusters: getEnvInt("PATTERNS_MAX_CLUSTERS", 50000),
+		},
+		Issues: IssuesConfig{
+			Enabled: getEnvBool("ISSUES_ENABLED", true),
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Queue.VisibilityTimeout <= 0 {
+		return fmt.Errorf("QUEUE_VISIBILITY_TIMEOUT must be positive, got %s", c.Queue.VisibilityTimeout)
+	}
+	if c.Server.DrainTimeout <= 0 {
+		return fmt.Errorf("SHUTDOWN_DRAIN_TIMEOUT must be positive, got %s", c.Server.DrainTimeout)
+	}
+	if c.Processor.BatchSize <= 0 {
+		return fmt.Errorf("PROCESSOR_BATCH_SIZE must be positive, got %d", c.Processor.BatchSize)
+	}
+	if c.Processor.Interval <= 0 {
+		return fmt.Errorf("PROCESSOR_INTERVAL must be positive, got %s", c.Processor.Interval)
+	}
+	if c.Retention.ChunkSize <= 0 {
+		return fmt.Errorf("RETENTION_CHUNK_SIZE must be positive, got %d", c.Retention.ChunkSize)
 	}
+	if c.Retention.Interval <= 0 {
+		return fmt.Errorf("RETENTION_INTERVAL must be positive, got %s", c.Retention.Interval)
+	}
+	if c.Archive.Segment < time.Second || c.Archive.Segment%time.Second != 0 {
+		return fmt.Errorf("ARCHIVE_SEGMENT must be whole seconds, got %s", c.Archive.Segment)
+	}
+	if c.Archive.BatchSize <= 0 {
+		return fmt.Errorf("ARCHIVE_BATCH_SIZE must be positive, got %d", c.Archive.BatchSize)
+	}
+	if c.Archive.Interval <= 0 {
+		return fmt.Errorf("ARCHIVE_INTERVAL must be positive, got %s", c.Archive.Interval)
+	}
+	if c.Query.CountLimit <= 0 {
+		return fmt.Errorf("QUERY_COUNT_LIMIT must be positive, got %d", c.Query.CountLimit)
+	}
+	if c.Query.FacetLimit <= 0 {
+		return fmt.Errorf("QUERY_FACET_LIMIT must be positive, got %d", c.Query.FacetLimit)
+	}
+	if c.Query.TraceWindow <= 0 {
+		return fmt.Errorf("QUERY_TRACE_WINDOW must be positive, got %s", c.Query.TraceWindow)
+	}
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -81,4 +282,61 @@ func getEnvInt(key string, defaultValue int) int{
 
 	return defaultValue
 	
-}
\ No newline at end of file
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+
+	return defaultValue
+}
+
+// getEnvList parses a comma separated list, skipping empty entries.
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvWeights parses a comma separated list of name=weight pairs, skipping
+// malformed entries.
+func getEnvWeights(key, defaultValue string) map[string]int {
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(getEnv(key, defaultValue), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if w, err := strconv.Atoi(value); err == nil {
+			weights[name] = w
+		}
+	}
+	return weights
+}
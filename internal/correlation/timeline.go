@@ -0,0 +1,74 @@
+// Package correlation follows a request, session or user across services by
+// the value of a correlation key in the tags or metadata of their logs.
+package correlation
+
+import (
+	"github.com/krishnaGauss/SoCode/internal/issues"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+// Expr is a query language filter matching the logs where key, as a tag or
+// a top-level metadata field, equals value. The metadata side has no index unless
+// one was created on the key, so the stores read the whole time range
+// searched.
+func Expr(key, value string) string {
+	quoted := querylang.Quote(value)
+	return "tags." + key + ":" + quoted + " OR metadata." + key + ":" + quoted
+}
+
+// Build merges logs, which are in time order, into the timeline of key and
+// value.
+func Build(key, value string, logs []models.LogEntry) models.Timeline {
+	t := models.Timeline{
+		Key:      key,
+		Value:    value,
+		Logs:     models.MarkTransitions(logs),
+		Services: []models.ServiceSpan{},
+		Gaps:     []models.TimelineGap{},
+	}
+	if len(logs) == 0 {
+		return t
+	}
+
+	start, end := logs[0].Timestamp, logs[len(logs)-1].Timestamp
+	t.Start, t.End = &start, &end
+	t.DurationMs = models.Milliseconds(end.Sub(start))
+
+	spans := make(map[string]int)
+	for i, log := range logs {
+		n, ok := spans[log.Service]
+		if !ok {
+			n = len(t.Services)
+			spans[log.Service] = n
+			t.Services = append(t.Services, models.ServiceSpan{Service: log.Service, Start: log.Timestamp})
+		}
+		span := &t.Services[n]
+		span.End = log.Timestamp
+		span.Count++
+
+		if issues.Tracked(log) {
+			span.Errors++
+			if t.FirstError == nil {
+				t.FirstError = &logs[i]
+				t.Logs[i].FirstError = true
+			}
+		}
+
+		if t.Logs[i].Transition {
+			prev := logs[i-1]
+			t.Gaps = append(t.Gaps, models.TimelineGap{
+				From:       prev.Service,
+				To:         log.Service,
+				Start:      prev.Timestamp,
+				End:        log.Timestamp,
+				DurationMs: models.Milliseconds(log.Timestamp.Sub(prev.Timestamp)),
+			})
+		}
+	}
+	for i := range t.Services {
+		span := &t.Services[i]
+		span.DurationMs = models.Milliseconds(span.End.Sub(span.Start))
+	}
+	return t
+}
@@ -0,0 +1,182 @@
+package correlation
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// entry is a log of service at ms milliseconds after start.
+type entry struct {
+	service string
+	ms      int
+	level   models.LogLevel
+}
+
+func timelineLogs(entries []entry) []models.LogEntry {
+	logs := make([]models.LogEntry, len(entries))
+	for i, e := range entries {
+		level := e.level
+		if level == "" {
+			level = models.INFO
+		}
+		logs[i] = models.LogEntry{
+			ID:        fmt.Sprintf("log-%d", i),
+			Timestamp: start.Add(time.Duration(e.ms) * time.Millisecond),
+			Level:     level,
+			Service:   e.service,
+		}
+	}
+	return logs
+}
+
+// span is a models.ServiceSpan without its times, which are checked through
+// its duration.
+type span struct {
+	service    string
+	durationMs float64
+	count      int
+	errors     int
+}
+
+type gap struct {
+	from, to   string
+	durationMs float64
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name       string
+		logs       []entry
+		durationMs float64
+		spans      []span
+		gaps       []gap
+		firstError int // index of the first error log, -1 for none
+	}{
+		{
+			name:       "no logs",
+			firstError: -1,
+		},
+		{
+			name:       "one log",
+			logs:       []entry{{"api", 0, models.ERROR}},
+			spans:      []span{{"api", 0, 1, 1}},
+			firstError: 0,
+		},
+		{
+			name: "services in turn",
+			logs: []entry{
+				{"frontend", 0, ""}, {"frontend", 5, ""},
+				{"api", 12, ""}, {"api", 20, models.ERROR},
+				{"db", 21, "fatal"},
+			},
+			durationMs: 21,
+			spans:      []span{{"frontend", 5, 2, 0}, {"api", 8, 2, 1}, {"db", 0, 1, 1}},
+			gaps:       []gap{{"frontend", "api", 7}, {"api", "db", 1}},
+			firstError: 3,
+		},
+		{
+			// a service coming back spans from its first to its last log,
+			// with a gap at every change
+			name: "interleaved services",
+			logs: []entry{
+				{"api", 0, ""}, {"auth", 3, ""}, {"api", 4, ""},
+				{"billing", 10, ""}, {"api", 30, "error"},
+			},
+			durationMs: 30,
+			spans:      []span{{"api", 30, 3, 1}, {"auth", 0, 1, 0}, {"billing", 0, 1, 0}},
+			gaps: []gap{
+				{"api", "auth", 3}, {"auth", "api", 1}, {"api", "billing", 6}, {"billing", "api", 20},
+			},
+			firstError: 4,
+		},
+		{
+			// logs at the same time are kept in the order given
+			name: "equal timestamps",
+			logs: []entry{
+				{"api", 0, ""}, {"worker", 0, models.ERROR}, {"api", 0, models.ERROR}, {"worker", 2, ""},
+			},
+			durationMs: 2,
+			spans:      []span{{"api", 0, 2, 1}, {"worker", 2, 2, 1}},
+			gaps:       []gap{{"api", "worker", 0}, {"worker", "api", 0}, {"api", "worker", 2}},
+			firstError: 1,
+		},
+		{
+			name:       "warnings are not errors",
+			logs:       []entry{{"api", 0, models.WARN}, {"api", 1, models.INFO}},
+			durationMs: 1,
+			spans:      []span{{"api", 1, 2, 0}},
+			firstError: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := timelineLogs(tt.logs)
+			timeline := Build("request_id", "req-1", logs)
+
+			if len(logs) == 0 {
+				if timeline.Start != nil || timeline.End != nil || len(timeline.Logs) != 0 {
+					t.Errorf("got %+v for no logs", timeline)
+				}
+			} else if !timeline.Start.Equal(logs[0].Timestamp) || !timeline.End.Equal(logs[len(logs)-1].Timestamp) {
+				t.Errorf("got %s to %s", timeline.Start, timeline.End)
+			}
+			if timeline.DurationMs != tt.durationMs {
+				t.Errorf("got duration %vms, want %vms", timeline.DurationMs, tt.durationMs)
+			}
+
+			var spans []span
+			for _, s := range timeline.Services {
+				if got := models.Milliseconds(s.End.Sub(s.Start)); got != s.DurationMs {
+					t.Errorf("%s spans %vms, reports %vms", s.Service, got, s.DurationMs)
+				}
+				spans = append(spans, span{s.Service, s.DurationMs, s.Count, s.Errors})
+			}
+			if !slices.Equal(spans, tt.spans) {
+				t.Errorf("got spans %v, want %v", spans, tt.spans)
+			}
+
+			var gaps []gap
+			for _, g := range timeline.Gaps {
+				gaps = append(gaps, gap{g.From, g.To, g.DurationMs})
+			}
+			if !slices.Equal(gaps, tt.gaps) {
+				t.Errorf("got gaps %v, want %v", gaps, tt.gaps)
+			}
+
+			var flagged []int
+			for i, log := range timeline.Logs {
+				if log.FirstError {
+					flagged = append(flagged, i)
+				}
+			}
+			switch {
+			case tt.firstError < 0:
+				if timeline.FirstError != nil || flagged != nil {
+					t.Errorf("got first error %v, flagged %v", timeline.FirstError, flagged)
+				}
+			case timeline.FirstError == nil || timeline.FirstError.ID != logs[tt.firstError].ID:
+				t.Errorf("got first error %v, want %s", timeline.FirstError, logs[tt.firstError].ID)
+			case !slices.Equal(flagged, []int{tt.firstError}):
+				t.Errorf("flagged %v, want [%d]", flagged, tt.firstError)
+			}
+		})
+	}
+}
+
+func TestExpr(t *testing.T) {
+	got := Expr("request_id", `req "1"`)
+	want := `tags.request_id:"req \"1\"" OR metadata.request_id:"req \"1\""`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := querylang.ParseFilter(got); err != nil {
+		t.Errorf("%s: %v", got, err)
+	}
+}
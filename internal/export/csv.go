@@ -0,0 +1,64 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	row     []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	cw := &csvWriter{w: csv.NewWriter(w), columns: columns, row: make([]string, len(columns))}
+	if err := cw.w.Write(columns); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (w *csvWriter) Write(logs []models.LogEntry) error {
+	for _, log := range logs {
+		for i, column := range w.columns {
+			w.row[i] = csvValue(log, column)
+		}
+		if err := w.w.Write(w.row); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// csvValue returns the text of column for log, empty where it has none.
+func csvValue(log models.LogEntry, column string) string {
+	switch column {
+	case "timestamp":
+		return log.Timestamp.UTC().Format(time.RFC3339Nano)
+	case "tags":
+		if len(log.Tags) == 0 {
+			return ""
+		}
+		tags, _ := json.Marshal(log.Tags)
+		return string(tags)
+	case "metadata":
+		return string(log.Metadata)
+	}
+	value, _ := storage.FieldValue(log, column)
+	return value
+}
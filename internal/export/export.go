@@ -0,0 +1,159 @@
+// Package export streams logs matching a query in bulk, as newline
+// delimited JSON, CSV or OTLP JSON.
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+	FormatOTLP   = "otlp"
+)
+
+// BatchSize is the number of logs read from the store at a time.
+const BatchSize = 5000
+
+// DefaultColumns are the CSV columns written when none are chosen.
+var DefaultColumns = []string{"timestamp", "id", "level", "service", "host", "source", "message"}
+
+// Writer encodes batches of logs in an export format.
+type Writer interface {
+	Write(logs []models.LogEntry) error
+	// Close writes anything the format holds back, without closing the
+	// underlying writer.
+	Close() error
+}
+
+// NewWriter returns a writer of format to w. Columns only apply to CSV.
+func NewWriter(format string, w io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case "", FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatOTLP:
+		return newOTLPWriter(w), nil
+	}
+	return nil, fmt.Errorf("format must be %s, %s or %s, got %q", FormatNDJSON, FormatCSV, FormatOTLP, format)
+}
+
+// ContentType is the media type of format. OTLP exports are newline
+// delimited too, each line holding one ExportLogsServiceRequest, so they are
+// not a single application/json document.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Extension is the file extension of format.
+func Extension(format string) string {
+	switch format {
+	case FormatCSV:
+		return "csv"
+	case FormatOTLP:
+		return "otlp.jsonl"
+	}
+	return "ndjson"
+}
+
+// Run writes the logs matching query to w, reading them in batches that
+// resume from the cursor of the last log written, so a query's results are
+// never held in memory at once. query.Cursor, when set, is where to start and
+// query.Limit, when set, caps the logs written. flush is called after every
+// batch. Run checks ctx between batches, and passes it to stores that take
+// one, and once it is done stops with its error. On any error it returns the
+// cursor to resume from, that of the last complete batch, or query.Cursor
+// when none was.
+func Run(ctx context.Context, store storage.LogStore, query models.LogQuery, w Writer, flush func() error) (*models.Cursor, error) {
+	if err := Validate(query); err != nil {
+		return nil, err
+	}
+
+	remaining := query.Limit
+	for {
+		if err := ctx.Err(); err != nil {
+			return query.Cursor, err
+		}
+
+		batch := query
+		batch.Limit = BatchSize
+		if remaining > 0 {
+			batch.Limit = min(BatchSize, remaining)
+		}
+		logs, err := storage.QueryLogsContext(ctx, store, batch)
+		if err != nil {
+			return query.Cursor, err
+		}
+		if len(logs) > 0 {
+			if err := w.Write(logs); err != nil {
+				return query.Cursor, err
+			}
+			if err := flush(); err != nil {
+				return query.Cursor, err
+			}
+			last := logs[len(logs)-1]
+			query.Cursor = &models.Cursor{Timestamp: last.Timestamp, ID: last.ID}
+		}
+
+		if remaining > 0 {
+			remaining -= len(logs)
+			if remaining <= 0 {
+				return nil, nil
+			}
+		}
+		if len(logs) < batch.Limit {
+			return nil, nil
+		}
+	}
+}
+
+// Validate rejects queries that cannot be exported, before anything is
+// written. Exports need a time range, so that every batch reads only the
+// part of the store it covers.
+func Validate(query models.LogQuery) error {
+	if err := storage.ValidateQuery(&query); err != nil {
+		return err
+	}
+	if query.StartTime == nil || query.EndTime == nil {
+		return fmt.Errorf("%w: exports need a start_time and an end_time", storage.ErrInvalidQuery)
+	}
+	if query.Sort == models.SortRelevance || query.Offset > 0 {
+		return fmt.Errorf("%w: exports are ordered by time and resume from a cursor, not an offset", storage.ErrInvalidQuery)
+	}
+	if query.Cursor != nil && query.Cursor.Before {
+		return fmt.Errorf("%w: exports resume forward from a cursor", storage.ErrInvalidQuery)
+	}
+	return nil
+}
+
+// ParseColumns reads a comma separated list of CSV columns: timestamp, the
+// fields filters take, or tags and metadata for the whole of them as JSON.
+func ParseColumns(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultColumns, nil
+	}
+	var columns []string
+	for _, column := range strings.Split(s, ",") {
+		column = strings.TrimSpace(column)
+		switch column {
+		case "timestamp", "tags", "metadata":
+		default:
+			f := models.FieldFilter{Field: column, Op: models.FilterEquals}
+			if err := f.Validate(); err != nil {
+				return nil, fmt.Errorf("unknown column %q", column)
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
@@ -0,0 +1,341 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+	"github.com/krishnaGauss/SoCode/internal/storage/storagetest"
+)
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// testLogs returns n logs a millisecond apart from start.
+func testLogs(n int) []models.LogEntry {
+	logs := make([]models.LogEntry, n)
+	for i := range logs {
+		logs[i] = models.LogEntry{
+			ID:        fmt.Sprintf("log-%05d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+			Level:     models.INFO,
+			Message:   "request handled",
+			Service:   "api",
+		}
+	}
+	return logs
+}
+
+func timeRange() models.LogQuery {
+	end := start.Add(time.Hour)
+	return models.LogQuery{StartTime: &start, EndTime: &end, Order: models.OrderAsc}
+}
+
+// collect is a Writer keeping the IDs written.
+type collect struct {
+	ids     []string
+	batches int
+	err     error
+}
+
+func (c *collect) Write(logs []models.LogEntry) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.batches++
+	for _, log := range logs {
+		c.ids = append(c.ids, log.ID)
+	}
+	return nil
+}
+
+func (c *collect) Close() error {
+	return nil
+}
+
+func noFlush() error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	logs := testLogs(2*BatchSize + 10)
+	w := &collect{}
+	cursor, err := Run(context.Background(), storagetest.NewMemoryStore(logs...), timeRange(), w, noFlush)
+	if err != nil || cursor != nil {
+		t.Fatalf("got %v, %v", cursor, err)
+	}
+	if len(w.ids) != len(logs) || w.batches != 3 {
+		t.Fatalf("got %d logs in %d batches", len(w.ids), w.batches)
+	}
+	if !slices.IsSorted(w.ids) || w.ids[0] != "log-00000" {
+		t.Errorf("logs are out of order")
+	}
+}
+
+func TestRunSameTimestamp(t *testing.T) {
+	// logs sharing a timestamp across a batch boundary are told apart by ID
+	logs := testLogs(BatchSize + 10)
+	for i := range logs {
+		logs[i].Timestamp = start
+	}
+	slices.Reverse(logs)
+	w := &collect{}
+	if _, err := Run(context.Background(), storagetest.NewMemoryStore(logs...), timeRange(), w, noFlush); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.ids) != len(logs) || !slices.IsSorted(w.ids) {
+		t.Errorf("got %d logs, sorted %v", len(w.ids), slices.IsSorted(w.ids))
+	}
+}
+
+func TestRunLimitAndCursor(t *testing.T) {
+	logs := testLogs(20)
+	query := timeRange()
+	query.Limit = 3
+	query.Cursor = &models.Cursor{Timestamp: logs[4].Timestamp, ID: logs[4].ID}
+
+	w := &collect{}
+	if _, err := Run(context.Background(), storagetest.NewMemoryStore(logs...), query, w, noFlush); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"log-00005", "log-00006", "log-00007"}; !slices.Equal(w.ids, want) {
+		t.Errorf("got %v, want %v", w.ids, want)
+	}
+}
+
+func TestRunStopsWhenDone(t *testing.T) {
+	logs := testLogs(BatchSize + 10)
+	store := storagetest.NewMemoryStore(logs...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the client goes away after the first batch
+	w := &collect{}
+	cursor, err := Run(ctx, store, timeRange(), w, func() error { cancel(); return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	last := logs[BatchSize-1]
+	if cursor == nil || cursor.ID != last.ID || !cursor.Timestamp.Equal(last.Timestamp) {
+		t.Errorf("got cursor %+v, want the last log of the first batch", cursor)
+	}
+	if n := store.Queries(); n != 1 {
+		t.Errorf("ran %d queries", n)
+	}
+
+	// a failed write resumes from where the export started
+	query := timeRange()
+	query.Cursor = cursor
+	failed := errors.New("disk full")
+	if got, err := Run(context.Background(), store, query, &collect{err: failed}, noFlush); !errors.Is(err, failed) || got != cursor {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	end := start.Add(time.Hour)
+	tests := []struct {
+		name  string
+		query models.LogQuery
+	}{
+		{"no time range", models.LogQuery{}},
+		{"no end", models.LogQuery{StartTime: &start}},
+		{"no start", models.LogQuery{EndTime: &end}},
+		{"offset", models.LogQuery{StartTime: &start, EndTime: &end, Offset: 10}},
+		{"relevance", models.LogQuery{StartTime: &start, EndTime: &end, Search: "x", Sort: models.SortRelevance}},
+		{"backward cursor", models.LogQuery{StartTime: &start, EndTime: &end, Cursor: &models.Cursor{Timestamp: start, ID: "x", Before: true}}},
+		{"invalid expression", models.LogQuery{StartTime: &start, EndTime: &end, Expr: "sevice:api"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.query); !errors.Is(err, storage.ErrInvalidQuery) {
+				t.Errorf("got %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+	if err := Validate(timeRange()); err != nil {
+		t.Errorf("got %v for a valid query", err)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns(" timestamp, id,tags.env ,metadata.user.id,tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"timestamp", "id", "tags.env", "metadata.user.id", "tags"}; !slices.Equal(columns, want) {
+		t.Errorf("got %v, want %v", columns, want)
+	}
+	if columns, _ := ParseColumns(""); !slices.Equal(columns, DefaultColumns) {
+		t.Errorf("got %v, want the default columns", columns)
+	}
+	if _, err := ParseColumns("id,colour"); err == nil {
+		t.Error("got no error for an unknown column")
+	}
+}
+
+func TestNewWriterRejectsUnknownFormats(t *testing.T) {
+	if _, err := NewWriter("xml", &bytes.Buffer{}, nil); err == nil {
+		t.Error("got no error")
+	}
+}
+
+func exampleLog() models.LogEntry {
+	return models.LogEntry{
+		ID:        "log-1",
+		Timestamp: start.Add(1500 * time.Millisecond),
+		Level:     models.ERROR,
+		Message:   "payment failed, \"card declined\"",
+		Source:    "app.log",
+		Service:   "payments",
+		Host:      "web-1",
+		Tags:      map[string]string{"env": "prod", "region": "eu"},
+		Metadata:  []byte(`{"user":{"id":42}}`),
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:    "00f067aa0ba902b7",
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatNDJSON, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := []models.LogEntry{exampleLog(), testLogs(1)[0]}
+	if err := w.Write(logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(logs) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(logs))
+	}
+	for i, line := range lines {
+		var got models.LogEntry
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != logs[i].ID || got.Message != logs[i].Message || !got.Timestamp.Equal(logs[i].Timestamp) {
+			t.Errorf("line %d: got %+v", i, got)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, []string{"timestamp", "id", "message", "tags", "tags.env", "tags.team", "metadata", "metadata.user.id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]models.LogEntry{exampleLog()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "timestamp,id,message,tags,tags.env,tags.team,metadata,metadata.user.id\n" +
+		`2024-05-01T12:00:01.5Z,log-1,"payment failed, ""card declined""","{""env"":""prod"",""region"":""eu""}",prod,,"{""user"":{""id"":42}}",42` + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestCSVWriterDefaultColumns(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an empty export still has its header
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), strings.Join(DefaultColumns, ",")+"\n"; got != want {
+		t.Errorf("got header %q, want %q", got, want)
+	}
+}
+
+func TestOTLPWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatOTLP, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := exampleLog()
+	other.ID, other.Host, other.Level, other.Tags, other.Metadata = "log-2", "web-2", "warn", nil, nil
+	// one request per batch
+	if err := w.Write([]models.LogEntry{exampleLog(), other, testLogs(1)[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(testLogs(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	var requests []otlpRequest
+	for scanner.Scan() {
+		var request otlpRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			t.Fatalf("line %d: %v", len(requests)+1, err)
+		}
+		requests = append(requests, request)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+
+	// a resource per service and host, in order of appearance
+	resources := requests[0].ResourceLogs
+	var names []string
+	for _, r := range resources {
+		names = append(names, fmt.Sprint(r.Resource.Attributes))
+	}
+	want := []string{
+		"[{service.name {payments}} {host.name {web-1}}]",
+		"[{service.name {payments}} {host.name {web-2}}]",
+		"[{service.name {api}}]",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("got resources %v, want %v", names, want)
+	}
+
+	record := resources[0].ScopeLogs[0].LogRecords[0]
+	if record.TimeUnixNano != "1714564801500000000" || record.SeverityNumber != 17 || record.SeverityText != "ERROR" ||
+		record.Body.StringValue != exampleLog().Message || record.TraceID != exampleLog().TraceID || record.SpanID != exampleLog().SpanID {
+		t.Errorf("got record %+v", record)
+	}
+	wantAttributes := "[{log.id {log-1}} {log.source {app.log}} {log.metadata {{\"user\":{\"id\":42}}}} {env {prod}} {region {eu}}]"
+	if got := fmt.Sprint(record.Attributes); got != wantAttributes {
+		t.Errorf("got attributes %s, want %s", got, wantAttributes)
+	}
+	if warn := resources[1].ScopeLogs[0].LogRecords[0]; warn.SeverityNumber != 13 || warn.SeverityText != "warn" {
+		t.Errorf("got severity %d %q for warn", warn.SeverityNumber, warn.SeverityText)
+	}
+	if got := len(requests[1].ResourceLogs[0].ScopeLogs[0].LogRecords); got != 2 {
+		t.Errorf("got %d records in the second request", got)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	for format, want := range map[string]string{
+		"": "application/x-ndjson", FormatNDJSON: "application/x-ndjson",
+		FormatOTLP: "application/x-ndjson", FormatCSV: "text/csv; charset=utf-8",
+	} {
+		if got := ContentType(format); got != want {
+			t.Errorf("ContentType(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
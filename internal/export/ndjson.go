@@ -0,0 +1,29 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *ndjsonWriter) Write(logs []models.LogEntry) error {
+	for _, log := range logs {
+		if err := w.enc.Encode(log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// The OTLP JSON encoding of ExportLogsServiceRequest, as written by the
+// OpenTelemetry Collector's file exporter: one request per line.
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber,omitempty"`
+	SeverityText   string          `json:"severityText,omitempty"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+	TraceID        string          `json:"traceId,omitempty"`
+	SpanID         string          `json:"spanId,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpScopeName names the instrumentation scope of exported logs.
+const otlpScopeName = "socode"
+
+// otlpSeverity maps levels to OTLP severity numbers.
+var otlpSeverity = map[models.LogLevel]int{
+	models.DEBUG: 5,
+	models.INFO:  9,
+	models.WARN:  13,
+	models.ERROR: 17,
+	models.FATAL: 21,
+}
+
+type otlpWriter struct {
+	enc *json.Encoder
+}
+
+func newOTLPWriter(w io.Writer) *otlpWriter {
+	return &otlpWriter{enc: json.NewEncoder(w)}
+}
+
+// Write encodes logs as one request, with a resource per service and host.
+func (w *otlpWriter) Write(logs []models.LogEntry) error {
+	var request otlpRequest
+	resources := make(map[[2]string]int)
+	for _, log := range logs {
+		key := [2]string{log.Service, log.Host}
+		i, ok := resources[key]
+		if !ok {
+			i = len(request.ResourceLogs)
+			resources[key] = i
+			request.ResourceLogs = append(request.ResourceLogs, otlpResourceLogs{
+				Resource: otlpResource{Attributes: otlpAttributes(
+					"service.name", log.Service,
+					"host.name", log.Host,
+				)},
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}}},
+			})
+		}
+		scope := &request.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, otlpRecord(log))
+	}
+	return w.enc.Encode(request)
+}
+
+func (w *otlpWriter) Close() error {
+	return nil
+}
+
+func otlpRecord(log models.LogEntry) otlpLogRecord {
+	attributes := otlpAttributes(
+		"log.id", log.ID,
+		"log.source", log.Source,
+		"log.template_id", log.TemplateID,
+		"log.fingerprint", log.Fingerprint,
+		"log.parent_span_id", log.ParentSpanID,
+		"log.metadata", string(log.Metadata),
+	)
+	for _, key := range slices.Sorted(maps.Keys(log.Tags)) {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: log.Tags[key]}})
+	}
+
+	return otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(log.Timestamp.UnixNano(), 10),
+		SeverityNumber: otlpSeverity[models.LogLevel(strings.ToUpper(string(log.Level)))],
+		SeverityText:   string(log.Level),
+		Body:           otlpValue{StringValue: log.Message},
+		Attributes:     attributes,
+		TraceID:        log.TraceID,
+		SpanID:         log.SpanID,
+	}
+}
+
+// otlpAttributes turns key, value pairs into attributes, skipping empty
+// values.
+func otlpAttributes(pairs ...string) []otlpAttribute {
+	var attributes []otlpAttribute
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			attributes = append(attributes, otlpAttribute{Key: pairs[i], Value: otlpValue{StringValue: pairs[i+1]}})
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,76 @@
+// Package issues groups ERROR and FATAL logs into issues by fingerprint.
+package issues
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/patterns"
+	"github.com/krishnaGauss/SoCode/internal/stacktrace"
+)
+
+// maxFrames bounds the stack lines that count towards a fingerprint, the
+// outermost frames rarely tell errors apart.
+const maxFrames = 50
+
+// maxTitle bounds the length of issue titles, in runes.
+const maxTitle = 200
+
+// Tracked reports whether log is grouped into an issue.
+func Tracked(log models.LogEntry) bool {
+	level := models.LogLevel(strings.ToUpper(string(log.Level)))
+	return level == models.ERROR || level == models.FATAL
+}
+
+// Fingerprint identifies the issue of log from its service and, when its
+// stack trace was parsed into the metadata, the types of its exceptions and
+// the functions of their frames. Otherwise the first line of the message and
+// the lines after it, taken as stack frames, are used, with numbers,
+// addresses and other variable values masked so the same error with
+// different IDs or line offsets gets the same fingerprint. Logs without a
+// trace keep the fingerprint they had before traces were parsed, so their
+// issues carry on.
+func Fingerprint(log models.LogEntry) string {
+	h := sha1.New()
+	h.Write([]byte(log.Service))
+
+	if trace, ok := stacktrace.FromMetadata(log.Metadata); ok {
+		// line numbers are left out, they change with every release
+		for _, e := range append([]stacktrace.Exception{trace.Exception}, trace.Causes...) {
+			h.Write([]byte{0})
+			h.Write([]byte(e.Type))
+			for _, f := range e.Frames {
+				h.Write([]byte{0})
+				h.Write([]byte(f.Module + " " + f.Function))
+			}
+		}
+		return hex.EncodeToString(h.Sum(nil)[:8])
+	}
+
+	first, rest, _ := strings.Cut(log.Message, "\n")
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(patterns.Tokenize(first), " ")))
+	for i, line := range strings.Split(rest, "\n") {
+		if i == maxFrames {
+			break
+		}
+		if tokens := patterns.Tokenize(line); len(tokens) > 0 {
+			h.Write([]byte{0})
+			h.Write([]byte(strings.Join(tokens, " ")))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Title is the first line of the message of log, shortened.
+func Title(log models.LogEntry) string {
+	title, _, _ := strings.Cut(log.Message, "\n")
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > maxTitle {
+		title = string([]rune(title)[:maxTitle-3]) + "..."
+	}
+	return title
+}
@@ -0,0 +1,90 @@
+package issues
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/stacktrace"
+)
+
+func TestFingerprint(t *testing.T) {
+	log := func(service, message string) models.LogEntry {
+		return models.LogEntry{Service: service, Level: models.ERROR, Message: message}
+	}
+	traced := func(message, typ, function string, line int) models.LogEntry {
+		trace := &stacktrace.Trace{Language: "java", Exception: stacktrace.Exception{
+			Type: typ, Frames: []stacktrace.Frame{{Function: function, Module: "com.acme", Line: line}},
+		}}
+		return models.LogEntry{Service: "api", Level: models.ERROR, Message: message, Metadata: stacktrace.AddToMetadata(nil, trace)}
+	}
+	tests := []struct {
+		name string
+		a, b models.LogEntry
+		same bool
+	}{
+		{"identical", log("api", "payment failed"), log("api", "payment failed"), true},
+		{"numbers are masked", log("api", "user 42 not found"), log("api", "user 7 not found"), true},
+		{"ids are masked", log("api", "order 3f2b1c9e-8a4d-4e5f-9b6a-1c2d3e4f5a6b failed"), log("api", "order 0c1d2e3f-8a4d-4e5f-9b6a-1c2d3e4f5a6b failed"), true},
+		{"whitespace", log("api", "payment  failed "), log("api", "payment failed"), true},
+		{"other service", log("api", "payment failed"), log("worker", "payment failed"), false},
+		{"other message", log("api", "payment failed"), log("api", "payment declined"), false},
+		{"frame offsets are masked", log("api", "panic: boom\ngoroutine 7 [running]:\nmain.run()"), log("api", "panic: boom\ngoroutine 12 [running]:\nmain.run()"), true},
+		{"other frames", log("api", "panic: boom\nmain.run()"), log("api", "panic: boom\nmain.serve()"), false},
+		{"blank lines", log("api", "panic: boom\n\nmain.run()"), log("api", "panic: boom\nmain.run()"), true},
+		{"trace line numbers are ignored", traced("card declined", "IOException", "com.acme.Pay.run", 42), traced("card declined", "IOException", "com.acme.Pay.run", 57), true},
+		{"trace messages are ignored", traced("card 1 declined", "IOException", "com.acme.Pay.run", 42), traced("timeout", "IOException", "com.acme.Pay.run", 42), true},
+		{"other exception", traced("x", "IOException", "com.acme.Pay.run", 42), traced("x", "TimeoutException", "com.acme.Pay.run", 42), false},
+		{"other trace frames", traced("x", "IOException", "com.acme.Pay.run", 42), traced("x", "IOException", "com.acme.Pay.retry", 42), false},
+		{"frames past the limit", log("api", "boom"+strings.Repeat("\nframe", maxFrames)+"\nouter"), log("api", "boom"+strings.Repeat("\nframe", maxFrames)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Fingerprint(tt.a), Fingerprint(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("got %s and %s, want same=%v", a, b, tt.same)
+			}
+		})
+	}
+}
+
+// Stored issues are keyed by fingerprint, so changing how it is computed
+// splits every existing issue in two.
+func TestFingerprintIsStable(t *testing.T) {
+	log := models.LogEntry{Service: "api", Message: "user 42 not found\n  at handler.go:12"}
+	if got, want := Fingerprint(log), "0a9edbfb7a543c4c"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	// metadata without a parsed trace does not count
+	log.Metadata = []byte(`{"user":"u42"}`)
+	if got, want := Fingerprint(log), "0a9edbfb7a543c4c"; got != want {
+		t.Errorf("got %s with metadata, want %s", got, want)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"payment failed", "payment failed"},
+		{"  panic: boom  \nmain.run()", "panic: boom"},
+		{strings.Repeat("é", maxTitle+1), strings.Repeat("é", maxTitle-3) + "..."},
+		{strings.Repeat("a", maxTitle), strings.Repeat("a", maxTitle)},
+	}
+	for _, tt := range tests {
+		if got := Title(models.LogEntry{Message: tt.message}); got != tt.want {
+			t.Errorf("Title(%.20q) = %.20q, want %.20q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestTracked(t *testing.T) {
+	for level, want := range map[models.LogLevel]bool{
+		models.ERROR: true, models.FATAL: true, "error": true, models.WARN: false, models.INFO: false,
+	} {
+		if got := Tracked(models.LogEntry{Level: level}); got != want {
+			t.Errorf("Tracked(%s) = %v, want %v", level, got, want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// lockKey is the advisory lock held while migrations run, so concurrent
+// instances starting together apply each migration once.
+const lockKey = 0x50c0de02
+
+// Migration is one schema change, read from a pair of files named
+// NNNN_description.up.sql and NNNN_description.down.sql.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+	// Destructive is set when the down file drops a table or column or
+	// deletes rows, so reverting the migration loses data.
+	Destructive bool
+}
+
+// Status describes a migration as known to the files, the database or both.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the up file changed after the migration was
+	// applied, and Missing when the database has a migration this build does
+	// not know about.
+	Modified bool
+	Missing  bool
+}
+
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var destructiveRe = regexp.MustCompile(`(?i)\b(DROP\s+(TABLE|COLUMN|SCHEMA)|TRUNCATE|DELETE\s+FROM)\b`)
+
+// ErrDataLoss is returned by Down when a migration to revert is destructive
+// and data loss was not allowed.
+var ErrDataLoss = errors.New("reverting would lose data")
+
+// Load reads the migrations in the root of fsys, ordered by version. Every
+// migration needs an up file; the down file is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names, %q and %q", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(data)
+			sum := sha256.Sum256(data)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(data)
+			migration.Destructive = destructiveRe.Match(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Migrator applies migrations to a PostgreSQL database and records them in
+// the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied. It refuses to run when an applied
+// migration was modified since.
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(conn, migration, migration.Up, true); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("applied migration", slog.Int64("version", migration.Version), slog.String("name", migration.Name))
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the steps most recently applied migrations and returns the
+// ones it reverted. Unless allowDataLoss is set, it reverts nothing when one
+// of them is destructive.
+func (m *Migrator) Down(steps int, allowDataLoss bool) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		revert, err := m.downPlan(applied, steps, allowDataLoss)
+		if err != nil {
+			return err
+		}
+		for _, migration := range revert {
+			if err := m.apply(conn, migration, migration.Down, false); err != nil {
+				return fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("reverted migration", slog.Int64("version", migration.Version), slog.String("name", migration.Name))
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// downPlan returns the steps most recently applied migrations, newest first,
+// checking they can all be reverted before any is.
+func (m *Migrator) downPlan(applied map[int64]appliedMigration, steps int, allowDataLoss bool) ([]Migration, error) {
+	var revert []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(revert) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no down file", migration.Version, migration.Name)
+		}
+		if migration.Destructive && !allowDataLoss {
+			return nil, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, ErrDataLoss)
+		}
+		revert = append(revert, migration)
+	}
+	return revert, nil
+}
+
+// Status lists every known migration, applied or not, plus applied
+// migrations missing from this build, ordered by version.
+func (m *Migrator) Status() ([]Status, error) {
+	conn, err := m.db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(conn); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &a.appliedAt
+			status.Modified = a.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	for version, a := range applied {
+		if !known[version] {
+			statuses = append(statuses, Status{
+				Version:   version,
+				Name:      a.name,
+				Applied:   true,
+				AppliedAt: &a.appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return statuses, nil
+}
+
+// Pending returns the number of migrations not applied yet.
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn on a single connection holding the session advisory lock.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if err := ensureTable(conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureTable(conn *sql.Conn) error {
+	_, err := conn.ExecContext(context.Background(), `
+		CREATE TABLE IF NOT EXISTS schema_migrations(
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify rejects a database whose applied migrations no longer match their
+// files. Applied migrations unknown to this build are left alone, as a newer
+// instance may have applied them during a rolling deploy.
+func (m *Migrator) verify(applied map[int64]appliedMigration) error {
+	for _, migration := range m.migrations {
+		a, ok := applied[migration.Version]
+		if ok && a.checksum != migration.Checksum {
+			return fmt.Errorf("migration %d (%s) was modified after it was applied", migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(conn *sql.Conn, migration Migration, script string, up bool) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, migration.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,353 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func versions(migrations []Migration) []int64 {
+	out := []int64{}
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_tenth.up.sql":    file("CREATE TABLE ten (id INT);"),
+		"0010_tenth.down.sql":  file("DROP TABLE ten;"),
+		"0002_second.up.sql":   file("CREATE INDEX two ON logs(id);"),
+		"0002_second.down.sql": file("DROP INDEX two;"),
+		"0001_first.up.sql":    file("CREATE TABLE one (id INT);"),
+		"README.md":            file("not a migration"),
+		"0003_notes.txt":       file("not a migration either"),
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// versions sort numerically, not by file name
+	if got := versions(migrations); !slices.Equal(got, []int64{1, 2, 10}) {
+		t.Fatalf("got versions %v", got)
+	}
+	first, second, tenth := migrations[0], migrations[1], migrations[2]
+	if first.Name != "first" || first.Down != "" {
+		t.Errorf("got %+v", first)
+	}
+	if second.Up != "CREATE INDEX two ON logs(id);" || second.Down != "DROP INDEX two;" {
+		t.Errorf("got %+v", second)
+	}
+	if second.Destructive || !tenth.Destructive {
+		t.Errorf("got destructive %v and %v, want only the tenth", second.Destructive, tenth.Destructive)
+	}
+	if first.Checksum == "" || first.Checksum == tenth.Checksum {
+		t.Errorf("got checksums %q and %q", first.Checksum, tenth.Checksum)
+	}
+}
+
+func TestLoadChecksumCoversTheUpFile(t *testing.T) {
+	load := func(fsys fstest.MapFS) Migration {
+		t.Helper()
+		migrations, err := Load(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migrations[0]
+	}
+
+	base := load(fstest.MapFS{"0001_a.up.sql": file("SELECT 1;")})
+	withDown := load(fstest.MapFS{"0001_a.up.sql": file("SELECT 1;"), "0001_a.down.sql": file("SELECT 2;")})
+	edited := load(fstest.MapFS{"0001_a.up.sql": file("SELECT 1; ")})
+	if base.Checksum != withDown.Checksum {
+		t.Error("the down file changed the checksum")
+	}
+	if base.Checksum == edited.Checksum {
+		t.Error("editing the up file kept the checksum")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{
+			name: "two names for one version",
+			fsys: fstest.MapFS{"0001_a.up.sql": file("SELECT 1;"), "0001_b.up.sql": file("SELECT 1;")},
+			want: "two names",
+		},
+		{
+			name: "a down file without its up file",
+			fsys: fstest.MapFS{"0001_a.up.sql": file("SELECT 1;"), "0002_b.down.sql": file("SELECT 1;")},
+			want: "no up file",
+		},
+		{
+			name: "an empty up file",
+			fsys: fstest.MapFS{"0001_a.up.sql": file("")},
+			want: "no up file",
+		},
+		{
+			name: "a version out of range",
+			fsys: fstest.MapFS{"99999999999999999999_a.up.sql": file("SELECT 1;")},
+			want: "invalid migration version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error about %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestructive(t *testing.T) {
+	tests := []struct {
+		down string
+		want bool
+	}{
+		{"DROP TABLE IF EXISTS logs;", true},
+		{"ALTER TABLE logs DROP COLUMN IF EXISTS trace_id;", true},
+		{"alter table logs drop   column span_id;", true},
+		{"DELETE FROM schema_notes;", true},
+		{"TRUNCATE issues;", true},
+		{"DROP INDEX IF EXISTS idx_logs_trace_id;", false},
+		{"CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);", false},
+		{"ALTER TABLE logs DROP CONSTRAINT logs_pkey;", false},
+	}
+	for _, tt := range tests {
+		migrations, err := Load(fstest.MapFS{"0001_a.up.sql": file("SELECT 1;"), "0001_a.down.sql": file(tt.down)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := migrations[0].Destructive; got != tt.want {
+			t.Errorf("%q: got destructive %v, want %v", tt.down, got, tt.want)
+		}
+	}
+}
+
+func testMigrator(t *testing.T, db *sql.DB) *Migrator {
+	t.Helper()
+	m, err := New(db, fstest.MapFS{
+		"0001_create.up.sql":   file("CREATE TABLE items (id INT PRIMARY KEY);"),
+		"0001_create.down.sql": file("DROP TABLE items;"),
+		"0002_index.up.sql":    file("CREATE INDEX items_id ON items (id);"),
+		"0002_index.down.sql":  file("DROP INDEX items_id;"),
+		"0003_name.up.sql":     file("ALTER TABLE items ADD COLUMN name TEXT;"),
+		"0003_name.down.sql":   file("ALTER TABLE items DROP COLUMN name;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestVerify(t *testing.T) {
+	m := testMigrator(t, nil)
+	sums := make(map[int64]string)
+	for _, migration := range m.migrations {
+		sums[migration.Version] = migration.Checksum
+	}
+
+	tests := []struct {
+		name    string
+		applied map[int64]appliedMigration
+		ok      bool
+	}{
+		{"nothing applied", nil, true},
+		{"matching checksums", map[int64]appliedMigration{1: {checksum: sums[1]}, 2: {checksum: sums[2]}}, true},
+		{"a migration unknown to this build", map[int64]appliedMigration{1: {checksum: sums[1]}, 7: {checksum: "x"}}, true},
+		{"a modified migration", map[int64]appliedMigration{1: {checksum: sums[1]}, 2: {checksum: "x"}}, false},
+	}
+	for _, tt := range tests {
+		if err := m.verify(tt.applied); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestDownPlan(t *testing.T) {
+	m := testMigrator(t, nil)
+	all := map[int64]appliedMigration{1: {}, 2: {}, 3: {}}
+	tests := []struct {
+		name          string
+		applied       map[int64]appliedMigration
+		steps         int
+		allowDataLoss bool
+		want          []int64
+		err           error
+	}{
+		{"newest first", all, 2, true, []int64{3, 2}, nil},
+		{"more steps than applied", map[int64]appliedMigration{1: {}, 2: {}}, 5, true, []int64{2, 1}, nil},
+		{"pending migrations are skipped", map[int64]appliedMigration{1: {}, 3: {}}, 2, true, []int64{3, 1}, nil},
+		{"a destructive step is refused", all, 1, false, nil, ErrDataLoss},
+		{"a destructive step anywhere refuses them all", map[int64]appliedMigration{1: {}, 2: {}}, 2, false, nil, ErrDataLoss},
+		{"a safe step needs no permission", map[int64]appliedMigration{1: {}, 2: {}}, 1, false, []int64{2}, nil},
+		{"nothing applied", nil, 1, false, []int64{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.downPlan(tt.applied, tt.steps, tt.allowDataLoss)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && !slices.Equal(versions(got), tt.want) {
+				t.Errorf("got %v, want %v", versions(got), tt.want)
+			}
+		})
+	}
+
+	noDown, err := New(nil, fstest.MapFS{"0001_a.up.sql": file("SELECT 1;")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := noDown.downPlan(map[int64]appliedMigration{1: {}}, 1, true); err == nil {
+		t.Error("got no error reverting a migration without a down file")
+	}
+}
+
+// openTestDB connects to the database in DATABASE_URL with a fresh schema
+// first on the search path. It skips the test when DATABASE_URL is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("socode_migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func pending(t *testing.T, m *Migrator) int {
+	t.Helper()
+	n, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestUpDown(t *testing.T) {
+	db := openTestDB(t)
+	m := testMigrator(t, db)
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(applied); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Fatalf("applied %v", got)
+	}
+	if applied, err := m.Up(); err != nil || len(applied) != 0 {
+		t.Fatalf("applied %v again, %v", versions(applied), err)
+	}
+
+	// dropping the name column is refused without permission, and nothing
+	// is reverted
+	if _, err := m.Down(1, false); !errors.Is(err, ErrDataLoss) {
+		t.Fatalf("got %v, want ErrDataLoss", err)
+	}
+	if n := pending(t, m); n != 0 {
+		t.Fatalf("%d migrations pending after a refused down", n)
+	}
+
+	reverted, err := m.Down(2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(reverted); !slices.Equal(got, []int64{3, 2}) {
+		t.Fatalf("reverted %v", got)
+	}
+	if n := pending(t, m); n != 2 {
+		t.Errorf("got %d migrations pending, want 2", n)
+	}
+	if _, err := db.Exec("SELECT name FROM items"); err == nil {
+		t.Error("the name column survived its down migration")
+	}
+
+	if applied, err := m.Up(); err != nil || !slices.Equal(versions(applied), []int64{2, 3}) {
+		t.Fatalf("reapplied %v, %v", versions(applied), err)
+	}
+}
+
+func TestUpRefusesModifiedMigration(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := testMigrator(t, db).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := New(db, fstest.MapFS{
+		"0001_create.up.sql": file("CREATE TABLE items (id BIGINT PRIMARY KEY);"),
+		"0004_more.up.sql":   file("CREATE TABLE more (id INT);"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := edited.Up(); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Fatalf("got %v, want the modified migration refused", err)
+	}
+
+	statuses, err := edited.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0002 and 0003 are applied but unknown to this build
+	var got []string
+	for _, s := range statuses {
+		got = append(got, fmt.Sprintf("%d applied=%v modified=%v missing=%v", s.Version, s.Applied, s.Modified, s.Missing))
+	}
+	want := []string{
+		"1 applied=true modified=true missing=false",
+		"2 applied=true modified=false missing=true",
+		"3 applied=true modified=false missing=true",
+		"4 applied=false modified=false missing=false",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got statuses %v, want %v", got, want)
+	}
+}
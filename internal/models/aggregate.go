@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AggregateQuery counts the logs matching Query per time bucket and group.
+type AggregateQuery struct {
+	Query LogQuery
+	// GroupBy lists the fields to group by: level, service, host, source or
+	// tags.<key>.
+	GroupBy []string
+	// Interval is the width of the time buckets. Zero counts the whole range
+	// as a single bucket.
+	Interval time.Duration
+	// TopN keeps the N largest groups over the whole range and folds the
+	// rest into one "other" group per bucket. Zero keeps every group.
+	TopN int
+}
+
+type aggregateQueryJSON struct {
+	Query    LogQuery `json:"query"`
+	GroupBy  []string `json:"group_by,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+	TopN     int      `json:"top_n,omitempty"`
+}
+
+func (q AggregateQuery) MarshalJSON() ([]byte, error) {
+	raw := aggregateQueryJSON{Query: q.Query, GroupBy: q.GroupBy, TopN: q.TopN}
+	if q.Interval > 0 {
+		raw.Interval = FormatDuration(q.Interval)
+	}
+	return json.Marshal(raw)
+}
+
+func (q *AggregateQuery) UnmarshalJSON(data []byte) error {
+	var raw aggregateQueryJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*q = AggregateQuery{Query: raw.Query, GroupBy: raw.GroupBy, TopN: raw.TopN}
+	if raw.Interval != "" {
+		interval, err := ParseDuration(raw.Interval)
+		if err != nil {
+			return err
+		}
+		q.Interval = interval
+	}
+	return nil
+}
+
+// AggregateBucket holds the counts per group of one time bucket. Start is
+// nil when the query has no interval.
+type AggregateBucket struct {
+	Start  *time.Time       `json:"start,omitempty"`
+	Groups []AggregateCount `json:"groups"`
+}
+
+// AggregateCount is the number of logs in one group, identified by the value
+// of each GroupBy field. Other marks the group folding everything outside
+// the top N.
+type AggregateCount struct {
+	Group map[string]string `json:"group,omitempty"`
+	Other bool              `json:"other,omitempty"`
+	Count int64             `json:"count"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	OrderDesc = "desc"
+	OrderAsc  = "asc"
+)
+
+// Cursor marks a position in a result ordered by (timestamp, id). A query
+// with a cursor returns the page right after it in the query's order, or
+// right before it when Before is set. Clients treat cursors as opaque
+// strings.
+type Cursor struct {
+	Timestamp time.Time `json:"ts"`
+	ID        string    `json:"id"`
+	Before    bool      `json:"before,omitempty"`
+}
+
+// cursorFields is Cursor without its string JSON encoding.
+type cursorFields Cursor
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func ParseCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursorFields
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return (*Cursor)(&c), nil
+}
+
+func (c Cursor) String() string {
+	data, _ := json.Marshal(cursorFields(c))
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (c Cursor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *Cursor) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return ErrInvalidCursor
+	}
+	parsed, err := ParseCursor(s)
+	if err != nil {
+		return err
+	}
+	*c = *parsed
+	return nil
+}
+
+// PageCursors returns the cursors of the pages following and preceding logs,
+// the page returned for query, or nil where there is nothing to page to.
+// A full page is assumed to have more logs after it.
+func PageCursors(query LogQuery, logs []LogEntry) (next, prev *Cursor) {
+	if len(logs) == 0 {
+		if query.Cursor == nil {
+			return nil, nil
+		}
+		// ran off one end, the way back starts at the cursor itself
+		back := *query.Cursor
+		back.Before = !back.Before
+		if back.Before {
+			return nil, &back
+		}
+		return &back, nil
+	}
+
+	full := query.Limit > 0 && len(logs) >= query.Limit
+	first, last := logs[0], logs[len(logs)-1]
+
+	if full || (query.Cursor != nil && query.Cursor.Before) {
+		next = &Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	if query.Cursor != nil && (!query.Cursor.Before || full) {
+		prev = &Cursor{Timestamp: first.Timestamp, ID: first.ID, Before: true}
+	}
+	return next, prev
+}
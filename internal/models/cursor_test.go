@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCursor(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	tests := []Cursor{
+		{Timestamp: ts, ID: "log-1"},
+		{Timestamp: ts, ID: "log-2", Before: true},
+		{Timestamp: ts.In(time.FixedZone("CEST", 2*60*60)), ID: "with spaces/and+symbols"},
+	}
+	for _, want := range tests {
+		t.Run(want.ID, func(t *testing.T) {
+			got, err := ParseCursor(want.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Timestamp.Equal(want.Timestamp) || got.ID != want.ID || got.Before != want.Before {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseCursorErrors(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := map[string]string{
+		"empty":       "",
+		"not base64":  "not a cursor!",
+		"padded":      base64.URLEncoding.EncodeToString([]byte(`{"ts":"2024-05-01T12:00:00Z","id":"a"}`)),
+		"not json":    encode("hello"),
+		"missing id":  encode(`{"ts":"2024-05-01T12:00:00Z"}`),
+		"bad ts":      encode(`{"ts":"yesterday","id":"a"}`),
+		"wrong types": encode(`{"ts":"2024-05-01T12:00:00Z","id":1}`),
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseCursor(in); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestPageCursors(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	logs := []LogEntry{{ID: "c", Timestamp: ts.Add(2 * time.Second)}, {ID: "b", Timestamp: ts.Add(time.Second)}}
+	first := &Cursor{Timestamp: logs[0].Timestamp, ID: "c", Before: true}
+	last := &Cursor{Timestamp: logs[1].Timestamp, ID: "b"}
+	at := &Cursor{Timestamp: ts.Add(3 * time.Second), ID: "d"}
+
+	tests := []struct {
+		name       string
+		query      LogQuery
+		logs       []LogEntry
+		next, prev *Cursor
+	}{
+		{"first page, full", LogQuery{Limit: 2}, logs, last, nil},
+		{"first page, partial", LogQuery{Limit: 5}, logs, nil, nil},
+		{"next page, full", LogQuery{Limit: 2, Cursor: at}, logs, last, first},
+		{"last page", LogQuery{Limit: 5, Cursor: at}, logs, nil, first},
+		{"previous page, full", LogQuery{Limit: 2, Cursor: &Cursor{Timestamp: ts, ID: "a", Before: true}}, logs, last, first},
+		{"first page reached backwards", LogQuery{Limit: 5, Cursor: &Cursor{Timestamp: ts, ID: "a", Before: true}}, logs, last, nil},
+		{"empty", LogQuery{Limit: 2}, nil, nil, nil},
+		{"ran off the end", LogQuery{Limit: 2, Cursor: at}, nil, nil, &Cursor{Timestamp: at.Timestamp, ID: "d", Before: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, prev := PageCursors(tt.query, tt.logs)
+			if !sameCursor(next, tt.next) || !sameCursor(prev, tt.prev) {
+				t.Errorf("got next %+v prev %+v, want next %+v prev %+v", next, prev, tt.next, tt.prev)
+			}
+		})
+	}
+}
+
+func sameCursor(a, b *Cursor) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Timestamp.Equal(b.Timestamp) && a.ID == b.ID && a.Before == b.Before
+}
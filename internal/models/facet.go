@@ -0,0 +1,25 @@
+package models
+
+// FacetQuery lists the distinct values of a field among the logs matching
+// Query, with how many logs have each. Field is a column (level, service,
+// host, source), "tags" for the tag keys or "tags.<key>" for the values of a
+// tag. Prefix narrows the values for autocompletion, ignoring case.
+type FacetQuery struct {
+	Query  LogQuery `json:"query"`
+	Field  string   `json:"field"`
+	Prefix string   `json:"prefix,omitempty"`
+	Limit  int      `json:"limit,omitempty"`
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facet holds the most common values of a field, most frequent first.
+// Truncated is set when more values exist than the limit allowed.
+type Facet struct {
+	Field     string       `json:"field"`
+	Values    []FacetValue `json:"values"`
+	Truncated bool         `json:"truncated"`
+}
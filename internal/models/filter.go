@@ -0,0 +1,195 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+// FilterOp is the comparison made by a FieldFilter.
+type FilterOp string
+
+const (
+	FilterEquals FilterOp = "eq"
+	FilterPrefix FilterOp = "prefix"
+	// FilterWildcard matches a glob where * is any run of characters and ?
+	// a single one. A backslash escapes the next character.
+	FilterWildcard FilterOp = "wildcard"
+	// FilterExists matches logs that have the tag or metadata path at all.
+	FilterExists FilterOp = "exists"
+	// FilterContains matches when tags or metadata contain the JSON object in
+	// Value, like the JSONB @> operator.
+	FilterContains FilterOp = "contains"
+)
+
+// FieldFilter narrows a LogQuery on a single field. Field is one of
+// querylang.Columns (id, level, message, source, service, host, template_id,
+// fingerprint, trace_id, span_id, parent_span_id), "tags.<key>",
+// "metadata.<path>" with the path split on dots, or "tags" and "metadata"
+// themselves for FilterContains. Not negates the filter; negated filters also
+// match logs that lack the field.
+type FieldFilter struct {
+	Field string   `json:"field"`
+	Op    FilterOp `json:"op"`
+	Value string   `json:"value,omitempty"`
+	Not   bool     `json:"not,omitempty"`
+}
+
+// ParseFilter reads the compact form of a filter used in query strings and
+// gRPC requests:
+//
+//	service=api           equals
+//	service!=api          not equals
+//	host=web-*            wildcard, a lone trailing * is a prefix match
+//	tags.env              tag exists
+//	!metadata.user.id     metadata path does not exist
+//	tags@>{"env":"prod"}  JSON containment
+//
+// A leading ! negates any filter.
+func ParseFilter(s string) (FieldFilter, error) {
+	var f FieldFilter
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, "!"); ok {
+		f.Not = true
+		s = rest
+	}
+
+	i := strings.IndexAny(s, "=!@")
+	switch {
+	case i < 0:
+		f.Field, f.Op = s, FilterExists
+	case strings.HasPrefix(s[i:], "@>"):
+		f.Field, f.Op, f.Value = s[:i], FilterContains, s[i+2:]
+	case strings.HasPrefix(s[i:], "!="):
+		f.Field, f.Not = s[:i], !f.Not
+		f.Op, f.Value = parseFilterValue(s[i+2:])
+	case s[i] == '=':
+		f.Field = s[:i]
+		f.Op, f.Value = parseFilterValue(s[i+1:])
+	default:
+		return FieldFilter{}, fmt.Errorf("invalid filter %q", s)
+	}
+
+	if err := f.Validate(); err != nil {
+		return FieldFilter{}, err
+	}
+	return f, nil
+}
+
+// GlobFilter returns the filter matching field against a glob, read like the
+// values of ParseFilter.
+func GlobFilter(field, glob string) FieldFilter {
+	op, value := parseFilterValue(glob)
+	return FieldFilter{Field: field, Op: op, Value: value}
+}
+
+// parseFilterValue tells equality from prefix and wildcard matches.
+func parseFilterValue(value string) (FilterOp, string) {
+	var literal strings.Builder
+	wildcards := 0
+	trailingStar := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value):
+			i++
+			literal.WriteByte(value[i])
+			trailingStar = false
+		case c == '*' || c == '?':
+			wildcards++
+			trailingStar = c == '*' && i == len(value)-1
+		default:
+			literal.WriteByte(c)
+		}
+	}
+
+	switch {
+	case wildcards == 0:
+		return FilterEquals, literal.String()
+	case wildcards == 1 && trailingStar:
+		return FilterPrefix, literal.String()
+	}
+	return FilterWildcard, value
+}
+
+// UnmarshalJSON accepts either a filter object or a string in the compact
+// form read by ParseFilter.
+func (f *FieldFilter) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := ParseFilter(s)
+		if err != nil {
+			return err
+		}
+		*f = parsed
+		return nil
+	}
+
+	type plain FieldFilter
+	var raw plain
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = FieldFilter(raw)
+	return f.Validate()
+}
+
+// TagKey returns the key of a "tags.<key>" filter.
+func (f FieldFilter) TagKey() (string, bool) {
+	key, ok := strings.CutPrefix(f.Field, "tags.")
+	return key, ok && key != ""
+}
+
+// MetadataPath returns the path of a "metadata.<path>" filter.
+func (f FieldFilter) MetadataPath() ([]string, bool) {
+	path, ok := strings.CutPrefix(f.Field, "metadata.")
+	if !ok || path == "" {
+		return nil, false
+	}
+	return strings.Split(path, "."), true
+}
+
+func (f FieldFilter) Validate() error {
+	_, isTag := f.TagKey()
+	path, isMetadata := f.MetadataPath()
+	for _, segment := range path {
+		if segment == "" {
+			return fmt.Errorf("invalid metadata path in %q", f.Field)
+		}
+	}
+
+	switch f.Op {
+	case FilterEquals, FilterPrefix, FilterWildcard:
+		if !slices.Contains(querylang.Columns, f.Field) && !isTag && !isMetadata {
+			return fmt.Errorf("cannot filter on %q", f.Field)
+		}
+
+	case FilterExists:
+		if !isTag && !isMetadata {
+			return fmt.Errorf("existence filters need a tag or metadata path, got %q", f.Field)
+		}
+
+	case FilterContains:
+		if f.Field != "tags" && f.Field != "metadata" {
+			return fmt.Errorf("containment filters apply to tags or metadata, got %q", f.Field)
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(f.Value), &object); err != nil {
+			return errors.New("containment filters need a JSON object")
+		}
+		if f.Field == "tags" {
+			var tags map[string]string
+			if err := json.Unmarshal([]byte(f.Value), &tags); err != nil {
+				return errors.New("tag values are strings")
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown filter operator %q", f.Op)
+	}
+	return nil
+}
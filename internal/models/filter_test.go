@@ -0,0 +1,59 @@
+package models
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want FieldFilter
+	}{
+		{"service=api", FieldFilter{Field: "service", Op: FilterEquals, Value: "api"}},
+		{" service=api ", FieldFilter{Field: "service", Op: FilterEquals, Value: "api"}},
+		{"service!=api", FieldFilter{Field: "service", Op: FilterEquals, Value: "api", Not: true}},
+		{"!service!=api", FieldFilter{Field: "service", Op: FilterEquals, Value: "api"}},
+		{"host=web-*", FieldFilter{Field: "host", Op: FilterPrefix, Value: "web-"}},
+		{"host=web-*-eu", FieldFilter{Field: "host", Op: FilterWildcard, Value: "web-*-eu"}},
+		{"host=web-?", FieldFilter{Field: "host", Op: FilterWildcard, Value: "web-?"}},
+		{`host=web-\*`, FieldFilter{Field: "host", Op: FilterEquals, Value: "web-*"}},
+		{"tags.env=prod", FieldFilter{Field: "tags.env", Op: FilterEquals, Value: "prod"}},
+		{"metadata.user.id=42", FieldFilter{Field: "metadata.user.id", Op: FilterEquals, Value: "42"}},
+		{"tags.env", FieldFilter{Field: "tags.env", Op: FilterExists}},
+		{"!metadata.user.id", FieldFilter{Field: "metadata.user.id", Op: FilterExists, Not: true}},
+		{`tags@>{"env":"prod"}`, FieldFilter{Field: "tags", Op: FilterContains, Value: `{"env":"prod"}`}},
+		{`metadata@>{"user":{"id":42}}`, FieldFilter{Field: "metadata", Op: FilterContains, Value: `{"user":{"id":42}}`}},
+		{"message=", FieldFilter{Field: "message", Op: FilterEquals}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFilter(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"service",
+		"colour=red",
+		"metadata..id=1",
+		"metadata.=1",
+		"tags@prod",
+		`service@>{"a":"b"}`,
+		`tags@>[1]`,
+		`tags@>{"env":1}`,
+		"metadata@>not json",
+	}
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			if f, err := ParseFilter(in); err == nil {
+				t.Errorf("got %+v, want an error", f)
+			}
+		})
+	}
+}
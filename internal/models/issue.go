@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type IssueStatus string
+
+const (
+	IssueOpen     IssueStatus = "open"
+	IssueResolved IssueStatus = "resolved"
+	IssueIgnored  IssueStatus = "ignored"
+)
+
+func ParseIssueStatus(s string) (IssueStatus, error) {
+	switch status := IssueStatus(s); status {
+	case IssueOpen, IssueResolved, IssueIgnored:
+		return status, nil
+	}
+	return "", fmt.Errorf("invalid issue status %q, must be open, resolved or ignored", s)
+}
+
+// Issue groups the ERROR and FATAL logs sharing a fingerprint. Regressed is
+// set when the issue was seen again after being resolved, which reopens it.
+type Issue struct {
+	ID          string      `json:"id"`
+	Service     string      `json:"service"`
+	Level       LogLevel    `json:"level"`
+	Title       string      `json:"title"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+	Count       int64       `json:"count"`
+	Hosts       []string    `json:"hosts"`
+	Status      IssueStatus `json:"status"`
+	Regressed   bool        `json:"regressed"`
+	ResolvedAt  *time.Time  `json:"resolved_at,omitempty"`
+	RegressedAt *time.Time  `json:"regressed_at,omitempty"`
+	LastLogID   string      `json:"last_log_id"`
+}
+
+// IssueQuery lists issues, most recently seen first. Empty fields do not
+// filter.
+type IssueQuery struct {
+	Status    []IssueStatus `json:"status,omitempty"`
+	Service   []string      `json:"service,omitempty"`
+	Regressed bool          `json:"regressed,omitempty"`
+	Limit     int           `json:"limit,omitempty"`
+	Offset    int           `json:"offset,omitempty"`
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/querylang"
 )
 
 type LogLevel string
@@ -25,17 +27,76 @@ type LogEntry struct {
 	Host      string            `json:"host" db:"host"`
 	Tags      map[string]string `json:"tags" db:"tags"`
 	Metadata  json.RawMessage   `json:"metadata" db:"metadata"`
+	// TemplateID is the pattern the message was assigned when processed.
+	TemplateID string `json:"template_id,omitempty" db:"template_id"`
+	// Fingerprint groups ERROR and FATAL logs into issues.
+	Fingerprint string `json:"fingerprint,omitempty" db:"fingerprint"`
+	// TraceID, SpanID and ParentSpanID place the log in a distributed trace.
+	TraceID      string `json:"trace_id,omitempty" db:"trace_id"`
+	SpanID       string `json:"span_id,omitempty" db:"span_id"`
+	ParentSpanID string `json:"parent_span_id,omitempty" db:"parent_span_id"`
+
+	// Rank and Highlight are only set on full-text search results.
+	Rank      float64 `json:"rank,omitempty" db:"-"`
+	Highlight string  `json:"highlight,omitempty" db:"-"`
+	// Cold is set on results read from the archive.
+	Cold bool `json:"cold,omitempty" db:"-"`
 }
 
+// SearchMode selects how LogQuery.Search matches messages.
+type SearchMode string
+
+const (
+	// SearchFullText matches words through the full-text index and accepts
+	// web search syntax: "quoted phrases", or, and -negation.
+	SearchFullText SearchMode = "fulltext"
+	// SearchSubstring matches the text anywhere in the message, ignoring
+	// case. It is the default.
+	SearchSubstring SearchMode = "substring"
+	SearchRegex     SearchMode = "regex"
+)
+
+const (
+	SortTimestamp = "timestamp"
+	// SortRelevance orders full-text search results by rank, newest first
+	// among equals.
+	SortRelevance = "relevance"
+)
+
 type LogQuery struct {
-	StartTime *time.Time        `json:"start_time,omitempty"`
-	EndTime   *time.Time        `json:"end_time,omitempty"`
-	Level     []LogLevel        `json:"level,omitempty"`
-	Source    []string          `json:"source,omitempty"`
-	Service   []string          `json:"service,omitempty"`
-	Host      []string          `json:"host,omitempty"`
-	Search    string            `json:"search,omitempty"`
-	Tags      map[string]string `json:"tags,omitempty"`
-	Limit     int               `json:"limit,omitempty"`
-	Offset    int               `json:"offset,omitempty"`
+	StartTime  *time.Time        `json:"start_time,omitempty"`
+	EndTime    *time.Time        `json:"end_time,omitempty"`
+	Level      []LogLevel        `json:"level,omitempty"`
+	Source     []string          `json:"source,omitempty"`
+	Service    []string          `json:"service,omitempty"`
+	Host       []string          `json:"host,omitempty"`
+	Search     string            `json:"search,omitempty"`
+	SearchMode SearchMode        `json:"search_mode,omitempty"`
+	Sort       string            `json:"sort,omitempty"`
+	Order      string            `json:"order,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Filters    []FieldFilter     `json:"filters,omitempty"`
+	// Expr is a filter in the query language, see package querylang.
+	Expr string `json:"q,omitempty"`
+	// ExprNode is Expr once parsed by storage.ValidateQuery, so the stores
+	// do not parse it again.
+	ExprNode querylang.Node `json:"-"`
+	Limit    int            `json:"limit,omitempty"`
+	Offset   int            `json:"offset,omitempty"`
+	Cursor   *Cursor        `json:"cursor,omitempty"`
+}
+
+// Total counts every log matching a query, beyond the returned page.
+type Total struct {
+	Value    int64  `json:"value"`
+	Relation string `json:"relation"`
 }
+
+const (
+	// TotalExact means Value is the exact count.
+	TotalExact = "eq"
+	// TotalAtLeast means counting stopped at Value.
+	TotalAtLeast = "gte"
+	// TotalEstimate means Value is the query planner's estimate.
+	TotalEstimate = "estimate"
+)
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Pattern is a message template mined from the logs of a service, with
+// "<*>" in place of the parts that vary.
+type Pattern struct {
+	ID        string    `json:"id" db:"id"`
+	Service   string    `json:"service" db:"service"`
+	Template  string    `json:"template" db:"template"`
+	FirstSeen time.Time `json:"first_seen" db:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" db:"last_seen"`
+}
+
+// PatternQuery lists the most common patterns among the logs matching Query,
+// with up to Examples of their logs each.
+type PatternQuery struct {
+	Query    LogQuery `json:"query"`
+	Limit    int      `json:"limit,omitempty"`
+	Examples int      `json:"examples,omitempty"`
+}
+
+// PatternCount is a pattern with the number of matching logs it covers.
+type PatternCount struct {
+	Pattern
+	Count    int64      `json:"count"`
+	Examples []LogEntry `json:"examples"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy keeps logs matching Service and Level for MaxAge. Empty
+// selectors match everything, and when several policies match a log the most
+// specific one wins: service and level, then service, then level, then the
+// catch-all.
+type RetentionPolicy struct {
+	Name    string        `json:"name"`
+	Service string        `json:"service,omitempty"`
+	Level   LogLevel      `json:"level,omitempty"`
+	MaxAge  time.Duration `json:"-"`
+}
+
+func (p RetentionPolicy) Specificity() int {
+	n := 0
+	if p.Service != "" {
+		n += 2
+	}
+	if p.Level != "" {
+		n++
+	}
+	return n
+}
+
+// Overlaps reports whether some log could match both policies.
+func (p RetentionPolicy) Overlaps(other RetentionPolicy) bool {
+	return (p.Service == "" || other.Service == "" || p.Service == other.Service) &&
+		(p.Level == "" || other.Level == "" || p.Level == other.Level)
+}
+
+type retentionPolicyJSON struct {
+	Name    string   `json:"name"`
+	Service string   `json:"service,omitempty"`
+	Level   LogLevel `json:"level,omitempty"`
+	MaxAge  string   `json:"max_age"`
+}
+
+func (p RetentionPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(retentionPolicyJSON{
+		Name:    p.Name,
+		Service: p.Service,
+		Level:   p.Level,
+		MaxAge:  FormatDuration(p.MaxAge),
+	})
+}
+
+func (p *RetentionPolicy) UnmarshalJSON(data []byte) error {
+	var raw retentionPolicyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	age, err := ParseDuration(raw.MaxAge)
+	if err != nil {
+		return fmt.Errorf("invalid max_age %q", raw.MaxAge)
+	}
+
+	*p = RetentionPolicy{
+		Name:    raw.Name,
+		Service: raw.Service,
+		Level:   LogLevel(strings.ToUpper(string(raw.Level))),
+		MaxAge:  age,
+	}
+	return nil
+}
+
+// RetentionScope selects the logs a policy deletes: those matching the
+// policy, older than Before and not governed by a more specific policy in
+// Except.
+type RetentionScope struct {
+	Policy RetentionPolicy
+	Except []RetentionPolicy
+	Before time.Time
+}
+
+type RetentionPreview struct {
+	Policy  RetentionPolicy `json:"policy"`
+	Cutoff  time.Time       `json:"cutoff"`
+	Expired int64           `json:"expired"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Timeline is every log sharing a value of a correlation key, such as a
+// request or session ID, merged across services in time order. Durations are
+// in milliseconds.
+type Timeline struct {
+	Key        string        `json:"key"`
+	Value      string        `json:"value"`
+	Start      *time.Time    `json:"start,omitempty"`
+	End        *time.Time    `json:"end,omitempty"`
+	DurationMs float64       `json:"duration_ms"`
+	Logs       []TraceLog    `json:"logs"`
+	Services   []ServiceSpan `json:"services"`
+	Gaps       []TimelineGap `json:"gaps"`
+	FirstError *LogEntry     `json:"first_error,omitempty"`
+	// Truncated is set when there were more logs than the timeline holds.
+	Truncated bool `json:"truncated"`
+	// WindowStart and WindowEnd are the time range that was searched.
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+// ServiceSpan is the part of a timeline logged by one service, from its
+// first log to its last.
+type ServiceSpan struct {
+	Service    string    `json:"service"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMs float64   `json:"duration_ms"`
+	Count      int       `json:"count"`
+	Errors     int       `json:"errors"`
+}
+
+// TimelineGap is the time between the last log of one service and the first
+// log of the next one in a timeline.
+type TimelineGap struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMs float64   `json:"duration_ms"`
+}
+
+// Milliseconds converts d to fractional milliseconds.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,39 @@
+package models
+
+// TraceLog is a log of a trace. Transition is set on the first log of a run
+// of logs from the same service, other than the first of the trace, with the
+// service logging before it in PreviousService.
+type TraceLog struct {
+	LogEntry
+	Transition      bool   `json:"transition,omitempty"`
+	PreviousService string `json:"previous_service,omitempty"`
+	// FirstError is set on the earliest ERROR or FATAL log of a timeline.
+	FirstError bool `json:"first_error,omitempty"`
+}
+
+// MarkTransitions marks where the service changes in logs, which are in time
+// order.
+func MarkTransitions(logs []LogEntry) []TraceLog {
+	marked := make([]TraceLog, len(logs))
+	for i, log := range logs {
+		marked[i].LogEntry = log
+		if i > 0 && logs[i-1].Service != log.Service {
+			marked[i].Transition = true
+			marked[i].PreviousService = logs[i-1].Service
+		}
+	}
+	return marked
+}
+
+// TraceServices lists the services of logs in the order they first log.
+func TraceServices(logs []LogEntry) []string {
+	services := []string{}
+	seen := make(map[string]bool)
+	for _, log := range logs {
+		if !seen[log.Service] {
+			seen[log.Service] = true
+			services = append(services, log.Service)
+		}
+	}
+	return services
+}
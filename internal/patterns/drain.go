@@ -0,0 +1,303 @@
+// Package patterns groups log messages into templates, where the parts that
+// vary from one message to the next are replaced by a placeholder, with a
+// variant of the Drain algorithm.
+//
+// Messages are masked and split into tokens, then routed through a tree by
+// service, token count and their first few tokens. Each leaf holds templates
+// of the same length; a message joins the most similar one if enough of its
+// tokens agree, turning the tokens that differ into placeholders, or starts a
+// new template otherwise.
+package patterns
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// Wildcard stands for a variable part of a template.
+const Wildcard = "<*>"
+
+// masks replace values that are variable by nature before tokenizing.
+var masks = []*regexp.Regexp{
+	regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+	regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`),
+	regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`),
+	regexp.MustCompile(`\b\d+(?:\.\d+)?(?:[a-zA-Z]{1,3})?\b`),
+}
+
+// hexToken matches whole tokens that look like hashes or IDs. Short ones are
+// left alone, they are more likely to be words.
+var hexToken = regexp.MustCompile(`^[0-9a-fA-F]{8,}$`)
+
+type cluster struct {
+	id        string
+	service   string
+	tokens    []string
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// seen widens the time range of c to ts and reports whether it changed.
+func (c *cluster) seen(ts time.Time) bool {
+	changed := false
+	if ts.Before(c.firstSeen) {
+		c.firstSeen, changed = ts, true
+	}
+	if ts.After(c.lastSeen) {
+		c.lastSeen, changed = ts, true
+	}
+	return changed
+}
+
+func (c *cluster) pattern() models.Pattern {
+	return models.Pattern{
+		ID:        c.id,
+		Service:   c.service,
+		Template:  strings.Join(c.tokens, " "),
+		FirstSeen: c.firstSeen,
+		LastSeen:  c.lastSeen,
+	}
+}
+
+type node struct {
+	children map[string]*node
+	clusters []*cluster
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+// Miner assigns messages to templates. It is safe for concurrent use.
+type Miner struct {
+	similarity  float64
+	prefix      int
+	maxChildren int
+	maxClusters int
+
+	mu       sync.Mutex
+	roots    map[string]*node
+	clusters map[string]*cluster
+	// dirty holds the templates created or changed since the last flush
+	dirty map[string]*cluster
+}
+
+func NewMiner(cfg *config.PatternsConfig) *Miner {
+	return &Miner{
+		similarity:  cfg.Similarity,
+		prefix:      max(cfg.Depth-2, 1),
+		maxChildren: cfg.MaxChildren,
+		maxClusters: cfg.MaxClusters,
+		roots:       make(map[string]*node),
+		clusters:    make(map[string]*cluster),
+		dirty:       make(map[string]*cluster),
+	}
+}
+
+// Load adds templates mined earlier, keeping their IDs.
+func (m *Miner) Load(patterns []models.Pattern) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range patterns {
+		if _, ok := m.clusters[p.ID]; ok {
+			continue
+		}
+		c := &cluster{
+			id:        p.ID,
+			service:   p.Service,
+			tokens:    strings.Fields(p.Template),
+			firstSeen: p.FirstSeen,
+			lastSeen:  p.LastSeen,
+		}
+		leaf := m.leaf(c.service, c.tokens)
+		leaf.clusters = append(leaf.clusters, c)
+		m.clusters[c.id] = c
+	}
+}
+
+// Add mines the first line of message, logged by service at ts, and returns
+// the ID of its template. Blank messages, and once the configured number of
+// templates is reached messages that match none of them, get an empty ID.
+func (m *Miner) Add(service, message string, ts time.Time) string {
+	tokens := Tokenize(message)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c := m.match(m.search(service, tokens), tokens); c != nil {
+		changed := c.seen(ts)
+		for i, token := range tokens {
+			if c.tokens[i] != token && c.tokens[i] != Wildcard {
+				c.tokens[i] = Wildcard
+				changed = true
+			}
+		}
+		if changed {
+			m.dirty[c.id] = c
+		}
+		return c.id
+	}
+
+	id := templateID(service, tokens)
+	if c, ok := m.clusters[id]; ok {
+		// the same template started again after the first one grew
+		// placeholders, keep a single template per ID
+		if c.seen(ts) {
+			m.dirty[id] = c
+		}
+		return id
+	}
+	if m.maxClusters > 0 && len(m.clusters) >= m.maxClusters {
+		return ""
+	}
+
+	c := &cluster{
+		id:        id,
+		service:   service,
+		tokens:    tokens,
+		firstSeen: ts,
+		lastSeen:  ts,
+	}
+	leaf := m.leaf(service, tokens)
+	leaf.clusters = append(leaf.clusters, c)
+	m.clusters[id] = c
+	m.dirty[id] = c
+	return id
+}
+
+// Flush passes the templates created or changed since the last successful
+// flush to save. They are kept for the next flush if save fails.
+func (m *Miner) Flush(save func([]models.Pattern) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.dirty) == 0 {
+		return nil
+	}
+
+	patterns := make([]models.Pattern, 0, len(m.dirty))
+	for _, c := range m.dirty {
+		patterns = append(patterns, c.pattern())
+	}
+	if err := save(patterns); err != nil {
+		return err
+	}
+	clear(m.dirty)
+	return nil
+}
+
+// leaf walks the tree of service down to the leaf for tokens, creating nodes
+// on the way.
+func (m *Miner) leaf(service string, tokens []string) *node {
+	key := service + "\x00" + strconv.Itoa(len(tokens))
+	n, ok := m.roots[key]
+	if !ok {
+		n = newNode()
+		m.roots[key] = n
+	}
+
+	for _, token := range tokens[:min(m.prefix, len(tokens))] {
+		if token == Wildcard || hasDigit(token) {
+			token = Wildcard
+		} else if _, ok := n.children[token]; !ok && len(n.children) >= m.maxChildren {
+			token = Wildcard
+		}
+		child, ok := n.children[token]
+		if !ok {
+			child = newNode()
+			n.children[token] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// search finds the leaf for tokens without changing the tree, following the
+// placeholder branch where a token has no branch of its own. It returns nil
+// when there is none.
+func (m *Miner) search(service string, tokens []string) *node {
+	n := m.roots[service+"\x00"+strconv.Itoa(len(tokens))]
+	for _, token := range tokens[:min(m.prefix, len(tokens))] {
+		if n == nil {
+			return nil
+		}
+		child, ok := n.children[token]
+		if !ok {
+			child = n.children[Wildcard]
+		}
+		n = child
+	}
+	return n
+}
+
+// match returns the template of leaf most similar to tokens, preferring the
+// more general one among equals, if it is similar enough.
+func (m *Miner) match(leaf *node, tokens []string) *cluster {
+	if leaf == nil {
+		return nil
+	}
+	var best *cluster
+	bestSim, bestParams := -1.0, -1
+	for _, c := range leaf.clusters {
+		same, params := 0, 0
+		for i, token := range c.tokens {
+			switch token {
+			case Wildcard:
+				params++
+			case tokens[i]:
+				same++
+			}
+		}
+		sim := 1.0
+		if len(tokens) > 0 {
+			sim = float64(same) / float64(len(tokens))
+		}
+		if sim > bestSim || (sim == bestSim && params > bestParams) {
+			best, bestSim, bestParams = c, sim, params
+		}
+	}
+	if best == nil || bestSim < m.similarity {
+		return nil
+	}
+	return best
+}
+
+// Tokenize masks the variable values of the first line of message and splits
+// it on whitespace.
+func Tokenize(message string) []string {
+	line, _, _ := strings.Cut(message, "\n")
+	for _, mask := range masks {
+		line = mask.ReplaceAllLiteralString(line, Wildcard)
+	}
+	tokens := strings.Fields(line)
+	for i, token := range tokens {
+		if hexToken.MatchString(token) && hasDigit(token) {
+			tokens[i] = Wildcard
+		}
+	}
+	return tokens
+}
+
+func hasDigit(s string) bool {
+	return strings.ContainsFunc(s, unicode.IsDigit)
+}
+
+// templateID derives an ID from the template a cluster started with, so the
+// same messages get the same ID when mined again from scratch.
+func templateID(service string, tokens []string) string {
+	sum := sha1.Sum([]byte(service + "\x00" + strings.Join(tokens, " ")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,147 @@
+package patterns
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func newTestMiner(maxClusters int) *Miner {
+	return NewMiner(&config.PatternsConfig{Similarity: 0.5, Depth: 4, MaxChildren: 100, MaxClusters: maxClusters})
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"user 42 logged in", "user <*> logged in"},
+		{"took 13ms", "took <*>"},
+		{"version 1.25 loaded", "version <*> loaded"},
+		{"connect to 10.0.0.12:5432 failed", "connect to <*> failed"},
+		{"request 3f2b1c9e-8a4d-4e5f-9b6a-1c2d3e4f5a6b done", "request <*> done"},
+		{"pointer 0xdeadbeef", "pointer <*>"},
+		{"commit a94a8fe5cc", "commit <*>"},
+		{"word deadbeef stays", "word deadbeef stays"},
+		{"first line\nsecond line 42", "first line"},
+		{"  spaced   out  ", "spaced out"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			if got := strings.Join(Tokenize(tt.message), " "); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinerGroupsMessages(t *testing.T) {
+	m := newTestMiner(0)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	a := m.Add("api", "login failed for alice", ts)
+	b := m.Add("api", "login failed for bob", ts.Add(time.Minute))
+	other := m.Add("api", "disk is full on /var", ts)
+	otherService := m.Add("worker", "login failed for alice", ts)
+	blank := m.Add("api", "   ", ts)
+
+	if a == "" || a != b {
+		t.Errorf("similar messages got templates %q and %q", a, b)
+	}
+	if other == a || otherService == a {
+		t.Errorf("different messages or services share template %q", a)
+	}
+	if blank != "" {
+		t.Errorf("blank message got template %q", blank)
+	}
+
+	var saved []models.Pattern
+	if err := m.Flush(func(p []models.Pattern) error { saved = p; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(saved, func(p models.Pattern) bool { return p.ID == a })
+	if i < 0 {
+		t.Fatalf("template %s was not flushed", a)
+	}
+	p := saved[i]
+	if p.Template != "login failed for <*>" || p.Service != "api" || !p.FirstSeen.Equal(ts) || !p.LastSeen.Equal(ts.Add(time.Minute)) {
+		t.Errorf("got %+v", p)
+	}
+}
+
+func TestMinerIDsAreStable(t *testing.T) {
+	ts := time.Now()
+	first := newTestMiner(0).Add("api", "cache miss for key session", ts)
+	second := newTestMiner(0).Add("api", "cache miss for key session", ts)
+	if first != second {
+		t.Errorf("got %q and %q for the same message", first, second)
+	}
+}
+
+func TestMinerMaxClusters(t *testing.T) {
+	m := newTestMiner(1)
+	ts := time.Now()
+	if id := m.Add("api", "server started", ts); id == "" {
+		t.Fatal("first template was not created")
+	}
+	if id := m.Add("api", "completely different message here", ts); id != "" {
+		t.Errorf("got template %q past the limit", id)
+	}
+	if id := m.Add("api", "server started", ts); id == "" {
+		t.Error("known template is no longer matched")
+	}
+}
+
+func TestMinerFlushesChangedTemplatesOnly(t *testing.T) {
+	m := newTestMiner(0)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.Load([]models.Pattern{{ID: "loaded", Service: "api", Template: "job <*> done", FirstSeen: ts, LastSeen: ts}})
+
+	flush := func() ([]string, error) {
+		var ids []string
+		err := m.Flush(func(patterns []models.Pattern) error {
+			for _, p := range patterns {
+				ids = append(ids, p.ID)
+			}
+			return nil
+		})
+		slices.Sort(ids)
+		return ids, err
+	}
+
+	// loaded templates are already saved
+	if ids, _ := flush(); len(ids) != 0 {
+		t.Errorf("flushed %v after loading", ids)
+	}
+
+	// a log within the known range changes nothing
+	if id := m.Add("api", "job 7 done", ts); id != "loaded" {
+		t.Fatalf("got template %q, want the loaded one", id)
+	}
+	if ids, _ := flush(); len(ids) != 0 {
+		t.Errorf("flushed %v without changes", ids)
+	}
+
+	m.Add("api", "job 8 done", ts.Add(time.Hour))
+	created := m.Add("api", "queue drained", ts)
+
+	failed := errors.New("database down")
+	if err := m.Flush(func([]models.Pattern) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the save error", err)
+	}
+	// kept for the next flush
+	want := []string{created, "loaded"}
+	slices.Sort(want)
+	if ids, _ := flush(); !slices.Equal(ids, want) {
+		t.Errorf("flushed %v, want %v", ids, want)
+	}
+	if ids, _ := flush(); len(ids) != 0 {
+		t.Errorf("flushed %v again", ids)
+	}
+}
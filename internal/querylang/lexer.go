@@ -0,0 +1,166 @@
+package querylang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenPipe
+	tokenComma
+	// tokenOp is one of the field operators ":", "=" and "!="
+	tokenOp
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenWord:
+		return "word"
+	case tokenString:
+		return "quoted string"
+	case tokenLParen:
+		return `"("`
+	case tokenRParen:
+		return `")"`
+	case tokenPipe:
+		return `"|"`
+	case tokenComma:
+		return `","`
+	}
+	return "operator"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	// pos is the byte offset of the token in the query
+	pos int
+	// glued is set when no space separates the token from the previous one
+	glued bool
+}
+
+// Error is a syntax or validation error at a position in the query.
+type Error struct {
+	// Pos is the position of the offending character, counted in characters
+	// from 1.
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+func errorAt(src string, offset int, format string, args ...interface{}) *Error {
+	return &Error{Pos: position(src, offset), Msg: fmt.Sprintf(format, args...)}
+}
+
+// position converts a byte offset in src to a character position.
+func position(src string, offset int) int {
+	return utf8.RuneCountInString(src[:offset]) + 1
+}
+
+// special holds the characters that end a word.
+const special = `():=!|,"`
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for {
+		start := i
+		for i < len(src) {
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		glued := i == start && len(tokens) > 0
+		if i == len(src) {
+			return append(tokens, token{kind: tokenEOF, pos: i}), nil
+		}
+
+		tok := token{pos: i, glued: glued}
+		switch c := src[i]; {
+		case c == '(':
+			tok.kind, tok.text = tokenLParen, "("
+			i++
+		case c == ')':
+			tok.kind, tok.text = tokenRParen, ")"
+			i++
+		case c == '|':
+			tok.kind, tok.text = tokenPipe, "|"
+			i++
+		case c == ',':
+			tok.kind, tok.text = tokenComma, ","
+			i++
+		case c == ':' || c == '=':
+			tok.kind, tok.text = tokenOp, string(c)
+			i++
+		case c == '!':
+			if !strings.HasPrefix(src[i:], "!=") {
+				return nil, errorAt(src, i, `unexpected "!", did you mean "!=" or NOT`)
+			}
+			tok.kind, tok.text = tokenOp, "!="
+			i += 2
+		case c == '"':
+			text, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tok.kind, tok.text = tokenString, text
+			i = end
+		default:
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if unicode.IsSpace(r) || strings.ContainsRune(special, r) {
+					break
+				}
+				if r == '\\' && i+size < len(src) {
+					// keep the escape, it is meaningful to globs
+					_, next := utf8.DecodeRuneInString(src[i+size:])
+					size += next
+				}
+				i += size
+			}
+			tok.kind, tok.text = tokenWord, src[tok.pos:i]
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// lexString reads the quoted string starting at start, where \" and \\ are
+// escapes, and returns its contents and the offset past the closing quote.
+func lexString(src string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(src) && (src[i+1] == '"' || src[i+1] == '\\') {
+				i++
+			}
+		}
+		b.WriteByte(src[i])
+	}
+	return "", 0, errorAt(src, start, "unterminated quoted string")
+}
+
+// Quote returns s as a quoted string of the query language, matched
+// literally.
+func Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,88 @@
+package querylang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describe renders tokens as kind:text, with a leading + on glued ones.
+func describe(tokens []token) string {
+	var parts []string
+	for _, tok := range tokens {
+		glued := ""
+		if tok.glued {
+			glued = "+"
+		}
+		parts = append(parts, fmt.Sprintf("%s%s:%s@%d", glued, tok.kind, tok.text, tok.pos))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"", []string{`end of query:@0`}},
+		{"timeout", []string{`word:timeout@0`, `end of query:@7`}},
+		{"level:ERROR", []string{`word:level@0`, `+operator::@5`, `+word:ERROR@6`, `end of query:@11`}},
+		{"host != web-1", []string{`word:host@0`, `operator:!=@5`, `word:web-1@8`, `end of query:@13`}},
+		{`msg:"disk \"full\""`, []string{`word:msg@0`, `+operator::@3`, `+quoted string:disk "full"@4`, `end of query:@19`}},
+		{`path:C:\\tmp`, []string{`word:path@0`, `+operator::@4`, `+word:C@5`, `+operator::@6`, `+word:\\tmp@7`, `end of query:@12`}},
+		{`host:web\*`, []string{`word:host@0`, `+operator::@4`, `+word:web\*@5`, `end of query:@10`}},
+		{"(a OR b) | count by host, level", []string{
+			`"(":(@0`, `+word:a@1`, `word:OR@3`, `word:b@6`, `+")":)@7`, `"|":|@9`, `word:count@11`,
+			`word:by@17`, `word:host@20`, `+",":,@24`, `word:level@26`, `end of query:@31`,
+		}},
+		{"  ünïcode  ", []string{`word:ünïcode@2`, `end of query:@13`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tokens, err := lex(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := describe(tokens), strings.Join(tt.want, " "); got != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a ! b", `unexpected "!", did you mean "!=" or NOT at position 3`},
+		{`msg:"open`, `unterminated quoted string at position 5`},
+		{`é "x`, `unterminated quoted string at position 3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := lex(tt.src)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{"plain", `with "quotes"`, `back\slash`, `a OR b`, ""} {
+		node, err := ParseFilter("msg:" + Quote(s))
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		term, ok := node.(*Term)
+		if !ok || term.Value != s || !term.Quoted {
+			t.Errorf("%q: got %+v", s, node)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+// Package querylang parses the text query language of log searches:
+//
+//	level:ERROR AND service:(auth OR payments) AND NOT msg:"timeout" | count by host
+//
+// Terms are field:value, field=value (the same) or field!=value, or bare
+// words and quoted phrases searched in the message. Values are globs where *
+// is any run of characters and ? a single one, unless quoted. Terms combine
+// with AND, OR, NOT and parentheses; juxtaposed terms are ANDed and AND binds
+// tighter than OR. A field followed by parentheses applies to every bare value
+// inside them. An optional pipeline after | counts the matches, in total or
+// per group.
+package querylang
+
+import (
+	"slices"
+	"strings"
+)
+
+// Node is a boolean expression over logs.
+type Node interface {
+	node()
+}
+
+type And struct{ Left, Right Node }
+
+type Or struct{ Left, Right Node }
+
+type Not struct{ X Node }
+
+// Term matches a single field. Field is empty for free text searched in the
+// message, "message" for a substring or glob match of the message, a column,
+// "tags.<key>" or "metadata.<path>".
+type Term struct {
+	Field string
+	Value string
+	// Quoted values are matched literally rather than as globs.
+	Quoted bool
+	Negate bool
+	// Pos is the byte offset of the term in the query.
+	Pos int
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Term) node() {}
+
+// Pipeline counts the logs matching the filter, per distinct values of
+// GroupBy when set.
+type Pipeline struct {
+	GroupBy []string
+	// Pos is the byte offset of the | starting the pipeline.
+	Pos int
+}
+
+// Query is a parsed query. Filter is nil when the query has no filter.
+type Query struct {
+	Filter   Node
+	Pipeline *Pipeline
+}
+
+var fieldAliases = map[string]string{
+	"msg": "message",
+}
+
+// Columns are the log columns terms can match. Structured filters and
+// grouping check their fields against these lists too, so every way of
+// querying accepts the same fields.
+var Columns = []string{
+	"id", "level", "message", "source", "service", "host",
+	"template_id", "fingerprint", "trace_id", "span_id", "parent_span_id",
+}
+
+// GroupColumns are the columns logs can be counted by.
+var GroupColumns = []string{"level", "source", "service", "host"}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+// Parse parses src. Errors are of type *Error.
+func Parse(src string) (*Query, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+
+	query := &Query{}
+	if k := p.peek().kind; k != tokenEOF && k != tokenPipe {
+		if query.Filter, err = p.parseOr(""); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().kind == tokenPipe {
+		if query.Pipeline, err = p.parsePipeline(); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.unexpected(tok)
+	}
+	return query, nil
+}
+
+// ParseFilter parses a query that must not have a pipeline.
+func ParseFilter(src string) (Node, error) {
+	query, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if query.Pipeline != nil {
+		return nil, errorAt(src, query.Pipeline.Pos, "pipelines are not allowed here")
+	}
+	return query.Filter, nil
+}
+
+// SplitPipeline parses src and returns the text of its filter and its
+// pipeline, nil when it has none.
+func SplitPipeline(src string) (string, *Pipeline, error) {
+	query, err := Parse(src)
+	if err != nil {
+		return "", nil, err
+	}
+	if query.Pipeline == nil {
+		return src, nil, nil
+	}
+	return strings.TrimSpace(src[:query.Pipeline.Pos]), query.Pipeline, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) keyword(tok token, word string) bool {
+	return tok.kind == tokenWord && tok.text == word
+}
+
+func (p *parser) unexpected(tok token) error {
+	if tok.kind == tokenEOF {
+		return errorAt(p.src, tok.pos, "unexpected end of query")
+	}
+	return errorAt(p.src, tok.pos, "unexpected %s %q", tok.kind, tok.text)
+}
+
+// parseOr parses a disjunction. field is set inside the parentheses
+// following a field, where bare values apply to it.
+func (p *parser) parseOr(field string) (Node, error) {
+	left, err := p.parseAnd(field)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(field string) (Node, error) {
+	left, err := p.parseNot(field)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		switch {
+		case p.keyword(tok, "AND"):
+			p.next()
+		case tok.kind == tokenWord && tok.text != "OR", tok.kind == tokenString, tok.kind == tokenLParen:
+			// juxtaposed terms are ANDed
+		default:
+			return left, nil
+		}
+		right, err := p.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseNot(field string) (Node, error) {
+	if p.keyword(p.peek(), "NOT") {
+		p.next()
+		x, err := p.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	return p.parsePrimary(field)
+}
+
+func (p *parser) parsePrimary(field string) (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		x, err := p.parseOr(field)
+		if err != nil {
+			return nil, err
+		}
+		switch closing := p.next(); closing.kind {
+		case tokenRParen:
+		case tokenEOF:
+			return nil, errorAt(p.src, tok.pos, `unclosed "("`)
+		default:
+			return nil, errorAt(p.src, closing.pos, `expected ")" before %q`, closing.text)
+		}
+		return x, nil
+
+	case tokenString:
+		return &Term{Field: field, Value: tok.text, Quoted: true, Pos: tok.pos}, nil
+
+	case tokenWord:
+		if op := p.peek(); op.kind == tokenOp && op.glued {
+			if field != "" {
+				return nil, errorAt(p.src, tok.pos, "field %q inside the values of %q", tok.text, field)
+			}
+			return p.parseTerm(tok)
+		}
+		if tok.text == "AND" || tok.text == "OR" {
+			return nil, p.unexpected(tok)
+		}
+		return &Term{Field: field, Value: tok.text, Pos: tok.pos}, nil
+	}
+	return nil, p.unexpected(tok)
+}
+
+// parseTerm parses the operator and value following the field name in name.
+func (p *parser) parseTerm(name token) (Node, error) {
+	field, err := p.field(name)
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+
+	value := p.peek()
+	switch {
+	case value.kind == tokenLParen && op.text != "!=":
+		return p.parsePrimary(field)
+	case value.kind == tokenLParen:
+		x, err := p.parsePrimary(field)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	case value.kind != tokenWord && value.kind != tokenString, !value.glued:
+		return nil, errorAt(p.src, op.pos, "expected a value after %s%s", name.text, op.text)
+	}
+	p.next()
+
+	return &Term{
+		Field:  field,
+		Value:  value.text,
+		Quoted: value.kind == tokenString,
+		Negate: op.text == "!=",
+		Pos:    name.pos,
+	}, nil
+}
+
+// field resolves and checks a field name.
+func (p *parser) field(name token) (string, error) {
+	field := name.text
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+	if slices.Contains(Columns, field) {
+		return field, nil
+	}
+	if key, ok := strings.CutPrefix(field, "tags."); ok && key != "" {
+		return field, nil
+	}
+	if path, ok := strings.CutPrefix(field, "metadata."); ok && !slices.Contains(strings.Split(path, "."), "") {
+		return field, nil
+	}
+	return "", errorAt(p.src, name.pos, "unknown field %q", name.text)
+}
+
+func (p *parser) parsePipeline() (*Pipeline, error) {
+	pipe := p.next()
+	if tok := p.next(); !p.keyword(tok, "count") {
+		return nil, errorAt(p.src, tok.pos, `expected "count" after "|"`)
+	}
+
+	pipeline := &Pipeline{Pos: pipe.pos}
+	if !p.keyword(p.peek(), "by") {
+		return pipeline, nil
+	}
+	p.next()
+
+	for {
+		tok := p.next()
+		if tok.kind != tokenWord {
+			return nil, errorAt(p.src, tok.pos, "expected a field to count by")
+		}
+		key, isTag := strings.CutPrefix(tok.text, "tags.")
+		if !slices.Contains(GroupColumns, tok.text) && (!isTag || key == "") {
+			return nil, errorAt(p.src, tok.pos, "cannot count by %q", tok.text)
+		}
+		for _, field := range pipeline.GroupBy {
+			if field == tok.text {
+				return nil, errorAt(p.src, tok.pos, "%q is counted by twice", tok.text)
+			}
+		}
+		pipeline.GroupBy = append(pipeline.GroupBy, tok.text)
+
+		if p.peek().kind != tokenComma {
+			return pipeline, nil
+		}
+		p.next()
+	}
+}
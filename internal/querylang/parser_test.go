@@ -0,0 +1,177 @@
+package querylang
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// format renders a node in prefix form, e.g. (and service:api (not "x")).
+func format(n Node) string {
+	switch n := n.(type) {
+	case nil:
+		return "<nil>"
+	case *And:
+		return fmt.Sprintf("(and %s %s)", format(n.Left), format(n.Right))
+	case *Or:
+		return fmt.Sprintf("(or %s %s)", format(n.Left), format(n.Right))
+	case *Not:
+		return fmt.Sprintf("(not %s)", format(n.X))
+	case *Term:
+		value := n.Value
+		if n.Quoted {
+			value = fmt.Sprintf("%q", value)
+		}
+		op := ":"
+		if n.Negate {
+			op = "!="
+		}
+		if n.Field == "" {
+			return value
+		}
+		return n.Field + op + value
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "<nil>"},
+		{"   ", "<nil>"},
+		{"timeout", "timeout"},
+		{`"disk full"`, `"disk full"`},
+		{"service:api", "service:api"},
+		{"service=api", "service:api"},
+		{"service!=api", "service!=api"},
+		{"msg:timeout", "message:timeout"},
+		{`message:"connection reset"`, `message:"connection reset"`},
+		{"host:web-*", "host:web-*"},
+		{"tags.env:prod", "tags.env:prod"},
+		{"metadata.user.id:42", "metadata.user.id:42"},
+		{"a b c", "(and (and a b) c)"},
+		{"a AND b OR c", "(or (and a b) c)"},
+		{"a OR b AND c", "(or a (and b c))"},
+		{"a OR b c", "(or a (and b c))"},
+		{"(a OR b) c", "(and (or a b) c)"},
+		{"NOT a b", "(and (not a) b)"},
+		{"NOT NOT a", "(not (not a))"},
+		{"NOT (a OR b)", "(not (or a b))"},
+		{"service:(auth OR payments)", "(or service:auth service:payments)"},
+		{`service:(auth "pay*")`, `(and service:auth service:"pay*")`},
+		{"service!=(auth OR payments)", "(not (or service:auth service:payments))"},
+		{"level:ERROR AND NOT msg:\"timeout\"", `(and level:ERROR (not message:"timeout"))`},
+		{"and or not", "(and (and and or) not)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			node, err := ParseFilter(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := format(node); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		src     string
+		filter  string
+		groupBy []string
+	}{
+		{"| count", "<nil>", nil},
+		{"level:ERROR | count", "level:ERROR", nil},
+		{"level:ERROR | count by host", "level:ERROR", []string{"host"}},
+		{"a | count by service, tags.env", "a", []string{"service", "tags.env"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			query, err := Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := format(query.Filter); got != tt.filter {
+				t.Errorf("got filter %s, want %s", got, tt.filter)
+			}
+			if query.Pipeline == nil {
+				t.Fatal("got no pipeline")
+			}
+			if !slices.Equal(query.Pipeline.GroupBy, tt.groupBy) {
+				t.Errorf("got group by %v, want %v", query.Pipeline.GroupBy, tt.groupBy)
+			}
+		})
+	}
+}
+
+func TestSplitPipeline(t *testing.T) {
+	filter, pipeline, err := SplitPipeline("level:ERROR service:api | count by host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter != "level:ERROR service:api" || pipeline == nil || !slices.Equal(pipeline.GroupBy, []string{"host"}) {
+		t.Errorf("got %q, %+v", filter, pipeline)
+	}
+
+	filter, pipeline, err = SplitPipeline("level:ERROR")
+	if err != nil || filter != "level:ERROR" || pipeline != nil {
+		t.Errorf("got %q, %+v, %v", filter, pipeline, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"sevice:api", `unknown field "sevice" at position 1`},
+		{"level:ERROR AND sevice:api", `unknown field "sevice" at position 17`},
+		{"metadata..id:1", `unknown field "metadata..id" at position 1`},
+		{"tags.:x", `unknown field "tags." at position 1`},
+		{"(a OR b", `unclosed "(" at position 1`},
+		{"a (b", `unclosed "(" at position 3`},
+		{"(a, b)", `expected ")" before "," at position 3`},
+		{"a)", `unexpected ")" ")" at position 2`},
+		{"a AND", "unexpected end of query at position 6"},
+		{"OR a", `unexpected word "OR" at position 1`},
+		{"a AND OR b", `unexpected word "OR" at position 7`},
+		{"service:", "expected a value after service: at position 8"},
+		{"service: api", "expected a value after service: at position 8"},
+		{"service:(host:a)", `field "host" inside the values of "service" at position 10`},
+		{"a | sum", `expected "count" after "|" at position 5`},
+		{"a | count by", "expected a field to count by at position 13"},
+		{"a | count by message", `cannot count by "message" at position 14`},
+		{"a | count by host, host", `"host" is counted by twice at position 20`},
+		{"a | count b", `unexpected word "b" at position 11`},
+		{"service : api", `unexpected operator ":" at position 9`},
+		{"é sevice:x", `unknown field "sevice" at position 3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got %q, want %q", err, tt.want)
+			}
+			if strings.Count(err.Error(), "at position") != 1 {
+				t.Errorf("%q names more than one position", err)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejectsPipelines(t *testing.T) {
+	_, err := ParseFilter("level:ERROR | count")
+	if err == nil || err.Error() != "pipelines are not allowed here at position 13" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// Load reads a JSON array of retention policies. An empty path means no
+// policies, so nothing is ever deleted.
+func Load(path string) ([]models.RetentionPolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retention config: %w", err)
+	}
+
+	var policies []models.RetentionPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse retention config: %w", err)
+	}
+
+	if err := Validate(policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// Validate rejects unnamed policies and policies with identical selectors,
+// since it would be ambiguous which one applies.
+func Validate(policies []models.RetentionPolicy) error {
+	seen := make(map[string]string)
+	for i, p := range policies {
+		if p.Name == "" {
+			return fmt.Errorf("retention policy %d has no name", i)
+		}
+		if p.MaxAge <= 0 {
+			return fmt.Errorf("retention policy %q has no max_age", p.Name)
+		}
+
+		key := p.Service + "\x00" + string(p.Level)
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("retention policies %q and %q select the same logs", other, p.Name)
+		}
+		seen[key] = p.Name
+	}
+	return nil
+}
+
+// Scopes resolves each policy into the set of logs it deletes at now,
+// excluding logs governed by a more specific policy.
+func Scopes(policies []models.RetentionPolicy, now time.Time) []models.RetentionScope {
+	scopes := make([]models.RetentionScope, 0, len(policies))
+	for _, p := range policies {
+		scope := models.RetentionScope{Policy: p, Before: now.Add(-p.MaxAge)}
+		for _, other := range policies {
+			if other.Specificity() > p.Specificity() && other.Overlaps(p) {
+				scope.Except = append(scope.Except, other)
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// PartitionCutoff returns the time before which every log is expired under
+// every policy, so whole time partitions ending before it can be dropped.
+// Without a catch-all policy some logs are kept forever and there is no
+// cutoff.
+func PartitionCutoff(policies []models.RetentionPolicy, now time.Time) (time.Time, bool) {
+	catchAll := false
+	var longest time.Duration
+	for _, p := range policies {
+		catchAll = catchAll || p.Specificity() == 0
+		longest = max(longest, p.MaxAge)
+	}
+
+	if !catchAll {
+		return time.Time{}, false
+	}
+	return now.Add(-longest), true
+}
+
+// KeepUnarchived moves cutoff back to archivedUntil, the end of the archive,
+// so logs are only deleted once archived. A zero archivedUntil, an empty
+// archive, keeps every log.
+func KeepUnarchived(cutoff, archivedUntil time.Time) time.Time {
+	if cutoff.After(archivedUntil) {
+		return archivedUntil
+	}
+	return cutoff
+}
@@ -0,0 +1,163 @@
+package retention
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+var (
+	all       = models.RetentionPolicy{Name: "all", MaxAge: 30 * 24 * time.Hour}
+	errorLogs = models.RetentionPolicy{Name: "errors", Level: models.ERROR, MaxAge: 90 * 24 * time.Hour}
+	debugLogs = models.RetentionPolicy{Name: "debug", Level: models.DEBUG, MaxAge: 24 * time.Hour}
+	api       = models.RetentionPolicy{Name: "api", Service: "api", MaxAge: 7 * 24 * time.Hour}
+	apiError  = models.RetentionPolicy{Name: "api-errors", Service: "api", Level: models.ERROR, MaxAge: 180 * 24 * time.Hour}
+	billing   = models.RetentionPolicy{Name: "billing", Service: "billing", MaxAge: 365 * 24 * time.Hour}
+)
+
+func policyNames(policies []models.RetentionPolicy) []string {
+	names := []string{}
+	for _, p := range policies {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestScopes(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		policies []models.RetentionPolicy
+		// except maps each policy to the policies it leaves alone
+		except map[string][]string
+	}{
+		{
+			name:     "a single catch-all",
+			policies: []models.RetentionPolicy{all},
+			except:   map[string][]string{"all": {}},
+		},
+		{
+			name:     "the catch-all leaves every narrower policy alone",
+			policies: []models.RetentionPolicy{all, errorLogs, api, apiError},
+			except: map[string][]string{
+				"all":        {"errors", "api", "api-errors"},
+				"errors":     {"api", "api-errors"},
+				"api":        {"api-errors"},
+				"api-errors": {},
+			},
+		},
+		{
+			name:     "a service outranks a level",
+			policies: []models.RetentionPolicy{errorLogs, api},
+			except: map[string][]string{
+				"errors": {"api"},
+				"api":    {},
+			},
+		},
+		{
+			name:     "policies that cannot overlap ignore each other",
+			policies: []models.RetentionPolicy{errorLogs, debugLogs, api, billing},
+			except: map[string][]string{
+				"errors":  {"api", "billing"},
+				"debug":   {"api", "billing"},
+				"api":     {},
+				"billing": {},
+			},
+		},
+		{
+			name:     "a service and level policy only overlaps its own service and level",
+			policies: []models.RetentionPolicy{debugLogs, billing, apiError},
+			except: map[string][]string{
+				"debug":      {"billing"},
+				"billing":    {},
+				"api-errors": {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes := Scopes(tt.policies, now)
+			if len(scopes) != len(tt.policies) {
+				t.Fatalf("got %d scopes for %d policies", len(scopes), len(tt.policies))
+			}
+			for i, scope := range scopes {
+				p := tt.policies[i]
+				if scope.Policy.Name != p.Name {
+					t.Errorf("scope %d is for %q, want %q", i, scope.Policy.Name, p.Name)
+				}
+				if want := now.Add(-p.MaxAge); !scope.Before.Equal(want) {
+					t.Errorf("%s: got cutoff %s, want %s", p.Name, scope.Before, want)
+				}
+				if got := policyNames(scope.Except); !slices.Equal(got, tt.except[p.Name]) {
+					t.Errorf("%s: got exceptions %v, want %v", p.Name, got, tt.except[p.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionCutoff(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		policies []models.RetentionPolicy
+		want     time.Duration
+		ok       bool
+	}{
+		{"no policies", nil, 0, false},
+		{"a catch-all", []models.RetentionPolicy{all}, all.MaxAge, true},
+		{"the longest policy wins", []models.RetentionPolicy{all, errorLogs, debugLogs}, errorLogs.MaxAge, true},
+		{"a narrower policy outliving the catch-all", []models.RetentionPolicy{api, all, billing}, billing.MaxAge, true},
+		{"without a catch-all some logs are kept", []models.RetentionPolicy{errorLogs, debugLogs, api}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cutoff, ok := PartitionCutoff(tt.policies, now)
+			if ok != tt.ok {
+				t.Fatalf("got ok %v, want %v", ok, tt.ok)
+			}
+			if ok && !cutoff.Equal(now.Add(-tt.want)) {
+				t.Errorf("got cutoff %s, want %s", cutoff, now.Add(-tt.want))
+			}
+		})
+	}
+}
+
+func TestKeepUnarchived(t *testing.T) {
+	cutoff := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		archived time.Time
+		want     time.Time
+	}{
+		{"archived past the cutoff", cutoff.Add(time.Hour), cutoff},
+		{"archived up to the cutoff", cutoff, cutoff},
+		{"archived short of the cutoff", cutoff.Add(-time.Hour), cutoff.Add(-time.Hour)},
+		{"nothing archived", time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := KeepUnarchived(cutoff, tt.archived); !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []models.RetentionPolicy
+		valid    bool
+	}{
+		{"distinct selectors", []models.RetentionPolicy{all, errorLogs, api, apiError}, true},
+		{"no name", []models.RetentionPolicy{{MaxAge: time.Hour}}, false},
+		{"no max age", []models.RetentionPolicy{{Name: "a"}}, false},
+		{"the same selector twice", []models.RetentionPolicy{errorLogs, {Name: "errors-again", Level: models.ERROR, MaxAge: time.Hour}}, false},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.policies); (err == nil) != tt.valid {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/archive"
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// errArchiverStopped is returned by Archiver.Reconcile when the archiver
+// stopped before it was done.
+var errArchiverStopped = errors.New("archiver stopped")
+
+// Archiver periodically copies closed time ranges of logs into archive
+// segments, oldest first.
+type Archiver struct {
+	storage   storage.LogStore
+	archive   *archive.Archive
+	segment   time.Duration
+	delay     time.Duration
+	interval  time.Duration
+	batchSize int
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+func NewArchiver(store storage.LogStore, a *archive.Archive, cfg *config.ArchiveConfig) *Archiver {
+	return &Archiver{
+		storage:   store,
+		archive:   a,
+		segment:   cfg.Segment,
+		delay:     cfg.Delay,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+		stopChan:  make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+}
+
+func (a *Archiver) Start() {
+	defer close(a.doneChan)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.Archive(time.Now()); err != nil {
+			slog.Warn("failed to archive logs", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-a.stopChan:
+			return
+		}
+	}
+}
+
+// Stop ends the archiving loop, waiting for the segment being written, if
+// any, to be finished.
+func (a *Archiver) Stop() {
+	close(a.stopChan)
+	<-a.doneChan
+}
+
+// Archive writes a segment for every range past the end of the archive that
+// closed at least the configured delay before now, skipping ranges without
+// logs, and returns the segments it wrote. Ranges are aligned on the Unix
+// epoch.
+func (a *Archiver) Archive(now time.Time) ([]archive.Segment, error) {
+	var written []archive.Segment
+	step := int64(a.segment / time.Second)
+	closed := now.Add(-a.delay)
+	next := a.archive.ArchivedUntil()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return written, nil
+		default:
+		}
+
+		// jump to the oldest log not archived yet
+		query := models.LogQuery{Order: models.OrderAsc, Limit: 1}
+		if !next.IsZero() {
+			query.StartTime = &next
+		}
+		oldest, err := a.storage.QueryLogs(query)
+		if err != nil || len(oldest) == 0 {
+			return written, err
+		}
+
+		from := time.Unix(oldest[0].Timestamp.Unix()/step*step, 0).UTC()
+		to := from.Add(a.segment)
+		if to.After(closed) {
+			return written, nil
+		}
+
+		seg, err := archive.Export(a.storage, a.archive, from, to, a.batchSize)
+		if err != nil {
+			return written, err
+		}
+		slog.Info("archived logs", slog.String("file", seg.File), slog.Int64("rows", seg.Rows))
+		written = append(written, seg)
+		next = to
+	}
+}
+
+// Reconcile makes sure every log of expired in scope is archived before it is
+// deleted: logs that arrived after their range was archived are added to its
+// segment, and ranges that were never archived are exported. It returns the
+// segments it wrote.
+// Each call reads the segments holding logs in scope, which once retention
+// keeps up is about one segment per policy.
+func (a *Archiver) Reconcile(expired storage.RetentionStore, scope models.RetentionScope) ([]archive.Segment, error) {
+	var written []archive.Segment
+	step := int64(a.segment / time.Second)
+	since := time.Unix(0, 0).UTC()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return written, errArchiverStopped
+		default:
+		}
+
+		oldest, ok, err := expired.OldestExpired(scope, since)
+		if err != nil || !ok {
+			return written, err
+		}
+
+		seg, ok := a.segmentAt(oldest)
+		if !ok {
+			from := time.Unix(oldest.Unix()/step*step, 0).UTC()
+			if seg, err = archive.Export(a.storage, a.archive, from, from.Add(a.segment), a.batchSize); err != nil {
+				return written, err
+			}
+			if seg.Rows > 0 {
+				slog.Info("archived late logs", slog.String("file", seg.File), slog.Int64("rows", seg.Rows))
+				written = append(written, seg)
+			}
+			since = from.Add(a.segment)
+			continue
+		}
+
+		seg, added, err := archive.Reconcile(a.storage, a.archive, seg, a.batchSize)
+		if err != nil {
+			return written, err
+		}
+		if added > 0 {
+			slog.Info("archived late logs", slog.String("file", seg.File), slog.Int64("rows", added))
+			written = append(written, seg)
+		}
+		since = seg.To
+	}
+}
+
+// segmentAt returns the segment whose range holds ts.
+func (a *Archiver) segmentAt(ts time.Time) (archive.Segment, bool) {
+	for _, seg := range a.archive.Segments() {
+		if !ts.Before(seg.From) && ts.Before(seg.To) {
+			return seg, true
+		}
+	}
+	return archive.Segment{}, false
+}
@@ -2,36 +2,60 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"github.com/krishnaGauss/SoCode/internal/config"
 	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
 	"github.com/krishnaGauss/SoCode/internal/storage"
 	"github.com/krishnaGauss/SoCode/proto/SoCode/proto"
 
 	"github.com/google/uuid"
 	// "google.golang.org/grpc"
-    "google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+var errShuttingDown = status.Error(codes.Unavailable, "server is shutting down")
+
 type LogServer struct {
 	proto.UnimplementedLogServiceServer
-	queue   *storage.RedisQueue
-	storage *storage.PostgresStorage
+	queue      storage.Queue
+	storage    storage.LogStore
+	countLimit int
+	facetLimit int
+	draining   atomic.Bool
 }
 
-func NewLogServer(queue *storage.RedisQueue, storage *storage.PostgresStorage) *LogServer {
+func NewLogServer(queue storage.Queue, storage storage.LogStore, cfg *config.QueryConfig) *LogServer {
 	return &LogServer{
-		queue:   queue,
-		storage: storage,
+		queue:      queue,
+		storage:    storage,
+		countLimit: cfg.CountLimit,
+		facetLimit: cfg.FacetLimit,
 	}
 }
 
+// StopAccepting makes the server reject new logs with codes.Unavailable so
+// clients retry against another instance, and ends open streams after their
+// current message.
+func (s *LogServer) StopAccepting() {
+	s.draining.Store(true)
+}
+
 func (s *LogServer) SendLog(ctx context.Context, req *proto.LogRequest) (*proto.LogResponse, error) {
+	if s.draining.Load() {
+		return nil, errShuttingDown
+	}
+
 	log := s.protoToModel(req)
 
-	if err := s.queue.EnqueueLog(log); err != nil {
+	if err := s.queue.Enqueue(log); err != nil {
 		return &proto.LogResponse{
 			Success: false,
 			Message: fmt.Sprintf("failed to enqueue log: %v", err),
@@ -40,115 +64,334 @@ func (s *LogServer) SendLog(ctx context.Context, req *proto.LogRequest) (*proto.
 	}
 
 	return &proto.LogResponse{
-        Success: true,
-        Message: "Log received successfully",
-    }, nil
+		Success: true,
+		Message: "Log received successfully",
+	}, nil
 }
 
 func (s *LogServer) SendLogStream(stream proto.LogService_SendLogStreamServer) error {
-	count:=0
+	count := 0
 
-	for{
-		req, err:=stream.Recv()
-		if err!=nil{
+	for {
+		req, err := stream.Recv()
+		if err != nil {
 			break
 		}
 
-		log:=s.protoToModel(req)
-		if err:=s.queue.EnqueueLog(log); err!=nil{
+		log := s.protoToModel(req)
+		if err := s.queue.Enqueue(log); err != nil {
 			slog.Info("failed to enqueue log:", slog.String(" ", err.Error()))
-            continue
+		} else {
+			count++
+		}
+
+		// checked once a message arrives, as Recv blocks until then; the
+		// message that woke it is still kept
+		if s.draining.Load() {
+			break
 		}
-		count++
 	}
 
 	return stream.SendAndClose(&proto.LogResponse{
-        Success: true,
-        Message: fmt.Sprintf("Processed %d logs", count),
-    })
+		Success: true,
+		Message: fmt.Sprintf("Processed %d logs", count),
+	})
+}
+
+func (s *LogServer) QueryLogs(ctx context.Context, req *proto.QueryRequest) (*proto.QueryResponse, error) {
+	query, err := queryFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, pipeline, err := querylang.SplitPipeline(query.Expr)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if pipeline != nil {
+		query.Expr = filter
+		return s.countLogs(query, pipeline)
+	}
+
+	countLimit := s.countLimit
+	switch req.TotalMode {
+	case "", "auto", "none":
+	case "exact":
+		countLimit = 0
+	default:
+		return nil, status.Error(codes.InvalidArgument, "total_mode must be auto, exact or none")
+	}
+
+	logs, err := s.storage.QueryLogs(query)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	response := &proto.QueryResponse{}
+	if req.TotalMode != "none" {
+		total, err := s.storage.CountLogs(query, countLimit)
+		if err != nil {
+			return nil, queryError(err)
+		}
+		response.Total = total.Value
+		response.TotalRelation = total.Relation
+	}
+
+	next, prev := models.PageCursors(query, logs)
+	if next != nil {
+		response.NextCursor = next.String()
+	}
+	if prev != nil {
+		response.PrevCursor = prev.String()
+	}
+
+	for _, log := range logs {
+		response.Logs = append(response.Logs, s.modelToProto(log))
+	}
+
+	return response, nil
 }
 
-func (s *LogServer) QueryLogs(ctx context.Context, req *proto.QueryRequest) (*proto.QueryResponse, error){
-	query:=models.LogQuery{
-		Search: req.Search,
-        Limit:  int(req.Limit),
-        Offset: int(req.Offset),
+// countLogs runs the count pipeline of a query language expression.
+func (s *LogServer) countLogs(query models.LogQuery, pipeline *querylang.Pipeline) (*proto.QueryResponse, error) {
+	buckets, err := s.storage.AggregateLogs(models.AggregateQuery{Query: query, GroupBy: pipeline.GroupBy})
+	if err != nil {
+		return nil, queryError(err)
 	}
 
-	if req.StartTime!=nil{
-		startTime:=req.StartTime.AsTime()
-		query.StartTime=&startTime
+	response := &proto.QueryResponse{}
+	for _, bucket := range buckets {
+		for _, group := range bucket.Groups {
+			response.Counts = append(response.Counts, &proto.AggregateCount{
+				Group: group.Group,
+				Other: group.Other,
+				Count: group.Count,
+			})
+		}
+	}
+	return response, nil
+}
+
+// queryFromProto converts the filters of a QueryRequest.
+func queryFromProto(req *proto.QueryRequest) (models.LogQuery, error) {
+	query := models.LogQuery{
+		Search:     req.Search,
+		Expr:       req.Q,
+		SearchMode: models.SearchMode(req.SearchMode),
+		Sort:       req.Sort,
+		Order:      req.Order,
+		Limit:      int(req.Limit),
+		Offset:     int(req.Offset),
+	}
+
+	if req.StartTime != nil {
+		startTime := req.StartTime.AsTime()
+		query.StartTime = &startTime
 	}
 
 	if req.EndTime != nil {
-        endTime := req.EndTime.AsTime()
-        query.EndTime = &endTime
-    }
+		endTime := req.EndTime.AsTime()
+		query.EndTime = &endTime
+	}
 
-	for _, level:=range req.Levels{
+	for _, level := range req.Levels {
 		query.Level = append(query.Level, models.LogLevel(level))
 	}
 
-	 query.Source = req.Sources
-    query.Service = req.Services
-    query.Host = req.Hosts
-    query.Tags = req.Tags
+	query.Source = req.Sources
+	query.Service = req.Services
+	query.Host = req.Hosts
+	query.Tags = req.Tags
+
+	for _, filter := range req.Filters {
+		f, err := models.ParseFilter(filter)
+		if err != nil {
+			return models.LogQuery{}, status.Error(codes.InvalidArgument, err.Error())
+		}
+		query.Filters = append(query.Filters, f)
+	}
+
+	if req.Cursor != "" {
+		cursor, err := models.ParseCursor(req.Cursor)
+		if err != nil {
+			return models.LogQuery{}, status.Error(codes.InvalidArgument, err.Error())
+		}
+		query.Cursor = cursor
+	}
+
+	return query, nil
+}
+
+// AggregateLogs counts logs per time bucket and group.
+func (s *LogServer) AggregateLogs(ctx context.Context, req *proto.AggregateRequest) (*proto.AggregateResponse, error) {
+	aggregate := models.AggregateQuery{
+		GroupBy: req.GroupBy,
+		TopN:    int(req.TopN),
+	}
+	if req.Query != nil {
+		query, err := queryFromProto(req.Query)
+		if err != nil {
+			return nil, err
+		}
+		aggregate.Query = query
+	}
+	if req.Interval != "" {
+		interval, err := models.ParseDuration(req.Interval)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		aggregate.Interval = interval
+	}
+
+	buckets, err := s.storage.AggregateLogs(aggregate)
+	if err != nil {
+		return nil, queryError(err)
+	}
+
+	response := &proto.AggregateResponse{}
+	for _, bucket := range buckets {
+		b := &proto.AggregateBucket{}
+		if bucket.Start != nil {
+			b.Start = timestamppb.New(*bucket.Start)
+		}
+		for _, group := range bucket.Groups {
+			b.Groups = append(b.Groups, &proto.AggregateCount{
+				Group: group.Group,
+				Other: group.Other,
+				Count: group.Count,
+			})
+		}
+		response.Buckets = append(response.Buckets, b)
+	}
+	return response, nil
+}
+
+// GetFacets lists the values of each field in req.Fields with their counts.
+func (s *LogServer) GetFacets(ctx context.Context, req *proto.FacetRequest) (*proto.FacetResponse, error) {
+	var query models.LogQuery
+	if req.Query != nil {
+		var err error
+		if query, err = queryFromProto(req.Query); err != nil {
+			return nil, err
+		}
+		query.Limit = 0
+	}
 
-	 logs, err := s.storage.QueryLogs(query)
-    if err != nil {
-        return nil, err
-    }
+	limit := storage.DefaultFacetLimit
+	if req.Limit > 0 {
+		limit = int(req.Limit)
+	}
 
-    response := &proto.QueryResponse{
-        Total: int32(len(logs)),
+	fields := req.Fields
+	if len(fields) == 0 {
+		fields = storage.FacetFields
 	}
 
-	 for _, log := range logs {
-        response.Logs = append(response.Logs, s.modelToProto(log))
-    }
+	response := &proto.FacetResponse{}
+	for _, field := range fields {
+		facet, err := s.storage.FacetLogs(models.FacetQuery{
+			Query:  query,
+			Field:  field,
+			Prefix: req.Prefix,
+			Limit:  min(limit, s.facetLimit),
+		})
+		if err != nil {
+			return nil, queryError(err)
+		}
 
-    return response, nil
+		f := &proto.Facet{Field: facet.Field, Truncated: facet.Truncated}
+		for _, value := range facet.Values {
+			f.Values = append(f.Values, &proto.FacetValue{Value: value.Value, Count: value.Count})
+		}
+		response.Facets = append(response.Facets, f)
+	}
+	return response, nil
+}
+
+// GetQueueStats reports how many logs are waiting in each lane. Queues
+// without lanes report everything under the default lane.
+func (s *LogServer) GetQueueStats(ctx context.Context, req *proto.QueueStatsRequest) (*proto.QueueStatsResponse, error) {
+	response := &proto.QueueStatsResponse{Lanes: make(map[string]int64)}
+
+	if lanes, ok := s.queue.(storage.LaneQueue); ok {
+		lens, err := lanes.LaneLen()
+		if err != nil {
+			return nil, err
+		}
+		for lane, n := range lens {
+			response.Lanes[string(lane)] = n
+			response.Total += n
+		}
+		return response, nil
+	}
+
+	n, err := s.queue.Len()
+	if err != nil {
+		return nil, err
+	}
+	response.Lanes[string(storage.LaneDefault)] = n
+	response.Total = n
+	return response, nil
 }
 
 func (s *LogServer) protoToModel(req *proto.LogRequest) models.LogEntry {
-	log:= models.LogEntry{
+	log := models.LogEntry{
 		ID:      req.Id,
-        Level:   models.LogLevel(req.Level),
-        Message: req.Message,
-        Source:  req.Source,
-        Service: req.Service,
-        Host:    req.Host,
-        Tags:    req.Tags,
+		Level:   models.LogLevel(req.Level),
+		Message: req.Message,
+		Source:  req.Source,
+		Service: req.Service,
+		Host:    req.Host,
+		Tags:    req.Tags,
+
+		TraceID:      req.TraceId,
+		SpanID:       req.SpanId,
+		ParentSpanID: req.ParentSpanId,
 	}
 
-	if req.Id==""{
-		log.ID=uuid.New().String()
+	if req.Id == "" {
+		log.ID = uuid.New().String()
 	}
 
-	 if req.Timestamp != nil {
-        log.Timestamp = req.Timestamp.AsTime()
-    } else {
-        log.Timestamp = time.Now()
-    }
+	if req.Timestamp != nil {
+		log.Timestamp = req.Timestamp.AsTime()
+	} else {
+		log.Timestamp = time.Now()
+	}
 
-    if req.Metadata != "" {
-        log.Metadata = []byte(req.Metadata)
-    }
+	if req.Metadata != "" {
+		log.Metadata = []byte(req.Metadata)
+	}
 
-    return log
+	return log
 }
 
 func (s *LogServer) modelToProto(log models.LogEntry) *proto.LogRequest {
-    return &proto.LogRequest{
-        Id:        log.ID,
-        Timestamp: timestamppb.New(log.Timestamp),
-        Level:     string(log.Level),
-        Message:   log.Message,
-        Source:    log.Source,
-        Service:   log.Service,
-        Host:      log.Host,
-        Tags:      log.Tags,
-        Metadata:  string(log.Metadata),
-    }
+	return &proto.LogRequest{
+		Id:           log.ID,
+		Timestamp:    timestamppb.New(log.Timestamp),
+		Level:        string(log.Level),
+		Message:      log.Message,
+		Source:       log.Source,
+		Service:      log.Service,
+		Host:         log.Host,
+		Tags:         log.Tags,
+		Metadata:     string(log.Metadata),
+		Rank:         log.Rank,
+		Highlight:    log.Highlight,
+		Cold:         log.Cold,
+		TemplateId:   log.TemplateID,
+		Fingerprint:  log.Fingerprint,
+		TraceId:      log.TraceID,
+		SpanId:       log.SpanID,
+		ParentSpanId: log.ParentSpanID,
+	}
 }
 
+// queryError reports invalid queries to clients as InvalidArgument.
+func queryError(err error) error {
+	if errors.Is(err, storage.ErrInvalidQuery) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
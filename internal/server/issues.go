@@ -0,0 +1,86 @@
+package server
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/issues"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// IssueTracker fingerprints ERROR and FATAL logs as a Processor and, as an
+// Observer, counts them into issues once they are stored.
+type IssueTracker struct {
+	store storage.IssueStore
+}
+
+func NewIssueTracker(store storage.IssueStore) *IssueTracker {
+	return &IssueTracker{store: store}
+}
+
+// Process returns a copy of logs with Fingerprint set on errors.
+func (t *IssueTracker) Process(logs []models.LogEntry) []models.LogEntry {
+	out := make([]models.LogEntry, len(logs))
+	for i, log := range logs {
+		if issues.Tracked(log) {
+			log.Fingerprint = issues.Fingerprint(log)
+		}
+		out[i] = log
+	}
+	return out
+}
+
+// Stored records the fingerprinted logs of a stored batch, one occurrence
+// per issue.
+func (t *IssueTracker) Stored(logs []models.LogEntry) {
+	byID := make(map[string]*models.Issue)
+	var occurrences []*models.Issue
+	for _, log := range logs {
+		if log.Fingerprint == "" {
+			continue
+		}
+		occ, ok := byID[log.Fingerprint]
+		if !ok {
+			occ = &models.Issue{
+				ID:        log.Fingerprint,
+				Service:   log.Service,
+				Level:     models.LogLevel(strings.ToUpper(string(log.Level))),
+				Title:     issues.Title(log),
+				FirstSeen: log.Timestamp,
+				LastSeen:  log.Timestamp,
+				LastLogID: log.ID,
+			}
+			byID[log.Fingerprint] = occ
+			occurrences = append(occurrences, occ)
+		}
+		occ.Count++
+		if !slices.Contains(occ.Hosts, log.Host) {
+			occ.Hosts = append(occ.Hosts, log.Host)
+		}
+		if log.Timestamp.Before(occ.FirstSeen) {
+			occ.FirstSeen = log.Timestamp
+		}
+		if !log.Timestamp.Before(occ.LastSeen) {
+			occ.LastSeen, occ.LastLogID = log.Timestamp, log.ID
+		}
+	}
+	if len(occurrences) == 0 {
+		return
+	}
+
+	batch := make([]models.Issue, len(occurrences))
+	for i, occ := range occurrences {
+		batch[i] = *occ
+	}
+	regressed, err := t.store.RecordIssues(batch)
+	if err != nil {
+		slog.Warn("failed to record issues", slog.String("error", err.Error()))
+		return
+	}
+	for _, issue := range regressed {
+		slog.Warn("resolved issue occurred again",
+			slog.String("issue", issue.ID), slog.String("service", issue.Service), slog.String("title", issue.Title))
+	}
+}
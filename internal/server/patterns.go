@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/patterns"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// PatternMiner is a Processor setting the template of every log and saving
+// new and changed templates to the store.
+type PatternMiner struct {
+	miner *patterns.Miner
+	store storage.PatternStore
+}
+
+// NewPatternMiner starts from the templates already saved in store, so logs
+// keep getting the same template IDs across restarts.
+func NewPatternMiner(store storage.PatternStore, cfg *config.PatternsConfig) (*PatternMiner, error) {
+	saved, err := store.LoadPatterns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load patterns: %w", err)
+	}
+
+	miner := patterns.NewMiner(cfg)
+	miner.Load(saved)
+	return &PatternMiner{miner: miner, store: store}, nil
+}
+
+// Process returns a copy of logs with TemplateID set. Templates that fail to
+// save are retried with the next batch.
+func (m *PatternMiner) Process(logs []models.LogEntry) []models.LogEntry {
+	out := make([]models.LogEntry, len(logs))
+	for i, log := range logs {
+		log.TemplateID = m.miner.Add(log.Service, log.Message, log.Timestamp)
+		out[i] = log
+	}
+
+	if err := m.miner.Flush(m.store.SavePatterns); err != nil {
+		slog.Warn("failed to save patterns", slog.String("error", err.Error()))
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// allServices is the key in a pipeline config whose stages apply to every
+// service. They run before the service specific stages.
+const allServices = "*"
+
+// Stage transforms a single log entry in place. Returning false drops the
+// entry.
+type Stage interface {
+	Apply(log *models.LogEntry) bool
+}
+
+// Processor transforms a batch of logs after it is dequeued and before it is
+// stored.
+type Processor interface {
+	Process(logs []models.LogEntry) []models.LogEntry
+}
+
+// Processors runs each processor on what the previous one returned.
+type Processors []Processor
+
+func (ps Processors) Process(logs []models.LogEntry) []models.LogEntry {
+	for _, p := range ps {
+		if len(logs) == 0 {
+			break
+		}
+		logs = p.Process(logs)
+	}
+	return logs
+}
+
+// Pipeline runs an ordered list of stages per service.
+type Pipeline struct {
+	stages map[string][]Stage
+}
+
+// PipelineConfig is the declarative form of a Pipeline, keyed by service name
+// with "*" matching every service:
+//
+//	{
+//	  "*":        [{"type": "level_remap", "levels": {"warning": "WARN"}}],
+//	  "payments": [{"type": "truncate", "field": "message", "max_length": 2048}]
+//	}
+type PipelineConfig map[string][]StageConfig
+
+type StageConfig struct {
+	Type string `json:"type"`
+
+	// rename, drop_if, extract, truncate
+	Field string `json:"field,omitempty"`
+	// rename
+	To string `json:"to,omitempty"`
+	// add_tags
+	Tags map[string]string `json:"tags,omitempty"`
+	// remove_tags
+	Keys []string `json:"keys,omitempty"`
+	// level_remap
+	Levels map[string]string `json:"levels,omitempty"`
+	// drop_if
+	Equals *string `json:"equals,omitempty"`
+	// drop_if, extract
+	Pattern string `json:"pattern,omitempty"`
+	// truncate
+	MaxLength int    `json:"max_length,omitempty"`
+	Suffix    string `json:"suffix,omitempty"`
+}
+
+// LoadPipeline reads a PipelineConfig from a JSON file. An empty path yields
+// a nil pipeline, which passes logs through unchanged.
+func LoadPipeline(path string) (*Pipeline, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline config: %w", err)
+	}
+
+	var cfg PipelineConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline config: %w", err)
+	}
+
+	return NewPipeline(cfg)
+}
+
+func NewPipeline(cfg PipelineConfig) (*Pipeline, error) {
+	p := &Pipeline{stages: make(map[string][]Stage, len(cfg))}
+
+	for service, stageConfigs := range cfg {
+		for i, sc := range stageConfigs {
+			stage, err := newStage(sc)
+			if err != nil {
+				return nil, fmt.Errorf("service %q stage %d: %w", service, i, err)
+			}
+			p.stages[service] = append(p.stages[service], stage)
+		}
+	}
+
+	return p, nil
+}
+
+// Process returns the logs that survive the pipeline. The input entries are
+// not modified, so they can still be re-queued if storing the output fails.
+func (p *Pipeline) Process(logs []models.LogEntry) []models.LogEntry {
+	if p == nil || len(p.stages) == 0 {
+		return logs
+	}
+
+	out := make([]models.LogEntry, 0, len(logs))
+	for _, log := range logs {
+		log.Tags = maps.Clone(log.Tags)
+		if p.apply(p.stages[allServices], &log) && p.apply(p.stages[log.Service], &log) {
+			out = append(out, log)
+		}
+	}
+	return out
+}
+
+func (p *Pipeline) apply(stages []Stage, log *models.LogEntry) bool {
+	for _, stage := range stages {
+		if !stage.Apply(log) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestPipelineProcess(t *testing.T) {
+	p, err := NewPipeline(PipelineConfig{
+		"*": {
+			{Type: "drop_if", Field: "level", Equals: strPtr("DEBUG")},
+			{Type: "add_tags", Tags: map[string]string{"env": "prod"}},
+		},
+		"payments": {
+			{Type: "rename", Field: "tags.env", To: "tags.environment"},
+			{Type: "truncate", MaxLength: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := []models.LogEntry{
+		{ID: "1", Service: "payments", Level: models.INFO, Message: "charged card", Tags: map[string]string{"team": "billing"}},
+		{ID: "2", Service: "payments", Level: models.DEBUG, Message: "retrying"},
+		{ID: "3", Service: "auth", Level: models.INFO, Message: "logged in"},
+	}
+	out := p.Process(in)
+
+	if len(out) != 2 || out[0].ID != "1" || out[1].ID != "3" {
+		t.Fatalf("got %+v", out)
+	}
+	// the service stages run after the "*" stages
+	if got := out[0].Tags; len(got) != 2 || got["environment"] != "prod" || got["team"] != "billing" {
+		t.Errorf("got payments tags %v", got)
+	}
+	if out[0].Message != "charg" {
+		t.Errorf("got payments message %q", out[0].Message)
+	}
+	if got := out[1].Tags; len(got) != 1 || got["env"] != "prod" || out[1].Message != "logged in" {
+		t.Errorf("got auth log %+v", out[1])
+	}
+
+	// the input is left as it was so it can be re-queued
+	if len(in[0].Tags) != 1 || in[0].Message != "charged card" || in[2].Tags != nil {
+		t.Errorf("input was modified: %+v", in)
+	}
+}
+
+func TestPipelinePassThrough(t *testing.T) {
+	logs := []models.LogEntry{{ID: "1"}}
+	var nilPipeline *Pipeline
+	if out := nilPipeline.Process(logs); len(out) != 1 {
+		t.Errorf("nil pipeline returned %+v", out)
+	}
+	empty, err := NewPipeline(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := empty.Process(logs); len(out) != 1 {
+		t.Errorf("empty pipeline returned %+v", out)
+	}
+}
+
+func TestLoadPipeline(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if p, err := LoadPipeline(""); p != nil || err != nil {
+		t.Errorf("empty path returned %v, %v", p, err)
+	}
+
+	p, err := LoadPipeline(write("ok.json", `{"*": [{"type": "level_remap", "levels": {"warning": "WARN"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := p.Process([]models.LogEntry{{Level: "warning"}})
+	if len(out) != 1 || out[0].Level != models.WARN {
+		t.Errorf("got %+v", out)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		err  string
+	}{
+		{"missing file", filepath.Join(dir, "missing.json"), "failed to read pipeline config"},
+		{"invalid JSON", write("bad.json", `{"*": [`), "failed to parse pipeline config"},
+		{"wrong shape", write("shape.json", `["rename"]`), "failed to parse pipeline config"},
+		{"bad stage", write("stage.json", `{"auth": [{"type": "add_tags", "tags": {"a": "b"}}, {"type": "truncate"}]}`), `service "auth" stage 1: truncate needs a positive max_length`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPipeline(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -1,36 +1,64 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"time"
 
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
 	"github.com/krishnaGauss/SoCode/internal/storage"
 )
 
+// Observer is told about every batch once it is stored, as it was stored.
+// Logs the store already had, such as ones the queue delivered again, are
+// left out when the store can tell them apart.
+type Observer interface {
+	Stored(logs []models.LogEntry)
+}
+
 type LogProcessor struct {
-	queue *storage.RedisQueue
-	storage *storage.PostgresStorage
-	batchSize int 
-	interval time.Duration
-	stopChan chan struct{}
+	queue         storage.Queue
+	storage       storage.LogStore
+	pipeline      Processor
+	observers     []Observer
+	batchSize     int
+	copyThreshold int
+	laneWeights   map[storage.Lane]int
+	interval      time.Duration
+	stopChan      chan struct{}
+	doneChan      chan struct{}
 }
 
-func NewLogProcessor(queue *storage.RedisQueue, storage *storage.PostgresStorage) *LogProcessor{
+func NewLogProcessor(queue storage.Queue, storage storage.LogStore, pipeline Processor, cfg *config.ProcessorConfig) *LogProcessor {
 	return &LogProcessor{
-        queue:     queue,
-        storage:   storage,
-        batchSize: 100,
-        interval:  5 * time.Second,
-        stopChan:  make(chan struct{}),
-    }
+		queue:         queue,
+		storage:       storage,
+		pipeline:      pipeline,
+		batchSize:     cfg.BatchSize,
+		copyThreshold: cfg.CopyThreshold,
+		laneWeights:   laneWeights(cfg.LaneWeights),
+		interval:      cfg.Interval,
+		stopChan:      make(chan struct{}),
+		doneChan:      make(chan struct{}),
+	}
+}
+
+// Observe adds an observer of stored batches. It must be called before
+// Start.
+func (p *LogProcessor) Observe(o Observer) {
+	p.observers = append(p.observers, o)
 }
 
-func (p *LogProcessor) Start(){
-	ticker:=time.NewTicker(p.interval)
+func (p *LogProcessor) Start() {
+	defer close(p.doneChan)
+
+	ticker := time.NewTicker(p.interval)
 	defer ticker.Stop()
 
-	for{
-		select{
+	for {
+		select {
 		case <-ticker.C:
 			p.processLogs()
 		case <-p.stopChan:
@@ -39,31 +67,228 @@ func (p *LogProcessor) Start(){
 	}
 }
 
-
-func (p *LogProcessor) Stop(){
+// Stop ends the processing loop and waits for the batch in flight, if any,
+// to finish, or for ctx to expire.
+func (p *LogProcessor) Stop(ctx context.Context) error {
 	close(p.stopChan)
+	select {
+	case <-p.doneChan:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("processing loop did not stop: %w", ctx.Err())
+	}
+}
+
+// Shutdown stops the processing loop and then flushes whatever is still
+// queued into storage until the queue is empty or ctx expires. Every batch is
+// written in a single statement or transaction, so an expired deadline leaves
+// the remaining logs in the queue rather than half-written.
+func (p *LogProcessor) Shutdown(ctx context.Context) error {
+	// the loop may still be writing a batch, draining alongside it would
+	// dequeue the same logs twice
+	if err := p.Stop(ctx); err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			remaining, _ := p.queue.Len()
+			return fmt.Errorf("drain interrupted with %d logs still queued: %w", remaining, err)
+		}
+
+		logs, err := p.dequeue()
+		if err != nil {
+			return fmt.Errorf("failed to dequeue logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+
+		if err := p.store(logs); err != nil {
+			p.requeue(logs)
+			return fmt.Errorf("failed to store logs: %w", err)
+		}
+		p.ack(logs)
+		slog.Info("Drained logs", slog.Int("count", len(logs)))
+	}
 }
 
-func (p *LogProcessor) processLogs(){
-	logs,err:=p.queue.DequeueLogs(int64(p.batchSize))
+// processLogs drains the queue one batch at a time until a batch comes back
+// short, so a backlog is cleared within a single tick. It returns early once
+// the processor is stopped, leaving the rest to Shutdown.
+func (p *LogProcessor) processLogs() {
+	for {
+		n := p.processBatch()
+		if n < p.batchSize {
+			return
+		}
+		select {
+		case <-p.stopChan:
+			return
+		default:
+		}
+	}
+}
+
+func (p *LogProcessor) processBatch() int {
+	logs, err := p.dequeue()
 	if err != nil {
-        slog.Debug("failed to dequeue logs")
-        return
-    }
+		slog.Debug("failed to dequeue logs")
+		return 0
+	}
 
 	if len(logs) == 0 {
-        return
-    }
+		return 0
+	}
 
-	 if err := p.storage.StoreLogs(logs); err != nil {
-        slog.Debug("failed to store logs")
-        // Re-queue logs on failure
-        for _, logEntry := range logs {
-            p.queue.EnqueueLog(logEntry)
-        }
-        return
-    }
+	if err := p.store(logs); err != nil {
+		slog.Debug("failed to store logs", slog.String("error", err.Error()))
+		p.requeue(logs)
+		return 0
+	}
+	p.ack(logs)
 
-    slog.Info("Processed logs")
+	slog.Info("Processed logs", slog.Int("count", len(logs)))
+	return len(logs)
+}
+
+// dequeue takes the next batch. Queues with lanes are drained by weight:
+// every lane is first offered its share of the batch, then whatever is left
+// goes to the lanes in priority order. Under load high severity lanes get
+// most of each batch, while lower ones still make progress every tick.
+func (p *LogProcessor) dequeue() ([]models.LogEntry, error) {
+	lanes, ok := p.queue.(storage.LaneQueue)
+	if !ok || len(p.laneWeights) == 0 {
+		return p.queue.Dequeue(int64(p.batchSize))
+	}
 
-}
\ No newline at end of file
+	shares := laneShares(p.laneWeights, p.batchSize)
+	var logs []models.LogEntry
+	take := func(lane storage.Lane, n int) error {
+		n = min(n, p.batchSize-len(logs))
+		if n <= 0 {
+			return nil
+		}
+		laneLogs, err := lanes.DequeueLane(lane, int64(n))
+		logs = append(logs, laneLogs...)
+		return err
+	}
+
+	for _, lane := range storage.Lanes {
+		if err := take(lane, shares[lane]); err != nil {
+			return logs, err
+		}
+	}
+	for _, lane := range storage.Lanes {
+		if err := take(lane, p.batchSize); err != nil {
+			return logs, err
+		}
+	}
+
+	return logs, nil
+}
+
+// laneShares splits a batch between the lanes by weight. Every weighted lane
+// gets at least one log, taken from the largest shares, so a lane with a
+// small weight is not starved by rounding.
+func laneShares(weights map[storage.Lane]int, batchSize int) map[storage.Lane]int {
+	totalWeight := 0
+	for _, w := range weights {
+		totalWeight += w
+	}
+
+	shares := make(map[storage.Lane]int, len(weights))
+	total := 0
+	for lane, w := range weights {
+		shares[lane] = max(batchSize*w/totalWeight, 1)
+		total += shares[lane]
+	}
+	for ; total > batchSize; total-- {
+		// on a tie the lower priority lane gives way, so when the batch is
+		// smaller than the number of lanes the lowest ones wait
+		largest := storage.Lanes[0]
+		for _, lane := range storage.Lanes {
+			if shares[lane] >= shares[largest] {
+				largest = lane
+			}
+		}
+		shares[largest]--
+	}
+	return shares
+}
+
+func laneWeights(weights map[string]int) map[storage.Lane]int {
+	out := make(map[storage.Lane]int, len(weights))
+	for _, lane := range storage.Lanes {
+		if w := weights[string(lane)]; w > 0 {
+			out[lane] = w
+		}
+	}
+	for name := range weights {
+		if _, ok := out[storage.Lane(name)]; !ok && weights[name] > 0 {
+			slog.Warn("ignoring weight for unknown lane", slog.String("lane", name))
+		}
+	}
+	return out
+}
+
+// requeue puts a failed batch back at the tail of the queue and acknowledges
+// the copies that made it, so the batch is retried by a later tick instead of
+// waiting for redelivery. Logs that cannot be re-queued, for example because
+// the queue is full, stay unacknowledged and are redelivered once their
+// visibility timeout passes.
+func (p *LogProcessor) requeue(logs []models.LogEntry) {
+	requeued := make([]models.LogEntry, 0, len(logs))
+	for _, logEntry := range logs {
+		if err := p.queue.Enqueue(logEntry); err != nil {
+			slog.Debug("failed to re-queue log", slog.String("error", err.Error()))
+			continue
+		}
+		requeued = append(requeued, logEntry)
+	}
+	p.ack(requeued)
+}
+
+func (p *LogProcessor) ack(logs []models.LogEntry) {
+	if err := p.queue.Ack(logs); err != nil {
+		slog.Debug("failed to acknowledge logs", slog.String("error", err.Error()))
+	}
+}
+
+// store runs the batch through the pipeline, writes what is left and passes
+// it on to the observers. The original batch is left untouched so callers can
+// re-queue it on failure.
+func (p *LogProcessor) store(logs []models.LogEntry) error {
+	if p.pipeline != nil {
+		logs = p.pipeline.Process(logs)
+		if len(logs) == 0 {
+			return nil
+		}
+	}
+
+	logs, err := p.write(logs)
+	if err != nil {
+		return err
+	}
+
+	if len(logs) == 0 {
+		return nil
+	}
+	for _, o := range p.observers {
+		o.Stored(logs)
+	}
+	return nil
+}
+
+// write stores the batch and returns the logs that were new to the store, or
+// the whole batch when the store cannot tell.
+func (p *LogProcessor) write(logs []models.LogEntry) ([]models.LogEntry, error) {
+	bulk := p.copyThreshold > 0 && len(logs) >= p.copyThreshold
+	if reporter, ok := p.storage.(storage.InsertReporter); ok {
+		return reporter.InsertLogs(logs, bulk)
+	}
+	if store, ok := p.storage.(storage.BulkLogStore); ok && bulk {
+		return logs, store.StoreLogsCopy(logs)
+	}
+	return logs, p.storage.StoreLogs(logs)
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// memoryStore keeps the logs written to it. When block is set, writes wait
+// for it to be closed.
+type memoryStore struct {
+	storage.LogStore
+	mu    sync.Mutex
+	logs  []models.LogEntry
+	block chan struct{}
+}
+
+func (m *memoryStore) StoreLogs(logs []models.LogEntry) error {
+	if m.block != nil {
+		<-m.block
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, logs...)
+	return nil
+}
+
+func (m *memoryStore) stored() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.logs)
+}
+
+func queueLogs(t *testing.T, q storage.Queue, n int, level models.LogLevel) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		log := models.LogEntry{ID: fmt.Sprintf("%s-%03d", level, i), Level: level, Message: "m"}
+		if err := q.Enqueue(log); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestProcessor(q storage.Queue, store storage.LogStore, interval time.Duration) *LogProcessor {
+	return NewLogProcessor(q, store, nil, &config.ProcessorConfig{BatchSize: 10, Interval: interval})
+}
+
+func TestShutdownDrainsQueue(t *testing.T) {
+	q := storage.NewMemoryQueue(0, time.Minute)
+	store := &memoryStore{}
+	// the loop never ticks, the drain does the work
+	p := newTestProcessor(q, store, time.Hour)
+	go p.Start()
+
+	queueLogs(t, q, 25, models.INFO)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.stored(); n != 25 {
+		t.Errorf("stored %d logs, want 25", n)
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Errorf("%d logs left in the queue", n)
+	}
+}
+
+func TestShutdownIsBounded(t *testing.T) {
+	q := storage.NewMemoryQueue(0, time.Minute)
+	store := &memoryStore{block: make(chan struct{})}
+	defer close(store.block)
+	p := newTestProcessor(q, store, time.Millisecond)
+	queueLogs(t, q, 5, models.INFO)
+	go p.Start()
+
+	// wait for the loop to be stuck writing the batch
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if n, _ := q.Len(); n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the batch was never dequeued")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline to be exceeded", err)
+	}
+}
+
+func TestProcessLogsStopsBetweenBatches(t *testing.T) {
+	q := storage.NewMemoryQueue(0, time.Minute)
+	store := &memoryStore{}
+	p := newTestProcessor(q, store, time.Hour)
+	queueLogs(t, q, 35, models.INFO)
+
+	close(p.stopChan)
+	p.processLogs()
+	if n := store.stored(); n != 10 {
+		t.Errorf("stored %d logs after stopping, want one batch of 10", n)
+	}
+}
+
+// laneQueue is a LaneQueue keeping a FIFO per lane. Dequeue drains the lanes
+// in priority order.
+type laneQueue struct {
+	storage.Queue
+	lanes map[storage.Lane][]models.LogEntry
+}
+
+func newLaneQueue(counts map[storage.Lane]int) *laneQueue {
+	q := &laneQueue{lanes: make(map[storage.Lane][]models.LogEntry)}
+	for lane, n := range counts {
+		for i := 0; i < n; i++ {
+			q.lanes[lane] = append(q.lanes[lane], models.LogEntry{ID: fmt.Sprintf("%s-%03d", lane, i)})
+		}
+	}
+	return q
+}
+
+func (q *laneQueue) DequeueLane(lane storage.Lane, count int64) ([]models.LogEntry, error) {
+	n := min(int(count), len(q.lanes[lane]))
+	logs := q.lanes[lane][:n]
+	q.lanes[lane] = q.lanes[lane][n:]
+	return logs, nil
+}
+
+func (q *laneQueue) Dequeue(count int64) ([]models.LogEntry, error) {
+	var logs []models.LogEntry
+	for _, lane := range storage.Lanes {
+		laneLogs, _ := q.DequeueLane(lane, count-int64(len(logs)))
+		logs = append(logs, laneLogs...)
+	}
+	return logs, nil
+}
+
+func (q *laneQueue) LaneLen() (map[storage.Lane]int64, error) {
+	out := make(map[storage.Lane]int64)
+	for lane, logs := range q.lanes {
+		out[lane] = int64(len(logs))
+	}
+	return out, nil
+}
+
+// laneCounts returns how many logs of each lane a batch holds, and the lanes
+// in the order they first appear.
+func laneCounts(logs []models.LogEntry) (map[storage.Lane]int, []storage.Lane) {
+	counts := make(map[storage.Lane]int)
+	var order []storage.Lane
+	for _, log := range logs {
+		lane, _, _ := strings.Cut(log.ID, "-")
+		if counts[storage.Lane(lane)] == 0 {
+			order = append(order, storage.Lane(lane))
+		}
+		counts[storage.Lane(lane)]++
+	}
+	return counts, order
+}
+
+func TestDequeueByLaneWeight(t *testing.T) {
+	crit, def, debug := storage.LaneCritical, storage.LaneDefault, storage.LaneDebug
+	tests := []struct {
+		name    string
+		weights map[string]int
+		queued  map[storage.Lane]int
+		batch   int
+		want    map[storage.Lane]int
+	}{
+		{
+			name:    "every lane gets its share",
+			weights: map[string]int{"critical": 6, "default": 3, "debug": 1},
+			queued:  map[storage.Lane]int{crit: 50, def: 50, debug: 50},
+			batch:   10,
+			want:    map[storage.Lane]int{crit: 6, def: 3, debug: 1},
+		},
+		{
+			name:    "unused share goes to the highest lane with logs",
+			weights: map[string]int{"critical": 6, "default": 3, "debug": 1},
+			queued:  map[storage.Lane]int{crit: 2, def: 50, debug: 50},
+			batch:   10,
+			want:    map[storage.Lane]int{crit: 2, def: 7, debug: 1},
+		},
+		{
+			name:    "debug drains under a critical flood",
+			weights: map[string]int{"critical": 100, "default": 10, "debug": 1},
+			queued:  map[storage.Lane]int{crit: 1000, def: 1000, debug: 5},
+			batch:   5,
+			want:    map[storage.Lane]int{crit: 3, def: 1, debug: 1},
+		},
+		{
+			name:    "a lane without weight only gets leftovers",
+			weights: map[string]int{"critical": 1, "default": 1},
+			queued:  map[storage.Lane]int{crit: 3, def: 3, debug: 50},
+			batch:   10,
+			want:    map[storage.Lane]int{crit: 3, def: 3, debug: 4},
+		},
+		{
+			name:    "a short queue is drained entirely",
+			weights: map[string]int{"critical": 6, "default": 3, "debug": 1},
+			queued:  map[storage.Lane]int{crit: 1, def: 2, debug: 3},
+			batch:   10,
+			want:    map[storage.Lane]int{crit: 1, def: 2, debug: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewLogProcessor(newLaneQueue(tt.queued), &memoryStore{}, nil, &config.ProcessorConfig{
+				BatchSize: tt.batch, Interval: time.Hour, LaneWeights: tt.weights,
+			})
+			logs, err := p.dequeue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts, order := laneCounts(logs)
+			if !maps.Equal(counts, tt.want) {
+				t.Errorf("got %v, want %v", counts, tt.want)
+			}
+			// ERROR and FATAL logs come first in the batch
+			if tt.want[crit] > 0 && order[0] != crit {
+				t.Errorf("got lanes in order %v", order)
+			}
+		})
+	}
+}
+
+func TestDequeueDrainsEveryLane(t *testing.T) {
+	q := newLaneQueue(map[storage.Lane]int{storage.LaneCritical: 100, storage.LaneDefault: 100, storage.LaneDebug: 10})
+	p := NewLogProcessor(q, &memoryStore{}, nil, &config.ProcessorConfig{
+		BatchSize: 20, Interval: time.Hour, LaneWeights: map[string]int{"critical": 8, "default": 4, "debug": 1},
+	})
+
+	// each batch holds 13 critical logs, including the one left over after
+	// the shares, 6 default and 1 debug until critical runs out
+	var batches int
+	for ; len(q.lanes[storage.LaneCritical]) > 0; batches++ {
+		logs, _ := p.dequeue()
+		if counts, _ := laneCounts(logs); counts[storage.LaneDebug] != 1 {
+			t.Fatalf("batch %d got %v", batches, counts)
+		}
+	}
+	// the last batch gives the 4 logs critical no longer needs to default
+	if batches != 8 || len(q.lanes[storage.LaneDefault]) != 48 {
+		t.Errorf("critical drained after %d batches with %d default logs left", batches, len(q.lanes[storage.LaneDefault]))
+	}
+}
+
+func TestDequeueWithoutWeights(t *testing.T) {
+	q := newLaneQueue(map[storage.Lane]int{storage.LaneDefault: 5, storage.LaneDebug: 5, storage.LaneCritical: 5})
+	p := NewLogProcessor(q, &memoryStore{}, nil, &config.ProcessorConfig{BatchSize: 8, Interval: time.Hour})
+	logs, _ := p.dequeue()
+	if counts, _ := laneCounts(logs); !maps.Equal(counts, map[storage.Lane]int{storage.LaneCritical: 5, storage.LaneDefault: 3}) {
+		t.Errorf("got %v, want strict priority", counts)
+	}
+}
+
+func TestLaneShares(t *testing.T) {
+	crit, def, debug := storage.LaneCritical, storage.LaneDefault, storage.LaneDebug
+	tests := []struct {
+		weights map[storage.Lane]int
+		batch   int
+		want    map[storage.Lane]int
+	}{
+		{map[storage.Lane]int{crit: 6, def: 3, debug: 1}, 100, map[storage.Lane]int{crit: 60, def: 30, debug: 10}},
+		{map[storage.Lane]int{crit: 100, def: 10, debug: 1}, 5, map[storage.Lane]int{crit: 3, def: 1, debug: 1}},
+		{map[storage.Lane]int{crit: 1, def: 1, debug: 1}, 2, map[storage.Lane]int{crit: 1, def: 1, debug: 0}},
+		{map[storage.Lane]int{debug: 1}, 7, map[storage.Lane]int{debug: 7}},
+	}
+	for _, tt := range tests {
+		if got := laneShares(tt.weights, tt.batch); !maps.Equal(got, tt.want) {
+			t.Errorf("laneShares(%v, %d) = %v, want %v", tt.weights, tt.batch, got, tt.want)
+		}
+	}
+}
+
+func TestLaneWeights(t *testing.T) {
+	got := laneWeights(map[string]int{"critical": 5, "debug": 0, "default": -1, "audit": 3})
+	if !maps.Equal(got, map[storage.Lane]int{storage.LaneCritical: 5}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/retention"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// RetentionEnforcer periodically deletes logs that outlived their retention
+// policy. Whole partitions are dropped when every policy has expired them;
+// everything else is deleted in bounded chunks.
+type RetentionEnforcer struct {
+	storage   storage.RetentionStore
+	policies  []models.RetentionPolicy
+	chunkSize int
+	interval  time.Duration
+	archiver  *Archiver
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+func NewRetentionEnforcer(store storage.RetentionStore, policies []models.RetentionPolicy, cfg *config.RetentionConfig) *RetentionEnforcer {
+	return &RetentionEnforcer{
+		storage:   store,
+		policies:  policies,
+		chunkSize: cfg.ChunkSize,
+		interval:  cfg.Interval,
+		stopChan:  make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+}
+
+// KeepUnarchived stops the enforcer from deleting logs newer than the end of
+// the archive of a, and has a reconcile the logs it is about to delete with
+// the archive first, so nothing is deleted before it has been archived. Logs
+// arriving between the two are still lost.
+func (e *RetentionEnforcer) KeepUnarchived(a *Archiver) {
+	e.archiver = a
+}
+
+func (e *RetentionEnforcer) Start() {
+	defer close(e.doneChan)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.enforce(time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-e.stopChan:
+			return
+		}
+	}
+}
+
+// Stop ends the enforcement loop, waiting for the chunk being deleted, if
+// any, to finish.
+func (e *RetentionEnforcer) Stop() {
+	close(e.stopChan)
+	<-e.doneChan
+}
+
+func (e *RetentionEnforcer) enforce(now time.Time) {
+	if len(e.policies) == 0 {
+		return
+	}
+
+	scopes := retention.Scopes(e.policies, now)
+	skip := make([]bool, len(scopes))
+	reconciled := true
+	var archived time.Time
+	if e.archiver != nil {
+		if archived = e.archiver.archive.ArchivedUntil(); archived.IsZero() {
+			return
+		}
+		for i := range scopes {
+			scopes[i].Before = retention.KeepUnarchived(scopes[i].Before, archived)
+			if _, err := e.archiver.Reconcile(e.storage, scopes[i]); err != nil {
+				slog.Warn("failed to archive logs before deleting them",
+					slog.String("policy", scopes[i].Policy.Name), slog.String("error", err.Error()))
+				skip[i], reconciled = true, false
+			}
+		}
+	}
+
+	// every log in an expired partition is in a scope, so partitions are
+	// only dropped once all scopes are archived
+	if postgres, ok := e.storage.(*storage.PostgresStorage); ok && reconciled {
+		if cutoff, ok := retention.PartitionCutoff(e.policies, now); ok {
+			if e.archiver != nil {
+				cutoff = retention.KeepUnarchived(cutoff, archived)
+			}
+			if _, err := postgres.DropPartitionsBefore(cutoff); err != nil {
+				slog.Warn("failed to drop expired partitions", slog.String("error", err.Error()))
+			}
+		}
+	}
+
+	for i, scope := range scopes {
+		if skip[i] {
+			continue
+		}
+		var total int64
+		for {
+			select {
+			case <-e.stopChan:
+				return
+			default:
+			}
+
+			n, err := e.storage.DeleteExpired(scope, e.chunkSize)
+			if err != nil {
+				slog.Warn("failed to delete expired logs",
+					slog.String("policy", scope.Policy.Name), slog.String("error", err.Error()))
+				break
+			}
+			total += n
+			if n < int64(e.chunkSize) {
+				break
+			}
+		}
+
+		if total > 0 {
+			slog.Info("deleted expired logs", slog.String("policy", scope.Policy.Name), slog.Int64("count", total))
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/archive"
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage/storagetest"
+)
+
+// retentionStore enforces retention over a storagetest.MemoryStore the way
+// the SQL stores do.
+type retentionStore struct {
+	*storagetest.MemoryStore
+}
+
+func inPolicy(log models.LogEntry, policy models.RetentionPolicy) bool {
+	return (policy.Service == "" || log.Service == policy.Service) &&
+		(policy.Level == "" || strings.EqualFold(string(log.Level), string(policy.Level)))
+}
+
+func inScope(log models.LogEntry, scope models.RetentionScope) bool {
+	if !log.Timestamp.Before(scope.Before) || !inPolicy(log, scope.Policy) {
+		return false
+	}
+	return !slices.ContainsFunc(scope.Except, func(p models.RetentionPolicy) bool { return inPolicy(log, p) })
+}
+
+func (s retentionStore) DeleteExpired(scope models.RetentionScope, limit int) (int64, error) {
+	n := 0
+	return s.Delete(func(log models.LogEntry) bool {
+		if n < limit && inScope(log, scope) {
+			n++
+			return true
+		}
+		return false
+	}), nil
+}
+
+func (s retentionStore) CountExpired(scope models.RetentionScope) (int64, error) {
+	var n int64
+	for _, log := range s.Logs() {
+		if inScope(log, scope) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s retentionStore) OldestExpired(scope models.RetentionScope, since time.Time) (time.Time, bool, error) {
+	var oldest time.Time
+	for _, log := range s.Logs() {
+		if inScope(log, scope) && !log.Timestamp.Before(since) && (oldest.IsZero() || log.Timestamp.Before(oldest)) {
+			oldest = log.Timestamp
+		}
+	}
+	return oldest, !oldest.IsZero(), nil
+}
+
+var retentionDay = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func retentionLog(id string, level models.LogLevel, after time.Duration) models.LogEntry {
+	return models.LogEntry{ID: id, Timestamp: retentionDay.Add(after), Level: level, Service: "api", Message: id}
+}
+
+// newArchivedStore archives one day of logs a day for four days, then adds
+// logs arriving late: one older than the archive, one in the first day and a
+// debug log in the third.
+func newArchivedStore(t *testing.T) (retentionStore, *Archiver, string) {
+	t.Helper()
+	dir := t.TempDir()
+	a, err := archive.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := retentionStore{storagetest.NewMemoryStore(
+		retentionLog("d0-1", models.INFO, time.Hour),
+		retentionLog("d0-2", models.INFO, 2*time.Hour),
+		retentionLog("d1-1", models.INFO, 25*time.Hour),
+		retentionLog("d1-2", "debug", 26*time.Hour),
+		retentionLog("d2-1", models.INFO, 49*time.Hour),
+		retentionLog("d3-1", models.INFO, 73*time.Hour),
+	)}
+	archiver := NewArchiver(store, a, &config.ArchiveConfig{Segment: 24 * time.Hour, Interval: time.Hour, BatchSize: 2})
+	if segs, err := archiver.Archive(retentionDay.AddDate(0, 0, 4)); err != nil || len(segs) != 4 {
+		t.Fatalf("archived %d segments, err %v", len(segs), err)
+	}
+
+	if err := store.StoreLogs([]models.LogEntry{
+		retentionLog("early", models.INFO, -19*time.Hour),
+		retentionLog("late-d0", models.INFO, 12*time.Hour),
+		retentionLog("late-debug", "DEBUG", 53*time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return store, archiver, dir
+}
+
+func archivedIDs(t *testing.T, a *archive.Archive) []string {
+	t.Helper()
+	var ids []string
+	for _, seg := range a.Segments() {
+		if err := a.Read(seg, func(log models.LogEntry) error {
+			ids = append(ids, log.ID)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func storedIDs(store retentionStore) []string {
+	var ids []string
+	for _, log := range store.Logs() {
+		ids = append(ids, log.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+var retentionPolicies = []models.RetentionPolicy{
+	{Name: "all", MaxAge: 48 * time.Hour},
+	{Name: "debug", Level: models.DEBUG, MaxAge: 24 * time.Hour},
+}
+
+func TestEnforceArchivesLateLogs(t *testing.T) {
+	store, archiver, _ := newArchivedStore(t)
+	e := NewRetentionEnforcer(store, retentionPolicies, &config.RetentionConfig{Interval: time.Hour, ChunkSize: 2})
+	e.KeepUnarchived(archiver)
+
+	e.enforce(retentionDay.AddDate(0, 0, 4))
+
+	if got, want := storedIDs(store), []string{"d2-1", "d3-1"}; !slices.Equal(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	want := []string{"d0-1", "d0-2", "d1-1", "d1-2", "d2-1", "d3-1", "early", "late-d0", "late-debug"}
+	if got := archivedIDs(t, archiver.archive); !slices.Equal(got, want) {
+		t.Errorf("archived %v, want %v", got, want)
+	}
+	if segs := archiver.archive.Segments(); len(segs) != 5 {
+		t.Errorf("got %d segments, want 5", len(segs))
+	}
+}
+
+func TestEnforceKeepsLogsItFailedToArchive(t *testing.T) {
+	store, archiver, dir := newArchivedStore(t)
+	for _, seg := range archiver.archive.Segments() {
+		if seg.From.Equal(retentionDay) {
+			if err := os.WriteFile(filepath.Join(dir, seg.File), []byte("not gzip"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	e := NewRetentionEnforcer(store, retentionPolicies, &config.RetentionConfig{Interval: time.Hour, ChunkSize: 2})
+	e.KeepUnarchived(archiver)
+
+	e.enforce(retentionDay.AddDate(0, 0, 4))
+
+	// the catch-all could not be reconciled past the first day, the debug
+	// logs were
+	want := []string{"d0-1", "d0-2", "d1-1", "d2-1", "d3-1", "early", "late-d0"}
+	if got := storedIDs(store); !slices.Equal(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestArchiverReconcileStopped(t *testing.T) {
+	store, archiver, _ := newArchivedStore(t)
+	close(archiver.stopChan)
+
+	scope := models.RetentionScope{Before: retentionDay.AddDate(0, 0, 4)}
+	if _, err := archiver.Reconcile(store, scope); err != errArchiverStopped {
+		t.Errorf("got %v, want errArchiverStopped", err)
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/stacktrace"
+)
+
+// StackTraceParser is a Processor storing the stack trace found in the
+// message of a log, if any, in its metadata under "stacktrace".
+type StackTraceParser struct{}
+
+// Process returns a copy of logs with their stack traces parsed.
+func (StackTraceParser) Process(logs []models.LogEntry) []models.LogEntry {
+	out := make([]models.LogEntry, len(logs))
+	for i, log := range logs {
+		if trace, ok := stacktrace.Parse(log.Message); ok {
+			log.Metadata = stacktrace.AddToMetadata(log.Metadata, trace)
+		}
+		out[i] = log
+	}
+	return out
+}
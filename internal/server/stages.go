@@ -0,0 +1,384 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func newStage(cfg StageConfig) (Stage, error) {
+	switch cfg.Type {
+	case "rename":
+		if cfg.Field == "" || cfg.To == "" {
+			return nil, errors.New("rename needs field and to")
+		}
+		if err := validateField(cfg.Field); err != nil {
+			return nil, err
+		}
+		if err := validateField(cfg.To); err != nil {
+			return nil, err
+		}
+		return &renameStage{from: cfg.Field, to: cfg.To}, nil
+
+	case "add_tags":
+		if len(cfg.Tags) == 0 {
+			return nil, errors.New("add_tags needs tags")
+		}
+		return &addTagsStage{tags: cfg.Tags}, nil
+
+	case "remove_tags":
+		if len(cfg.Keys) == 0 {
+			return nil, errors.New("remove_tags needs keys")
+		}
+		return &removeTagsStage{keys: cfg.Keys}, nil
+
+	case "level_remap":
+		if len(cfg.Levels) == 0 {
+			return nil, errors.New("level_remap needs levels")
+		}
+		levels := make(map[string]models.LogLevel, len(cfg.Levels))
+		for from, to := range cfg.Levels {
+			levels[strings.ToUpper(from)] = models.LogLevel(strings.ToUpper(to))
+		}
+		return &levelRemapStage{levels: levels}, nil
+
+	case "drop_if":
+		if err := validateField(cfg.Field); err != nil {
+			return nil, err
+		}
+		if (cfg.Equals == nil) == (cfg.Pattern == "") {
+			return nil, errors.New("drop_if needs exactly one of equals or pattern")
+		}
+		stage := &dropIfStage{field: cfg.Field, equals: cfg.Equals}
+		if cfg.Pattern != "" {
+			re, err := regexp.Compile(cfg.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern: %w", err)
+			}
+			stage.pattern = re
+		}
+		return stage, nil
+
+	case "extract":
+		field := cfg.Field
+		if field == "" {
+			field = "message"
+		}
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		named := false
+		for _, name := range re.SubexpNames() {
+			named = named || name != ""
+		}
+		if !named {
+			return nil, errors.New("extract pattern needs at least one named group")
+		}
+		return &extractStage{field: field, pattern: re}, nil
+
+	case "truncate":
+		field := cfg.Field
+		if field == "" {
+			field = "message"
+		}
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
+		if cfg.MaxLength <= 0 {
+			return nil, errors.New("truncate needs a positive max_length")
+		}
+		if len(cfg.Suffix) >= cfg.MaxLength {
+			return nil, errors.New("truncate suffix must be shorter than max_length")
+		}
+		return &truncateStage{field: field, maxLength: cfg.MaxLength, suffix: cfg.Suffix}, nil
+	}
+
+	return nil, fmt.Errorf("unknown stage type %q", cfg.Type)
+}
+
+// renameStage moves a value from one field to another, for example
+// "tags.env" to "tags.environment" or "metadata.uid" to "metadata.user_id".
+// Metadata values keep their JSON type when moved to another metadata key.
+type renameStage struct {
+	from, to string
+}
+
+func (s *renameStage) Apply(log *models.LogEntry) bool {
+	value, ok := getValue(log, s.from)
+	if !ok {
+		return true
+	}
+	deleteField(log, s.from)
+	setValue(log, s.to, value)
+	return true
+}
+
+type addTagsStage struct {
+	tags map[string]string
+}
+
+func (s *addTagsStage) Apply(log *models.LogEntry) bool {
+	if log.Tags == nil {
+		log.Tags = make(map[string]string, len(s.tags))
+	}
+	for k, v := range s.tags {
+		log.Tags[k] = v
+	}
+	return true
+}
+
+type removeTagsStage struct {
+	keys []string
+}
+
+func (s *removeTagsStage) Apply(log *models.LogEntry) bool {
+	for _, k := range s.keys {
+		delete(log.Tags, k)
+	}
+	return true
+}
+
+type levelRemapStage struct {
+	levels map[string]models.LogLevel
+}
+
+func (s *levelRemapStage) Apply(log *models.LogEntry) bool {
+	if level, ok := s.levels[strings.ToUpper(string(log.Level))]; ok {
+		log.Level = level
+	}
+	return true
+}
+
+type dropIfStage struct {
+	field   string
+	equals  *string
+	pattern *regexp.Regexp
+}
+
+func (s *dropIfStage) Apply(log *models.LogEntry) bool {
+	value, ok := getField(log, s.field)
+	if !ok {
+		return true
+	}
+	if s.equals != nil {
+		return value != *s.equals
+	}
+	return !s.pattern.MatchString(value)
+}
+
+// extractStage copies the named groups of a regular expression match into
+// metadata.
+type extractStage struct {
+	field   string
+	pattern *regexp.Regexp
+}
+
+func (s *extractStage) Apply(log *models.LogEntry) bool {
+	value, ok := getField(log, s.field)
+	if !ok {
+		return true
+	}
+
+	match := s.pattern.FindStringSubmatch(value)
+	if match == nil {
+		return true
+	}
+
+	for i, name := range s.pattern.SubexpNames() {
+		if name != "" && i < len(match) {
+			setField(log, "metadata."+name, match[i])
+		}
+	}
+	return true
+}
+
+// truncateStage cuts values longer than maxLength bytes, suffix included, at
+// a rune boundary. Metadata values that are not strings are left alone.
+type truncateStage struct {
+	field     string
+	maxLength int
+	suffix    string
+}
+
+func (s *truncateStage) Apply(log *models.LogEntry) bool {
+	raw, ok := getValue(log, s.field)
+	value, isStr := raw.(string)
+	if !ok || !isStr || len(value) <= s.maxLength {
+		return true
+	}
+
+	cut := s.maxLength - len(s.suffix)
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	setField(log, s.field, value[:cut]+s.suffix)
+	return true
+}
+
+// Stage fields are either one of the top level string fields of a LogEntry or
+// "tags.<key>" / "metadata.<key>" for a single tag or top level metadata key.
+
+var topLevelFields = map[string]bool{
+	"message": true, "level": true, "source": true, "service": true, "host": true,
+	"trace_id": true, "span_id": true, "parent_span_id": true,
+}
+
+func validateField(field string) error {
+	if topLevelFields[field] {
+		return nil
+	}
+	if key, ok := strings.CutPrefix(field, "tags."); ok && key != "" {
+		return nil
+	}
+	if key, ok := strings.CutPrefix(field, "metadata."); ok && key != "" {
+		return nil
+	}
+	return fmt.Errorf("unsupported field %q", field)
+}
+
+// getField returns the value of a field as a string, with metadata values
+// that are not strings in their JSON form.
+func getField(log *models.LogEntry, field string) (string, bool) {
+	value, ok := getValue(log, field)
+	if !ok {
+		return "", false
+	}
+	if str, isStr := value.(string); isStr {
+		return str, true
+	}
+	raw, _ := json.Marshal(value)
+	return string(raw), true
+}
+
+// getValue returns the value of a field: a string, or for metadata keys the
+// decoded JSON value.
+func getValue(log *models.LogEntry, field string) (interface{}, bool) {
+	switch field {
+	case "message":
+		return log.Message, true
+	case "level":
+		return string(log.Level), true
+	case "source":
+		return log.Source, true
+	case "service":
+		return log.Service, true
+	case "host":
+		return log.Host, true
+	case "trace_id":
+		return log.TraceID, true
+	case "span_id":
+		return log.SpanID, true
+	case "parent_span_id":
+		return log.ParentSpanID, true
+	}
+
+	if key, ok := strings.CutPrefix(field, "tags."); ok {
+		value, ok := log.Tags[key]
+		return value, ok
+	}
+
+	if key, ok := strings.CutPrefix(field, "metadata."); ok {
+		value, ok := decodeMetadata(log)[key]
+		return value, ok
+	}
+
+	return "", false
+}
+
+func setField(log *models.LogEntry, field, value string) {
+	setValue(log, field, value)
+}
+
+// setValue sets a field. Metadata keys store the value as is, other fields
+// take its string form as returned by getField.
+func setValue(log *models.LogEntry, field string, raw interface{}) {
+	if key, ok := strings.CutPrefix(field, "metadata."); ok {
+		metadata := decodeMetadata(log)
+		if metadata == nil {
+			if len(log.Metadata) > 0 && string(log.Metadata) != "null" {
+				return // not an object, leave it alone
+			}
+			metadata = make(map[string]interface{})
+		}
+		metadata[key] = raw
+		log.Metadata, _ = json.Marshal(metadata)
+		return
+	}
+
+	value, isStr := raw.(string)
+	if !isStr {
+		encoded, _ := json.Marshal(raw)
+		value = string(encoded)
+	}
+
+	switch field {
+	case "message":
+		log.Message = value
+	case "level":
+		log.Level = models.LogLevel(value)
+	case "source":
+		log.Source = value
+	case "service":
+		log.Service = value
+	case "host":
+		log.Host = value
+	case "trace_id":
+		log.TraceID = value
+	case "span_id":
+		log.SpanID = value
+	case "parent_span_id":
+		log.ParentSpanID = value
+	}
+
+	if key, ok := strings.CutPrefix(field, "tags."); ok {
+		if log.Tags == nil {
+			log.Tags = make(map[string]string)
+		}
+		log.Tags[key] = value
+	}
+}
+
+func deleteField(log *models.LogEntry, field string) {
+	if topLevelFields[field] {
+		setField(log, field, "")
+		return
+	}
+
+	if key, ok := strings.CutPrefix(field, "tags."); ok {
+		delete(log.Tags, key)
+	}
+
+	if key, ok := strings.CutPrefix(field, "metadata."); ok {
+		metadata := decodeMetadata(log)
+		if _, exists := metadata[key]; exists {
+			delete(metadata, key)
+			log.Metadata, _ = json.Marshal(metadata)
+		}
+	}
+}
+
+// decodeMetadata returns the metadata object, or nil when it is empty or not
+// a JSON object. Numbers are kept as json.Number so re-encoding the object
+// does not change them.
+func decodeMetadata(log *models.LogEntry) map[string]interface{} {
+	if len(log.Metadata) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(log.Metadata))
+	dec.UseNumber()
+	var metadata map[string]interface{}
+	if err := dec.Decode(&metadata); err != nil {
+		return nil
+	}
+	return metadata
+}
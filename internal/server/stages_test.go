@@ -0,0 +1,309 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestStages(t *testing.T) {
+	base := func() models.LogEntry {
+		return models.LogEntry{
+			Level:    models.LogLevel("warning"),
+			Message:  "user 42 logged in from 10.0.0.1",
+			Service:  "auth",
+			Tags:     map[string]string{"env": "prod", "team": "identity"},
+			Metadata: []byte(`{"uid":12345678901234567890,"flags":{"beta":true},"note":"hi"}`),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		stage StageConfig
+		// edit changes the base log before the stage runs
+		edit func(*models.LogEntry)
+		keep bool
+		want func(*models.LogEntry)
+	}{
+		{
+			name:  "rename tag",
+			stage: StageConfig{Type: "rename", Field: "tags.env", To: "tags.environment"},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Tags = map[string]string{"environment": "prod", "team": "identity"}
+			},
+		},
+		{
+			name:  "rename missing field",
+			stage: StageConfig{Type: "rename", Field: "tags.region", To: "tags.zone"},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "rename keeps metadata types",
+			stage: StageConfig{Type: "rename", Field: "metadata.uid", To: "metadata.user_id"},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Metadata = []byte(`{"flags":{"beta":true},"note":"hi","user_id":12345678901234567890}`)
+			},
+		},
+		{
+			name:  "rename object to metadata",
+			stage: StageConfig{Type: "rename", Field: "metadata.flags", To: "metadata.features"},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Metadata = []byte(`{"features":{"beta":true},"note":"hi","uid":12345678901234567890}`)
+			},
+		},
+		{
+			name:  "rename metadata to tag",
+			stage: StageConfig{Type: "rename", Field: "metadata.uid", To: "tags.uid"},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Tags["uid"] = "12345678901234567890"
+				l.Metadata = []byte(`{"flags":{"beta":true},"note":"hi"}`)
+			},
+		},
+		{
+			name:  "rename tag to top level",
+			stage: StageConfig{Type: "rename", Field: "tags.team", To: "host"},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Host = "identity"
+				delete(l.Tags, "team")
+			},
+		},
+		{
+			name:  "add tags",
+			stage: StageConfig{Type: "add_tags", Tags: map[string]string{"env": "staging", "region": "eu"}},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Tags = map[string]string{"env": "staging", "region": "eu", "team": "identity"}
+			},
+		},
+		{
+			name:  "add tags without tags",
+			stage: StageConfig{Type: "add_tags", Tags: map[string]string{"region": "eu"}},
+			edit:  func(l *models.LogEntry) { l.Tags = nil },
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Tags = map[string]string{"region": "eu"}
+			},
+		},
+		{
+			name:  "remove tags",
+			stage: StageConfig{Type: "remove_tags", Keys: []string{"env", "missing"}},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Tags = map[string]string{"team": "identity"}
+			},
+		},
+		{
+			name:  "level remap ignores case",
+			stage: StageConfig{Type: "level_remap", Levels: map[string]string{"Warning": "warn"}},
+			keep:  true,
+			want:  func(l *models.LogEntry) { l.Level = models.WARN },
+		},
+		{
+			name:  "level remap without a match",
+			stage: StageConfig{Type: "level_remap", Levels: map[string]string{"trace": "DEBUG"}},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "drop if equals",
+			stage: StageConfig{Type: "drop_if", Field: "tags.env", Equals: strPtr("prod")},
+			keep:  false,
+		},
+		{
+			name:  "drop if equals no match",
+			stage: StageConfig{Type: "drop_if", Field: "tags.env", Equals: strPtr("dev")},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "drop if empty equals",
+			stage: StageConfig{Type: "drop_if", Field: "host", Equals: strPtr("")},
+			keep:  false,
+		},
+		{
+			name:  "drop if missing field",
+			stage: StageConfig{Type: "drop_if", Field: "tags.region", Equals: strPtr("")},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "drop if pattern",
+			stage: StageConfig{Type: "drop_if", Field: "message", Pattern: `logged in`},
+			keep:  false,
+		},
+		{
+			name:  "drop if pattern on metadata JSON",
+			stage: StageConfig{Type: "drop_if", Field: "metadata.flags", Pattern: `"beta":true`},
+			keep:  false,
+		},
+		{
+			name:  "extract",
+			stage: StageConfig{Type: "extract", Pattern: `user (?P<user>\d+) .* from (?P<ip>\S+)`},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Metadata = []byte(`{"flags":{"beta":true},"ip":"10.0.0.1","note":"hi","uid":12345678901234567890,"user":"42"}`)
+			},
+		},
+		{
+			name:  "extract without a match",
+			stage: StageConfig{Type: "extract", Pattern: `order (?P<order>\d+)`},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "extract into empty metadata",
+			stage: StageConfig{Type: "extract", Field: "service", Pattern: `(?P<app>\w+)`},
+			edit:  func(l *models.LogEntry) { l.Metadata = nil },
+			keep:  true,
+			want:  func(l *models.LogEntry) { l.Metadata = []byte(`{"app":"auth"}`) },
+		},
+		{
+			name:  "extract leaves non object metadata",
+			stage: StageConfig{Type: "extract", Pattern: `user (?P<user>\d+)`},
+			edit:  func(l *models.LogEntry) { l.Metadata = []byte(`[1,2]`) },
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "truncate counts the suffix",
+			stage: StageConfig{Type: "truncate", MaxLength: 10, Suffix: "..."},
+			keep:  true,
+			want:  func(l *models.LogEntry) { l.Message = "user 42..." },
+		},
+		{
+			name:  "truncate short value",
+			stage: StageConfig{Type: "truncate", MaxLength: 100, Suffix: "..."},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "truncate at a rune boundary",
+			stage: StageConfig{Type: "truncate", MaxLength: 5, Suffix: "…"},
+			edit:  func(l *models.LogEntry) { l.Message = "añoñoño" },
+			keep:  true,
+			// "…" takes 3 bytes, leaving 2 of which "a" fits
+			want: func(l *models.LogEntry) { l.Message = "a…" },
+		},
+		{
+			name:  "truncate skips non string metadata",
+			stage: StageConfig{Type: "truncate", Field: "metadata.uid", MaxLength: 4},
+			keep:  true,
+			want:  func(*models.LogEntry) {},
+		},
+		{
+			name:  "truncate string metadata",
+			stage: StageConfig{Type: "truncate", Field: "metadata.note", MaxLength: 1},
+			keep:  true,
+			want: func(l *models.LogEntry) {
+				l.Metadata = []byte(`{"flags":{"beta":true},"note":"h","uid":12345678901234567890}`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := newStage(tt.stage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log := base()
+			if tt.edit != nil {
+				tt.edit(&log)
+			}
+			want := log
+			want.Tags = make(map[string]string)
+			for k, v := range log.Tags {
+				want.Tags[k] = v
+			}
+			if log.Tags == nil {
+				want.Tags = nil
+			}
+
+			if keep := stage.Apply(&log); keep != tt.keep {
+				t.Fatalf("got keep %v, want %v", keep, tt.keep)
+			}
+			if !tt.keep {
+				return
+			}
+			tt.want(&want)
+			assertLog(t, log, want)
+		})
+	}
+}
+
+// assertLog compares two logs, with metadata compared as JSON.
+func assertLog(t *testing.T, got, want models.LogEntry) {
+	t.Helper()
+	if string(got.Metadata) != string(want.Metadata) {
+		t.Errorf("got metadata %s, want %s", got.Metadata, want.Metadata)
+	}
+	got.Metadata, want.Metadata = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStageConfigErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage StageConfig
+		err   string
+	}{
+		{"unknown type", StageConfig{Type: "uppercase"}, `unknown stage type "uppercase"`},
+		{"rename without to", StageConfig{Type: "rename", Field: "host"}, "rename needs field and to"},
+		{"rename bad field", StageConfig{Type: "rename", Field: "timestamp", To: "host"}, `unsupported field "timestamp"`},
+		{"rename bad target", StageConfig{Type: "rename", Field: "host", To: "tags."}, `unsupported field "tags."`},
+		{"add_tags empty", StageConfig{Type: "add_tags"}, "add_tags needs tags"},
+		{"remove_tags empty", StageConfig{Type: "remove_tags"}, "remove_tags needs keys"},
+		{"level_remap empty", StageConfig{Type: "level_remap"}, "level_remap needs levels"},
+		{"drop_if without a field", StageConfig{Type: "drop_if", Equals: strPtr("x")}, `unsupported field ""`},
+		{"drop_if neither", StageConfig{Type: "drop_if", Field: "host"}, "drop_if needs exactly one of equals or pattern"},
+		{"drop_if both", StageConfig{Type: "drop_if", Field: "host", Equals: strPtr("x"), Pattern: "x"}, "drop_if needs exactly one of equals or pattern"},
+		{"drop_if bad pattern", StageConfig{Type: "drop_if", Field: "host", Pattern: "("}, "invalid pattern"},
+		{"extract bad pattern", StageConfig{Type: "extract", Pattern: "("}, "invalid pattern"},
+		{"extract unnamed groups", StageConfig{Type: "extract", Pattern: `(\d+)`}, "extract pattern needs at least one named group"},
+		{"extract bad field", StageConfig{Type: "extract", Field: "metadata.", Pattern: `(?P<n>\d+)`}, `unsupported field "metadata."`},
+		{"truncate without max_length", StageConfig{Type: "truncate"}, "truncate needs a positive max_length"},
+		{"truncate long suffix", StageConfig{Type: "truncate", MaxLength: 3, Suffix: "..."}, "truncate suffix must be shorter than max_length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newStage(tt.stage)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGetFieldJSONForm(t *testing.T) {
+	log := models.LogEntry{Metadata: []byte(`{"n":1.50,"ok":false,"s":"x"}`)}
+	for field, want := range map[string]string{"metadata.n": "1.50", "metadata.ok": "false", "metadata.s": "x"} {
+		if got, ok := getField(&log, field); !ok || got != want {
+			t.Errorf("getField(%s) = %q, %v, want %q", field, got, ok, want)
+		}
+	}
+	if _, ok := getField(&log, "metadata.missing"); ok {
+		t.Error("got a value for a missing key")
+	}
+
+	setField(&log, "metadata.s", "y")
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(log.Metadata, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if string(metadata["n"]) != "1.50" {
+		t.Errorf("setting another key changed n to %s", metadata["n"])
+	}
+}
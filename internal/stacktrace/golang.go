@@ -0,0 +1,67 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	goPanic     = regexp.MustCompile(`^(panic|fatal error): (.*)$`)
+	goGoroutine = regexp.MustCompile(`^goroutine \d+ \[[^\]]*\]:$`)
+	goLocation  = regexp.MustCompile(`^\t(.+):(\d+)(?: \+0x[0-9a-f]+)?$`)
+)
+
+// parseGo reads a panic or fatal error and the stack of the goroutine listed
+// first, the one that failed.
+func parseGo(lines []string) ([]Exception, bool) {
+	i := 0
+	var e Exception
+	for ; i < len(lines); i++ {
+		if m := goPanic.FindStringSubmatch(strings.TrimSpace(lines[i])); m != nil {
+			e = Exception{Type: m[1], Message: m[2], Frames: []Frame{}}
+			break
+		}
+	}
+	for ; i < len(lines) && !goGoroutine.MatchString(lines[i]); i++ {
+	}
+	if i == len(lines) {
+		return nil, false
+	}
+
+	for i++; i+1 < len(lines) && len(e.Frames) < maxFrames; i += 2 {
+		loc := goLocation.FindStringSubmatch(lines[i+1])
+		if lines[i] == "" || loc == nil {
+			break
+		}
+		fn := goFunction(lines[i])
+		line, _ := strconv.Atoi(loc[2])
+		e.Frames = append(e.Frames, Frame{Function: fn, Module: goPackage(fn), File: loc[1], Line: line})
+	}
+	return []Exception{e}, e.Type != ""
+}
+
+// goFunction drops the arguments and goroutine annotations of a function
+// line, such as "main.run(0x1, {0x4b2f40, 0x5})".
+func goFunction(line string) string {
+	line = strings.TrimPrefix(line, "created by ")
+	if i := strings.Index(line, " in goroutine "); i >= 0 {
+		line = line[:i]
+	}
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndex(line, "("); i > 0 {
+			line = line[:i]
+		}
+	}
+	return line
+}
+
+// goPackage returns the import path of a qualified function name such as
+// "net/http.(*Server).Serve".
+func goPackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return ""
+}
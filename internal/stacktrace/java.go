@@ -0,0 +1,83 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// an optional module such as "java.base/" precedes the class
+	javaFrame    = regexp.MustCompile(`^\s+at\s+(?:[\w.$-]+(?:@[\w.-]+)?/)?([\w$.<>]+)\.([\w$<>]+)\(([^:)]*)(?::(\d+))?\)`)
+	javaCausedBy = regexp.MustCompile(`^\s*Caused by: (.*)$`)
+	javaThread   = regexp.MustCompile(`^Exception in thread "[^"]*" `)
+)
+
+// parseJava reads a Java exception and its Caused by chain. Suppressed
+// exceptions are skipped.
+func parseJava(lines []string) ([]Exception, bool) {
+	first := -1
+	for i, line := range lines {
+		if javaFrame.MatchString(line) {
+			first = i
+			break
+		}
+	}
+	header := previousLine(lines, first)
+	if header < 0 {
+		return nil, false
+	}
+
+	typ, message := splitException(javaThread.ReplaceAllString(strings.TrimSpace(lines[header]), ""))
+	exceptions := []Exception{{Type: typ, Message: message, Frames: []Frame{}}}
+	suppressed := false
+	for _, line := range lines[first:] {
+		current := &exceptions[len(exceptions)-1]
+		if m := javaFrame.FindStringSubmatch(line); m != nil {
+			if !suppressed && len(current.Frames) < maxFrames {
+				current.Frames = append(current.Frames, javaFrameOf(m))
+			}
+			continue
+		}
+		if m := javaCausedBy.FindStringSubmatch(line); m != nil && !strings.HasPrefix(line, "\t\t") {
+			typ, message := splitException(m[1])
+			exceptions = append(exceptions, Exception{Type: typ, Message: message, Frames: []Frame{}})
+			suppressed = false
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "Suppressed: "):
+			suppressed = true
+		case strings.HasPrefix(trimmed, "... "), trimmed == "":
+		case !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t"):
+			// the trace is over
+			return exceptions, true
+		}
+	}
+	return exceptions, true
+}
+
+func javaFrameOf(m []string) Frame {
+	class, method := m[1], m[2]
+	f := Frame{Function: class + "." + method}
+	if i := strings.LastIndex(class, "."); i >= 0 {
+		f.Module = class[:i]
+	}
+	// "Native Method" and "Unknown Source" are not files
+	if !strings.Contains(m[3], " ") {
+		f.File = m[3]
+	}
+	f.Line, _ = strconv.Atoi(m[4])
+	return f
+}
+
+// previousLine returns the index of the last non-blank line before i, or -1.
+func previousLine(lines []string, i int) int {
+	for i--; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			return i
+		}
+	}
+	return -1
+}
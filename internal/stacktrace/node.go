@@ -0,0 +1,60 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// frames are "at fn (file:line:column)" or "at file:line:column", where only
+// the file in parentheses may contain spaces
+var nodeFrame = regexp.MustCompile(`^\s+at (?:(.+?) \((.+?):(\d+):\d+\)|(\S+?):(\d+):\d+)$`)
+
+// parseNode reads a Node.js error stack.
+func parseNode(lines []string) ([]Exception, bool) {
+	first := -1
+	for i, line := range lines {
+		if nodeFrame.MatchString(line) {
+			first = i
+			break
+		}
+	}
+	header := previousLine(lines, first)
+	if header < 0 {
+		return nil, false
+	}
+
+	typ, message := splitException(lines[header])
+	e := Exception{Type: typ, Message: message, Frames: []Frame{}}
+	for _, line := range lines[first:] {
+		m := nodeFrame.FindStringSubmatch(line)
+		if m == nil {
+			if strings.HasPrefix(strings.TrimSpace(line), "at ") {
+				// frames without a location, such as "at async Promise.all (index 0)"
+				continue
+			}
+			break
+		}
+		if len(e.Frames) < maxFrames {
+			// only one of the alternatives of nodeFrame matched
+			file := strings.TrimPrefix(m[2]+m[4], "file://")
+			line, _ := strconv.Atoi(m[3] + m[5])
+			e.Frames = append(e.Frames, Frame{Function: strings.TrimPrefix(m[1], "async "), Module: nodeModule(file), File: file, Line: line})
+		}
+	}
+	return []Exception{e}, true
+}
+
+// nodeModule returns the package of a file under node_modules, or the file
+// without its extension.
+func nodeModule(file string) string {
+	path, ok := packagePath(file, "node_modules")
+	if !ok {
+		return stripExtension(path)
+	}
+	parts := strings.SplitN(path, "/", 3)
+	if strings.HasPrefix(parts[0], "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
@@ -0,0 +1,80 @@
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const pythonTraceback = "Traceback (most recent call last):"
+
+var (
+	pythonFrame = regexp.MustCompile(`^\s+File "(.+)", line (\d+)(?:, in (.+))?$`)
+	// the standard library is under a directory such as lib/python3.12
+	pythonLib = regexp.MustCompile(`^.*/python\d+(?:\.\d+)?/`)
+)
+
+// parsePython reads a traceback, including the tracebacks of the exceptions
+// it was raised while handling or from.
+func parsePython(lines []string) ([]Exception, bool) {
+	var chain []Exception
+	var current *Exception
+	for _, line := range lines {
+		if strings.TrimSpace(line) == pythonTraceback {
+			current = &Exception{Frames: []Frame{}}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := pythonFrame.FindStringSubmatch(line); m != nil {
+			if len(current.Frames) < maxFrames {
+				line, _ := strconv.Atoi(m[2])
+				current.Frames = append(current.Frames, Frame{Function: m[3], Module: pythonModule(m[1]), File: m[1], Line: line})
+			}
+			continue
+		}
+		// source lines and markers are indented, the exception is not
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		current.Type, current.Message = splitException(line)
+		chain = append(chain, *current)
+		current = nil
+	}
+	if len(chain) == 0 {
+		return nil, false
+	}
+
+	// the last exception is the one reported, the earlier ones its causes
+	exceptions := make([]Exception, len(chain))
+	for i, e := range chain {
+		exceptions[len(chain)-1-i] = e
+	}
+	return exceptions, true
+}
+
+// pythonModule returns the dotted module of a file, such as billing.charge
+// for /app/billing/charge.py. Installed packages and the standard library
+// are named from their directory, and absolute paths of the application
+// from below its root, such as /app.
+func pythonModule(file string) string {
+	// <stdin>, <string> and frozen modules have no path
+	if strings.HasPrefix(file, "<") {
+		return file
+	}
+	path, ok := packagePath(file, "site-packages", "dist-packages")
+	if !ok && pythonLib.MatchString(path) {
+		path, ok = pythonLib.ReplaceAllString(path, ""), true
+	}
+	if !ok {
+		if volume := strings.Index(path, ":/"); volume >= 0 {
+			path = path[volume+1:]
+		}
+		if strings.HasPrefix(path, "/") {
+			_, path, _ = strings.Cut(path[1:], "/")
+		}
+	}
+	path = strings.TrimSuffix(stripExtension(path), "/__init__")
+	return strings.ReplaceAll(path, "/", ".")
+}
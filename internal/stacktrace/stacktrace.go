@@ -0,0 +1,152 @@
+// Package stacktrace extracts exceptions and their frames from stack traces
+// logged as text by Go, Java, Python and Node.js programs.
+package stacktrace
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// MetadataKey is the key of the parsed trace in log metadata.
+const MetadataKey = "stacktrace"
+
+// maxFrames bounds the frames kept per exception.
+const maxFrames = 100
+
+type Frame struct {
+	Function string `json:"function,omitempty"`
+	// Module is the package of the function, or for scripts the file
+	// without its extension, relative to the installed packages if under
+	// them.
+	Module string `json:"module,omitempty"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+}
+
+type Exception struct {
+	Type    string  `json:"type"`
+	Message string  `json:"message,omitempty"`
+	Frames  []Frame `json:"frames"`
+}
+
+// Trace is the parsed form of a stack trace. The exception that was reported
+// is at the top level and Causes lists the exceptions that led to it, the
+// nearest first. Modules and Files list every module and file of any frame
+// once, so a filter on their text finds traces passing through them.
+type Trace struct {
+	Language string `json:"language"`
+	Exception
+	Causes  []Exception `json:"causes,omitempty"`
+	Modules []string    `json:"modules"`
+	Files   []string    `json:"files"`
+}
+
+var parsers = []struct {
+	language string
+	parse    func(lines []string) ([]Exception, bool)
+}{
+	{"python", parsePython},
+	{"go", parseGo},
+	{"java", parseJava},
+	{"node", parseNode},
+}
+
+// Parse finds a stack trace in message. It reports false when there is
+// none.
+func Parse(message string) (*Trace, bool) {
+	if !strings.Contains(message, "\n") {
+		return nil, false
+	}
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+
+	for _, p := range parsers {
+		exceptions, ok := p.parse(lines)
+		if !ok || len(exceptions) == 0 {
+			continue
+		}
+
+		t := &Trace{Language: p.language, Exception: exceptions[0], Causes: exceptions[1:]}
+		t.Modules, t.Files = []string{}, []string{}
+		for _, e := range exceptions {
+			for _, f := range e.Frames {
+				if f.Module != "" && !slices.Contains(t.Modules, f.Module) {
+					t.Modules = append(t.Modules, f.Module)
+				}
+				if f.File != "" && !slices.Contains(t.Files, f.File) {
+					t.Files = append(t.Files, f.File)
+				}
+			}
+		}
+		return t, true
+	}
+	return nil, false
+}
+
+// FromMetadata returns the trace stored in log metadata, if any.
+func FromMetadata(metadata json.RawMessage) (*Trace, bool) {
+	if len(metadata) == 0 {
+		return nil, false
+	}
+	var fields struct {
+		Trace *Trace `json:"stacktrace"`
+	}
+	if err := json.Unmarshal(metadata, &fields); err != nil || fields.Trace == nil {
+		return nil, false
+	}
+	return fields.Trace, true
+}
+
+// AddToMetadata returns metadata with t stored under MetadataKey. Metadata
+// that already has the key, or is not a JSON object, is returned unchanged.
+func AddToMetadata(metadata json.RawMessage, t *Trace) json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &fields); err != nil || fields == nil {
+			return metadata
+		}
+		if _, ok := fields[MetadataKey]; ok {
+			return metadata
+		}
+	}
+
+	encoded, err := json.Marshal(t)
+	if err != nil {
+		return metadata
+	}
+	fields[MetadataKey] = encoded
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return metadata
+	}
+	return out
+}
+
+// splitException splits an exception header such as "pkg.Error: message"
+// into its type and message.
+func splitException(line string) (string, string) {
+	typ, message, _ := strings.Cut(strings.TrimSpace(line), ": ")
+	return strings.TrimSpace(typ), strings.TrimSpace(message)
+}
+
+// stripExtension drops the extension of the last element of path.
+func stripExtension(path string) string {
+	slash := strings.LastIndexAny(path, `/\`)
+	if dot := strings.LastIndex(path, "."); dot > slash+1 {
+		return path[:dot]
+	}
+	return path
+}
+
+// packagePath returns path relative to the last directory of installed
+// packages named dir, such as site-packages or node_modules, and whether it
+// is under one.
+func packagePath(path string, dirs ...string) (string, bool) {
+	path = strings.ReplaceAll(path, `\`, "/")
+	for _, dir := range dirs {
+		if i := strings.LastIndex(path, "/"+dir+"/"); i >= 0 {
+			return path[i+len(dir)+2:], true
+		}
+	}
+	return path, false
+}
@@ -0,0 +1,150 @@
+package stacktrace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// describe renders an exception as its type followed by its frames, each as
+// function@module:line.
+func describe(e Exception) string {
+	parts := []string{e.Type}
+	for _, f := range e.Frames {
+		parts = append(parts, fmt.Sprintf("%s@%s:%d", f.Function, f.Module, f.Line))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		file       string
+		language   string
+		exceptions []string
+		modules    []string
+	}{
+		{
+			file:     "go_panic.txt",
+			language: "go",
+			exceptions: []string{
+				"panic github.com/acme/shop/billing.(*Charger).Charge@github.com/acme/shop/billing:42 main.main@main:17",
+			},
+			modules: []string{"github.com/acme/shop/billing", "main"},
+		},
+		{
+			file:     "java_caused_by.txt",
+			language: "java",
+			exceptions: []string{
+				"java.lang.IllegalStateException com.acme.billing.Charger.charge@com.acme.billing:42 com.acme.Main.main@com.acme:10",
+				"java.io.IOException java.net.Socket.connect@java.net:633 com.acme.billing.Gateway.call@com.acme.billing:88",
+				"java.net.SocketTimeoutException sun.nio.ch.NioSocketImpl.timedFinishConnect@sun.nio.ch:0",
+			},
+			modules: []string{"com.acme.billing", "com.acme", "java.net", "sun.nio.ch"},
+		},
+		{
+			file:     "python_chained.txt",
+			language: "python",
+			exceptions: []string{
+				"json.decoder.JSONDecodeError charge@billing.charge:42 json@requests.models:971 <module>@billing:3",
+				"StopIteration raw_decode@json.decoder:353",
+			},
+			modules: []string{"billing.charge", "requests.models", "billing", "json.decoder"},
+		},
+		{
+			file:     "node_error.txt",
+			language: "node",
+			exceptions: []string{
+				"TypeError chargeCard@/srv/app/billing/charge:42 @@acme/queue:88 processTicksAndRejections@node:internal/process/task_queues:95",
+			},
+			modules: []string{"/srv/app/billing/charge", "@acme/queue", "node:internal/process/task_queues"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			message, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			trace, ok := Parse(string(message))
+			if !ok {
+				t.Fatal("no trace found")
+			}
+			if trace.Language != tt.language {
+				t.Errorf("got language %s, want %s", trace.Language, tt.language)
+			}
+			var got []string
+			for _, e := range append([]Exception{trace.Exception}, trace.Causes...) {
+				got = append(got, describe(e))
+			}
+			if !slices.Equal(got, tt.exceptions) {
+				t.Errorf("got exceptions\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.exceptions, "\n"))
+			}
+			if !slices.Equal(trace.Modules, tt.modules) {
+				t.Errorf("got modules %v, want %v", trace.Modules, tt.modules)
+			}
+		})
+	}
+}
+
+func TestParseIgnoresPlainText(t *testing.T) {
+	message, err := os.ReadFile(filepath.Join("testdata", "plain_text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace, ok := Parse(string(message)); ok {
+		t.Errorf("got a %s trace %+v", trace.Language, trace.Exception)
+	}
+	if _, ok := Parse("panic: boom"); ok {
+		t.Error("got a trace from a single line")
+	}
+}
+
+func TestPythonModule(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"/app/billing/charge.py", "billing.charge"},
+		{"/app/billing/__init__.py", "billing"},
+		{"billing/charge.py", "billing.charge"},
+		{"charge.py", "charge"},
+		{"/usr/local/lib/python3.12/site-packages/requests/models.py", "requests.models"},
+		{"/usr/lib/python3/dist-packages/yaml/__init__.py", "yaml"},
+		{"/usr/lib/python3.12/json/decoder.py", "json.decoder"},
+		{`C:\app\billing\charge.py`, "billing.charge"},
+		{"<stdin>", "<stdin>"},
+		{"<frozen importlib._bootstrap>", "<frozen importlib._bootstrap>"},
+	}
+	for _, tt := range tests {
+		if got := pythonModule(tt.file); got != tt.want {
+			t.Errorf("pythonModule(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	trace := &Trace{Language: "go", Exception: Exception{Type: "panic", Frames: []Frame{{Function: "main.main", Module: "main"}}}}
+
+	metadata := AddToMetadata([]byte(`{"user":"u1"}`), trace)
+	got, ok := FromMetadata(metadata)
+	if !ok || describe(got.Exception) != "panic main.main@main:0" {
+		t.Fatalf("got %+v from %s", got, metadata)
+	}
+	if !strings.Contains(string(metadata), `"user":"u1"`) {
+		t.Errorf("lost existing fields: %s", metadata)
+	}
+
+	// an existing trace and metadata that is not an object are kept
+	if again := AddToMetadata(metadata, &Trace{Language: "java"}); string(again) != string(metadata) {
+		t.Errorf("got %s", again)
+	}
+	if got := AddToMetadata([]byte(`[1]`), trace); string(got) != "[1]" {
+		t.Errorf("got %s", got)
+	}
+	if _, ok := FromMetadata([]byte(`{"user":"u1"}`)); ok {
+		t.Error("got a trace from metadata without one")
+	}
+}
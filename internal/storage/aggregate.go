@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// maxBuckets caps the time buckets a single aggregation may produce.
+const maxBuckets = 10000
+
+// aggregateDialect holds the SQL that differs between backends.
+type aggregateDialect struct {
+	// bucket renders the start of the time bucket of a row, or NULL when
+	// interval is zero.
+	bucket func(interval time.Duration) string
+	// tag renders the value of a tag.
+	tag func(key string, args *sqlArgs) string
+	// where renders the filters of a query.
+	where func(query models.LogQuery, args *sqlArgs) (string, error)
+	// notDistinct is the NULL-safe equality operator.
+	notDistinct string
+	// scanBucket reads the bucket column.
+	scanBucket func(src interface{}) (*time.Time, error)
+}
+
+func validateAggregate(query *models.AggregateQuery) error {
+	if err := ValidateQuery(&query.Query); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(query.GroupBy))
+	for _, field := range query.GroupBy {
+		key, isTag := strings.CutPrefix(field, "tags.")
+		if _, ok := groupColumns[field]; !ok && (!isTag || key == "") {
+			return fmt.Errorf("%w: cannot group by %q", ErrInvalidQuery, field)
+		}
+		if seen[field] {
+			return fmt.Errorf("%w: %q is grouped by twice", ErrInvalidQuery, field)
+		}
+		seen[field] = true
+	}
+
+	if query.TopN < 0 {
+		return fmt.Errorf("%w: top_n must not be negative", ErrInvalidQuery)
+	}
+
+	if query.Interval != 0 {
+		if query.Interval < time.Second || query.Interval%time.Second != 0 {
+			return fmt.Errorf("%w: interval must be whole seconds", ErrInvalidQuery)
+		}
+		if query.Query.StartTime == nil {
+			return fmt.Errorf("%w: an interval needs a start_time", ErrInvalidQuery)
+		}
+		end := time.Now()
+		if query.Query.EndTime != nil {
+			end = *query.Query.EndTime
+		}
+		if end.Sub(*query.Query.StartTime)/query.Interval > maxBuckets {
+			return fmt.Errorf("%w: more than %d buckets, use a larger interval", ErrInvalidQuery, maxBuckets)
+		}
+	}
+	return nil
+}
+
+// aggregateSQL renders an aggregation. Rows hold the bucket, one value per
+// GroupBy field, whether the row is the "other" group and the count, ordered
+// by bucket and then by count, largest first.
+func aggregateSQL(query models.AggregateQuery, dialect aggregateDialect, args *sqlArgs) (string, error) {
+	var groups, selects []string
+	for i, field := range query.GroupBy {
+		expr, ok := groupColumns[field]
+		if !ok {
+			expr = dialect.tag(strings.TrimPrefix(field, "tags."), args)
+		}
+		groups = append(groups, fmt.Sprintf("g%d", i))
+		selects = append(selects, fmt.Sprintf("%s AS g%d", expr, i))
+	}
+
+	ordinals := []string{"1"}
+	for i := range groups {
+		ordinals = append(ordinals, fmt.Sprint(i+2))
+	}
+
+	where, err := dialect.where(query.Query, args)
+	if err != nil {
+		return "", err
+	}
+	counts := "SELECT " + strings.Join(append([]string{dialect.bucket(query.Interval) + " AS bucket"}, selects...), ", ")
+	counts += ", COUNT(*) AS n FROM logs" + where
+	counts += " GROUP BY " + strings.Join(ordinals, ", ")
+
+	if query.TopN == 0 || len(groups) == 0 {
+		columns := strings.Join(append(append([]string{"bucket"}, groups...), "FALSE", "n"), ", ")
+		return fmt.Sprintf("WITH counts AS (%s) SELECT %s FROM counts ORDER BY bucket, n DESC", counts, columns), nil
+	}
+
+	// groups are ranked by their count over the whole range; everything
+	// past the top N collapses into NULL values flagged as other
+	var folded, joins []string
+	for _, g := range groups {
+		folded = append(folded, fmt.Sprintf("CASE WHEN r.grp_rank <= %d THEN c.%s END", query.TopN, g))
+		joins = append(joins, fmt.Sprintf("c.%[1]s %[2]s r.%[1]s", g, dialect.notDistinct))
+	}
+	list := strings.Join(groups, ", ")
+
+	return fmt.Sprintf(`WITH counts AS (%[1]s),
+		ranked AS (
+			SELECT %[2]s, ROW_NUMBER() OVER (ORDER BY SUM(n) DESC, %[2]s) AS grp_rank FROM counts GROUP BY %[2]s
+		)
+		SELECT c.bucket, %[3]s, r.grp_rank > %[4]d, CAST(SUM(c.n) AS BIGINT)
+		FROM counts c JOIN ranked r ON %[5]s
+		GROUP BY %[6]s
+		ORDER BY 1, %[7]d, %[8]d DESC`,
+		counts, list, strings.Join(folded, ", "), query.TopN, strings.Join(joins, " AND "),
+		strings.Join(append(ordinals, fmt.Sprint(len(groups)+2)), ", "), len(groups)+2, len(groups)+3), nil
+}
+
+// scanAggregate groups the rows of aggregateSQL into buckets.
+func scanAggregate(rows *sql.Rows, query models.AggregateQuery, dialect aggregateDialect) ([]models.AggregateBucket, error) {
+	var buckets []models.AggregateBucket
+	for rows.Next() {
+		var bucket interface{}
+		var other bool
+		var count int64
+		values := make([]sql.NullString, len(query.GroupBy))
+
+		dest := []interface{}{&bucket}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &other, &count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		start, err := dialect.scanBucket(bucket)
+		if err != nil {
+			return nil, err
+		}
+		if len(buckets) == 0 || !sameBucket(buckets[len(buckets)-1].Start, start) {
+			buckets = append(buckets, models.AggregateBucket{Start: start})
+		}
+
+		group := models.AggregateCount{Other: other, Count: count}
+		if !other && len(values) > 0 {
+			group.Group = make(map[string]string, len(values))
+			for i, field := range query.GroupBy {
+				group.Group[field] = values[i].String
+			}
+		}
+		last := &buckets[len(buckets)-1]
+		last.Groups = append(last.Groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fillBuckets(buckets, query), nil
+}
+
+// fillBuckets adds the empty buckets of the time range, so charts get an
+// unbroken series.
+func fillBuckets(buckets []models.AggregateBucket, query models.AggregateQuery) []models.AggregateBucket {
+	if query.Interval == 0 {
+		if len(buckets) == 0 {
+			buckets = append(buckets, models.AggregateBucket{Groups: []models.AggregateCount{}})
+		}
+		return buckets
+	}
+
+	end := time.Now()
+	if query.Query.EndTime != nil {
+		end = *query.Query.EndTime
+	}
+
+	// buckets are aligned on the Unix epoch, like the SQL that made them
+	step := int64(query.Interval / time.Second)
+	first := time.Unix(query.Query.StartTime.Unix()/step*step, 0).UTC()
+
+	filled := make([]models.AggregateBucket, 0, len(buckets))
+	next := 0
+	for t := first; !t.After(end); t = t.Add(query.Interval) {
+		if next < len(buckets) && buckets[next].Start.Equal(t) {
+			filled = append(filled, buckets[next])
+			next++
+			continue
+		}
+		start := t
+		filled = append(filled, models.AggregateBucket{Start: &start, Groups: []models.AggregateCount{}})
+	}
+	return filled
+}
+
+func sameBucket(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
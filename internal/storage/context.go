@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+var ErrLogNotFound = errors.New("log not found")
+
+// GetLog returns the log with id. It is looked up in the innermost store,
+// whose index finds it without reading any archive, so logs that are only
+// left in the archive are not found. IDs are not unique across timestamps in
+// PostgreSQL, when several logs share one the most recent is returned.
+func GetLog(store LogStore, id string) (models.LogEntry, error) {
+	logs, err := Unwrap(store).QueryLogs(models.LogQuery{
+		Filters: []models.FieldFilter{{Field: "id", Op: models.FilterEquals, Value: id}},
+		Limit:   1,
+	})
+	if err != nil {
+		return models.LogEntry{}, err
+	}
+	if len(logs) == 0 {
+		return models.LogEntry{}, fmt.Errorf("%w: %s", ErrLogNotFound, id)
+	}
+	return logs[0], nil
+}
+
+// LogContext returns up to before logs right before log and up to after logs
+// right after it, both oldest first, among the logs sharing its value of
+// every field in scope and at most window away from it. Logs are ordered by
+// timestamp then ID, so logs with the same timestamp keep the same order from
+// one call to the next.
+func LogContext(store LogStore, log models.LogEntry, scope []string, before, after int, window time.Duration) ([]models.LogEntry, []models.LogEntry, error) {
+	if before < 0 || after < 0 {
+		return nil, nil, fmt.Errorf("%w: before and after must not be negative", ErrInvalidQuery)
+	}
+
+	var query models.LogQuery
+	for _, field := range scope {
+		switch field {
+		case "host":
+			query.Host = []string{log.Host}
+		case "source":
+			query.Source = []string{log.Source}
+		case "service":
+			query.Service = []string{log.Service}
+		default:
+			return nil, nil, fmt.Errorf("%w: scope must be one of host, source or service, got %q", ErrInvalidQuery, field)
+		}
+	}
+	query.Cursor = &models.Cursor{Timestamp: log.Timestamp, ID: log.ID}
+	start, end := log.Timestamp.Add(-window), log.Timestamp.Add(window)
+
+	prev := []models.LogEntry{}
+	if before > 0 {
+		query.Order, query.Limit = models.OrderDesc, before
+		query.StartTime, query.EndTime = &start, &log.Timestamp
+		logs, err := store.QueryLogs(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		slices.Reverse(logs)
+		prev = append(prev, logs...)
+	}
+
+	next := []models.LogEntry{}
+	if after > 0 {
+		query.Order, query.Limit = models.OrderAsc, after
+		query.StartTime, query.EndTime = &log.Timestamp, &end
+		logs, err := store.QueryLogs(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		next = append(next, logs...)
+	}
+	return prev, next, nil
+}
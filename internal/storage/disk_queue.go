@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+const (
+	segmentExt  = ".seg"
+	ackFileName = "ack"
+)
+
+// DiskQueue persists logs as newline delimited JSON in numbered segment files
+// under a local directory. Every log gets a sequence number; the highest
+// contiguous acknowledged sequence is recorded in an "ack" file and segments
+// that are entirely acknowledged are deleted. Logs that were dequeued but not
+// acknowledged within the visibility timeout, or before a restart, are
+// delivered again.
+type DiskQueue struct {
+	mu                sync.Mutex
+	dir               string
+	capacity          int
+	segmentSize       int
+	visibilityTimeout time.Duration
+
+	segments []int64 // first sequence number of each segment, ascending
+	writer   *os.File
+	writeSeq int64 // sequence number of the next log written
+
+	readFile *os.File
+	reader   *bufio.Reader
+	readSeg  int   // index into segments of the segment being read
+	readSeq  int64 // sequence number of the next log dequeued
+
+	ackSeq int64 // every log below this sequence number is acknowledged
+	// pending holds the dequeued logs from ackSeq on, in sequence order,
+	// and pendingIDs the sequence numbers of those not yet acknowledged by
+	// log ID
+	pending    []pendingLog
+	pendingIDs map[string][]int64
+}
+
+type pendingLog struct {
+	seq      int64
+	acked    bool
+	log      models.LogEntry
+	deadline time.Time
+}
+
+// NewDiskQueue opens or creates a queue in dir. Segments are rotated every
+// segmentSize logs; capacity bounds the number of unacknowledged logs, with
+// zero or less meaning unbounded. Logs not acknowledged within
+// visibilityTimeout of being dequeued are delivered again.
+func NewDiskQueue(dir string, capacity, segmentSize int, visibilityTimeout time.Duration) (*DiskQueue, error) {
+	if segmentSize <= 0 {
+		return nil, errors.New("segment size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &DiskQueue{
+		dir:               dir,
+		capacity:          capacity,
+		segmentSize:       segmentSize,
+		visibilityTimeout: visibilityTimeout,
+		pendingIDs:        make(map[string][]int64),
+	}
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.openReader(); err != nil {
+		q.writer.Close()
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (q *DiskQueue) load() error {
+	ack, err := os.ReadFile(filepath.Join(q.dir, ackFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read ack file: %w", err)
+	default:
+		if q.ackSeq, err = strconv.ParseInt(strings.TrimSpace(string(ack)), 10, 64); err != nil {
+			return fmt.Errorf("corrupt ack file: %w", err)
+		}
+	}
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list queue directory: %w", err)
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentExt)
+		if !ok {
+			continue
+		}
+		start, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, start)
+	}
+	slices.Sort(q.segments)
+
+	if len(q.segments) == 0 {
+		q.segments = []int64{q.ackSeq}
+		q.writeSeq = q.ackSeq
+	} else {
+		last := q.segments[len(q.segments)-1]
+		lines, err := repairSegment(q.segmentPath(last))
+		if err != nil {
+			return err
+		}
+		q.writeSeq = last + lines
+	}
+
+	q.ackSeq = max(q.ackSeq, q.segments[0])
+	q.removeAckedSegments()
+
+	q.writer, err = os.OpenFile(q.segmentPath(q.segments[len(q.segments)-1]), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	return nil
+}
+
+// repairSegment drops a partially written last line, left behind by a crash
+// in the middle of a write, and returns the number of complete lines.
+func repairSegment(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read segment: %w", err)
+	}
+
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end < len(data) {
+		if err := os.Truncate(path, int64(end)); err != nil {
+			return 0, fmt.Errorf("failed to repair segment: %w", err)
+		}
+	}
+	return int64(bytes.Count(data[:end], []byte{'\n'})), nil
+}
+
+// openReader positions the reader at the first unacknowledged log.
+func (q *DiskQueue) openReader() error {
+	q.readSeg = 0
+	for i, start := range q.segments {
+		if start <= q.ackSeq {
+			q.readSeg = i
+		}
+	}
+	if err := q.openSegment(q.readSeg); err != nil {
+		return err
+	}
+
+	for q.readSeq = q.segments[q.readSeg]; q.readSeq < q.ackSeq; q.readSeq++ {
+		if _, err := q.reader.ReadBytes('\n'); err != nil {
+			return fmt.Errorf("failed to seek segment: %w", err)
+		}
+	}
+	return nil
+}
+
+func (q *DiskQueue) openSegment(i int) error {
+	if q.readFile != nil {
+		q.readFile.Close()
+	}
+
+	f, err := os.Open(q.segmentPath(q.segments[i]))
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	q.readFile = f
+	q.reader = bufio.NewReader(f)
+	q.readSeg = i
+	return nil
+}
+
+func (q *DiskQueue) segmentPath(start int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", start, segmentExt))
+}
+
+func (q *DiskQueue) Enqueue(log models.LogEntry) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.capacity > 0 && q.writeSeq-q.ackSeq >= int64(q.capacity) {
+		return ErrQueueFull
+	}
+
+	if q.writeSeq-q.segments[len(q.segments)-1] >= int64(q.segmentSize) {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	// a single unbuffered write per line, under the lock, means readers
+	// never observe a partial line
+	if _, err := q.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	q.writeSeq++
+	return nil
+}
+
+func (q *DiskQueue) rotate() error {
+	if err := q.writer.Sync(); err != nil {
+		return err
+	}
+	if err := q.writer.Close(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(q.segmentPath(q.writeSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	q.writer = f
+	q.segments = append(q.segments, q.writeSeq)
+	return nil
+}
+
+func (q *DiskQueue) Dequeue(count int64) ([]models.LogEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	deadline := now.Add(q.visibilityTimeout)
+	logs := q.redeliver(now, deadline, count)
+	for int64(len(logs)) < count && q.readSeq < q.writeSeq {
+		line, err := q.reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 && q.readSeg < len(q.segments)-1 {
+			if err := q.openSegment(q.readSeg + 1); err != nil {
+				return logs, err
+			}
+			continue
+		}
+		if err != nil {
+			return logs, fmt.Errorf("failed to read segment: %w", err)
+		}
+
+		seq := q.readSeq
+		q.readSeq++
+
+		var log models.LogEntry
+		if err := json.Unmarshal(line, &log); err != nil {
+			// Skip malformed logs, they can never be acknowledged otherwise
+			q.pending = append(q.pending, pendingLog{seq: seq, acked: true})
+			continue
+		}
+		q.pending = append(q.pending, pendingLog{seq: seq, log: log, deadline: deadline})
+		q.pendingIDs[log.ID] = append(q.pendingIDs[log.ID], seq)
+		logs = append(logs, log)
+	}
+
+	return logs, nil
+}
+
+// redeliver returns up to count pending logs whose visibility timeout passed,
+// oldest first, and gives them a new deadline. Without it a log whose
+// consumer never acknowledges it would hold back ackSeq, and with it segment
+// removal and the capacity, until the next restart.
+func (q *DiskQueue) redeliver(now, deadline time.Time, count int64) []models.LogEntry {
+	var logs []models.LogEntry
+	for i := range q.pending {
+		if int64(len(logs)) >= count {
+			break
+		}
+		p := &q.pending[i]
+		if p.acked || !now.After(p.deadline) {
+			continue
+		}
+		p.deadline = deadline
+		logs = append(logs, p.log)
+	}
+	return logs
+}
+
+func (q *DiskQueue) Ack(logs []models.LogEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, log := range logs {
+		seqs := q.pendingIDs[log.ID]
+		if len(seqs) == 0 || len(q.pending) == 0 {
+			continue
+		}
+		// pending is contiguous, so a sequence number is also an index
+		p := &q.pending[seqs[0]-q.pending[0].seq]
+		p.acked = true
+		p.log = models.LogEntry{}
+		if len(seqs) == 1 {
+			delete(q.pendingIDs, log.ID)
+		} else {
+			q.pendingIDs[log.ID] = seqs[1:]
+		}
+	}
+
+	ackSeq := q.ackSeq
+	for len(q.pending) > 0 && q.pending[0].acked {
+		ackSeq = q.pending[0].seq + 1
+		q.pending = q.pending[1:]
+	}
+	if ackSeq == q.ackSeq {
+		return nil
+	}
+
+	tmp := filepath.Join(q.dir, ackFileName+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(ackSeq, 10)), 0o644); err != nil {
+		return fmt.Errorf("failed to write ack file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, ackFileName)); err != nil {
+		return fmt.Errorf("failed to write ack file: %w", err)
+	}
+	q.ackSeq = ackSeq
+
+	q.removeAckedSegments()
+	return nil
+}
+
+// removeAckedSegments deletes every segment whose logs are all acknowledged,
+// always keeping the segment being written.
+func (q *DiskQueue) removeAckedSegments() {
+	for len(q.segments) > 1 && q.segments[1] <= q.ackSeq {
+		os.Remove(q.segmentPath(q.segments[0]))
+		q.segments = q.segments[1:]
+		// the reader may still sit at the end of the removed segment, in
+		// which case this drops to -1 and the next read opens segment 0
+		q.readSeg--
+	}
+}
+
+func (q *DiskQueue) Len() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.writeSeq - q.readSeq, nil
+}
+
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.readFile != nil {
+		q.readFile.Close()
+	}
+	if err := q.writer.Sync(); err != nil {
+		q.writer.Close()
+		return err
+	}
+	return q.writer.Close()
+}
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func openDiskQueue(t *testing.T, dir string, capacity, segmentSize int, timeout time.Duration) *DiskQueue {
+	t.Helper()
+	q, err := NewDiskQueue(dir, capacity, segmentSize, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func enqueueIDs(t *testing.T, q Queue, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := q.Enqueue(models.LogEntry{ID: id, Message: "m " + id}); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+}
+
+func seqIDs(prefix string, from, to int) []string {
+	var ids []string
+	for i := from; i < to; i++ {
+		ids = append(ids, fmt.Sprintf("%s%d", prefix, i))
+	}
+	return ids
+}
+
+func queuedIDs(logs []models.LogEntry) []string {
+	ids := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	return ids
+}
+
+func dequeueIDs(t *testing.T, q Queue, n int64) []string {
+	t.Helper()
+	logs, err := q.Dequeue(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return queuedIDs(logs)
+}
+
+func ackIDs(t *testing.T, q Queue, ids ...string) {
+	t.Helper()
+	logs := make([]models.LogEntry, len(ids))
+	for i, id := range ids {
+		logs[i] = models.LogEntry{ID: id}
+	}
+	if err := q.Ack(logs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range matches {
+		matches[i] = filepath.Base(m)
+	}
+	return matches
+}
+
+func TestDiskQueueReplaysUnackedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 0, 4, time.Minute)
+	enqueueIDs(t, q, seqIDs("a", 0, 10)...)
+
+	if got := dequeueIDs(t, q, 6); !slices.Equal(got, seqIDs("a", 0, 6)) {
+		t.Fatalf("got %v", got)
+	}
+	ackIDs(t, q, "a0", "a1", "a2")
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a3 to a5 were dequeued but never acknowledged, so they come back
+	q = openDiskQueue(t, dir, 0, 4, time.Minute)
+	defer q.Close()
+	if n, _ := q.Len(); n != 7 {
+		t.Errorf("got length %d, want 7", n)
+	}
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, seqIDs("a", 3, 10)) {
+		t.Errorf("got %v after restart", got)
+	}
+}
+
+func TestDiskQueuePartialAcks(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 0, 100, time.Minute)
+	enqueueIDs(t, q, seqIDs("a", 0, 5)...)
+	dequeueIDs(t, q, 5)
+
+	// acknowledging out of order only advances past the contiguous prefix
+	ackIDs(t, q, "a1", "a3", "unknown")
+	if q.ackSeq != 0 {
+		t.Errorf("got ackSeq %d, want 0", q.ackSeq)
+	}
+	ackIDs(t, q, "a0")
+	if q.ackSeq != 2 {
+		t.Errorf("got ackSeq %d, want 2", q.ackSeq)
+	}
+	q.Close()
+
+	q = openDiskQueue(t, dir, 0, 100, time.Minute)
+	defer q.Close()
+	// a3 was acknowledged out of order, which is not persisted
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, seqIDs("a", 2, 5)) {
+		t.Errorf("got %v after restart", got)
+	}
+}
+
+func TestDiskQueueDuplicateIDs(t *testing.T) {
+	q := openDiskQueue(t, t.TempDir(), 0, 100, time.Minute)
+	defer q.Close()
+	enqueueIDs(t, q, "a", "b", "a")
+	dequeueIDs(t, q, 3)
+
+	// each acknowledgement releases one copy, oldest first
+	ackIDs(t, q, "a")
+	if q.ackSeq != 1 {
+		t.Errorf("got ackSeq %d, want 1", q.ackSeq)
+	}
+	ackIDs(t, q, "b", "a")
+	if q.ackSeq != 3 {
+		t.Errorf("got ackSeq %d, want 3", q.ackSeq)
+	}
+}
+
+func TestDiskQueueRemovesAckedSegments(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 0, 3, time.Minute)
+	defer q.Close()
+	enqueueIDs(t, q, seqIDs("a", 0, 8)...)
+	if got := segmentFiles(t, dir); len(got) != 3 {
+		t.Fatalf("got segments %v", got)
+	}
+
+	dequeueIDs(t, q, 4)
+	ackIDs(t, q, seqIDs("a", 0, 4)...)
+	want := []string{fmt.Sprintf("%020d%s", 3, segmentExt), fmt.Sprintf("%020d%s", 6, segmentExt)}
+	if got := segmentFiles(t, dir); !slices.Equal(got, want) {
+		t.Errorf("got segments %v, want %v", got, want)
+	}
+
+	// the segment being written is kept even when everything is acknowledged
+	dequeueIDs(t, q, 100)
+	ackIDs(t, q, seqIDs("a", 4, 8)...)
+	if got := segmentFiles(t, dir); !slices.Equal(got, want[1:]) {
+		t.Errorf("got segments %v, want %v", got, want[1:])
+	}
+
+	// and reading carries on across the removed segments
+	enqueueIDs(t, q, "b0", "b1")
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, []string{"b0", "b1"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestDiskQueueFull(t *testing.T) {
+	q := openDiskQueue(t, t.TempDir(), 3, 100, time.Minute)
+	defer q.Close()
+	enqueueIDs(t, q, "a0", "a1", "a2")
+	if err := q.Enqueue(models.LogEntry{ID: "a3"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+
+	// dequeued logs still count until they are acknowledged
+	dequeueIDs(t, q, 2)
+	if err := q.Enqueue(models.LogEntry{ID: "a3"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+	ackIDs(t, q, "a0")
+	enqueueIDs(t, q, "a3")
+}
+
+func TestDiskQueueRedeliversAfterTimeout(t *testing.T) {
+	q := openDiskQueue(t, t.TempDir(), 2, 100, 10*time.Millisecond)
+	defer q.Close()
+	enqueueIDs(t, q, "a0", "a1")
+
+	// a consumer that cannot re-queue a failed batch into a full queue
+	// leaves it unacknowledged
+	if got := dequeueIDs(t, q, 2); !slices.Equal(got, []string{"a0", "a1"}) {
+		t.Fatalf("got %v", got)
+	}
+	if err := q.Enqueue(models.LogEntry{ID: "a0"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+	if got := dequeueIDs(t, q, 2); len(got) != 0 {
+		t.Fatalf("got %v before the timeout", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := dequeueIDs(t, q, 1); !slices.Equal(got, []string{"a0"}) {
+		t.Fatalf("got %v, want a0 redelivered", got)
+	}
+	if got := dequeueIDs(t, q, 2); !slices.Equal(got, []string{"a1"}) {
+		t.Fatalf("got %v, want a1 redelivered", got)
+	}
+
+	// acknowledging the redelivered logs frees the queue
+	ackIDs(t, q, "a0", "a1")
+	enqueueIDs(t, q, "b0", "b1")
+	if got := dequeueIDs(t, q, 2); !slices.Equal(got, []string{"b0", "b1"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestDiskQueueSkipsMalformedLines(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 0, 100, time.Minute)
+	enqueueIDs(t, q, "a0")
+	q.Close()
+
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt))
+	appendFile(t, path, "not json\n")
+	q = openDiskQueue(t, dir, 0, 100, time.Minute)
+	defer q.Close()
+	enqueueIDs(t, q, "a1")
+
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, []string{"a0", "a1"}) {
+		t.Fatalf("got %v", got)
+	}
+	ackIDs(t, q, "a0", "a1")
+	if q.ackSeq != 3 {
+		t.Errorf("got ackSeq %d, want 3", q.ackSeq)
+	}
+}
+
+func TestRepairSegment(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lines   int64
+		want    string
+	}{
+		{"empty", "", 0, ""},
+		{"complete", "{\"id\":\"a\"}\n{\"id\":\"b\"}\n", 2, "{\"id\":\"a\"}\n{\"id\":\"b\"}\n"},
+		{"partial last line", "{\"id\":\"a\"}\n{\"id\":", 1, "{\"id\":\"a\"}\n"},
+		{"only a partial line", "{\"id\":", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "0"+segmentExt)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			lines, err := repairSegment(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if lines != tt.lines || string(data) != tt.want {
+				t.Errorf("got %d lines and %q, want %d and %q", lines, data, tt.lines, tt.want)
+			}
+		})
+	}
+
+	if _, err := repairSegment(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("got no error for a missing segment")
+	}
+}
+
+func TestDiskQueueRecoversFromPartialWrite(t *testing.T) {
+	dir := t.TempDir()
+	q := openDiskQueue(t, dir, 0, 100, time.Minute)
+	enqueueIDs(t, q, "a0", "a1")
+	q.Close()
+
+	// a crash in the middle of a write leaves half a line behind
+	appendFile(t, filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt)), `{"id":"a2","mes`)
+
+	q = openDiskQueue(t, dir, 0, 100, time.Minute)
+	defer q.Close()
+	enqueueIDs(t, q, "a3")
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, []string{"a0", "a1", "a3"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+// exprDialect holds the SQL that differs between backends when compiling the
+// query language.
+type exprDialect struct {
+	filter func(f models.FieldFilter, args *sqlArgs) string
+	// search renders a full-text match of the message.
+	search func(text string, args *sqlArgs) string
+	// contains renders a case-insensitive substring match of the message.
+	contains func(text string, args *sqlArgs) string
+}
+
+// exprCondition compiles a parsed filter into a parameterized condition.
+func exprCondition(node querylang.Node, dialect exprDialect, args *sqlArgs) string {
+	switch n := node.(type) {
+	case *querylang.And:
+		return "(" + exprCondition(n.Left, dialect, args) + " AND " + exprCondition(n.Right, dialect, args) + ")"
+	case *querylang.Or:
+		return "(" + exprCondition(n.Left, dialect, args) + " OR " + exprCondition(n.Right, dialect, args) + ")"
+	case *querylang.Not:
+		// like negated filters, match logs where the condition is NULL
+		return "NOT COALESCE(" + exprCondition(n.X, dialect, args) + ", false)"
+	case *querylang.Term:
+		return termCondition(n, dialect, args)
+	}
+	panic("unknown query node")
+}
+
+func termCondition(term *querylang.Term, dialect exprDialect, args *sqlArgs) string {
+	switch {
+	case term.Field == "":
+		if term.Quoted {
+			return dialect.search(`"`+term.Value+`"`, args)
+		}
+		return dialect.search(term.Value, args)
+
+	case term.Field == "message" && (term.Quoted || !strings.ContainsAny(term.Value, "*?")):
+		condition := dialect.contains(term.Value, args)
+		if term.Negate {
+			return "NOT COALESCE(" + condition + ", false)"
+		}
+		return condition
+	}
+
+	value := term.Value
+	if term.Field == "level" {
+		// levels are stored upper case
+		value = strings.ToUpper(value)
+	}
+
+	f := models.FieldFilter{Field: term.Field, Op: models.FilterEquals, Value: value}
+	if !term.Quoted {
+		f = models.GlobFilter(term.Field, value)
+	}
+	f.Not = term.Negate
+	return dialect.filter(f, args)
+}
+
+var postgresExpr = exprDialect{
+	filter: filterCondition,
+	search: func(text string, args *sqlArgs) string {
+		return "to_tsvector('english', message) @@ websearch_to_tsquery('english', " + args.add(text) + ")"
+	},
+	contains: func(text string, args *sqlArgs) string {
+		return "message ILIKE " + args.add("%"+escapeLike(text)+"%")
+	},
+}
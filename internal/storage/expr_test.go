@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+func TestExprCondition(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		args []string
+	}{
+		// levels are stored upper case, however they are typed
+		{`level:error`, `level = $1`, []string{"ERROR"}},
+		{`level:"warn"`, `level = $1`, []string{"WARN"}},
+		{`level:err*`, `level LIKE $1`, []string{"ERR%"}},
+
+		// quoted values are literal, bare ones are globs
+		{`service:api`, `service = $1`, []string{"api"}},
+		{`service:"api*"`, `service = $1`, []string{"api*"}},
+		{`service:api*`, `service LIKE $1`, []string{"api%"}},
+		{`host:web-?1`, `host LIKE $1`, []string{"web-_1"}},
+		{`host:web_*`, `host LIKE $1`, []string{`web\_%`}},
+
+		// free text goes to the full-text search, quoted as a phrase
+		{`timeout`, `to_tsvector('english', message) @@ websearch_to_tsquery('english', $1)`, []string{"timeout"}},
+		{`"connection reset"`, `to_tsvector('english', message) @@ websearch_to_tsquery('english', $1)`, []string{`"connection reset"`}},
+
+		// message terms are substring matches unless they are globs
+		{`msg:timeout`, `message ILIKE $1`, []string{"%timeout%"}},
+		{`message:"50%"`, `message ILIKE $1`, []string{`%50\%%`}},
+		{`message:time*out`, `message LIKE $1`, []string{"time%out"}},
+		{`message!=timeout`, `NOT COALESCE(message ILIKE $1, false)`, []string{"%timeout%"}},
+
+		// negations also match logs where the field is missing, so NULL
+		// counts as false before negating
+		{`service!=api`, `NOT COALESCE(service = $1, false)`, []string{"api"}},
+		{`tags.env!=prod`, `NOT COALESCE(tags @> jsonb_build_object($1::text, $2::text), false)`, []string{"env", "prod"}},
+		{`NOT metadata.user.id:u1`, `NOT COALESCE(metadata #>> $1::text[] = $2, false)`, []string{`{"user","id"}`, "u1"}},
+		{`NOT (level:error OR service:api)`, `NOT COALESCE((level = $1 OR service = $2), false)`, []string{"ERROR", "api"}},
+
+		{`tags.env:prod*`, `tags->>$1::text LIKE $2`, []string{"env", "prod%"}},
+		{
+			`level:error service:(auth OR pay*)`,
+			`(level = $1 AND (service = $2 OR service LIKE $3))`,
+			[]string{"ERROR", "auth", "pay%"},
+		},
+	}
+	for _, tt := range tests {
+		node, err := querylang.ParseFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		args := &sqlArgs{}
+		got := exprCondition(node, postgresExpr, args)
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.expr, got, tt.want)
+		}
+		if gotArgs := argStrings(args.args); !slices.Equal(gotArgs, tt.args) {
+			t.Errorf("%s: got args %q, want %q", tt.expr, gotArgs, tt.args)
+		}
+	}
+}
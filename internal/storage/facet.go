@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// DefaultFacetLimit is the number of values returned when a facet query does
+// not ask for a limit.
+const DefaultFacetLimit = 50
+
+// FacetFields are the facets listed when none are asked for.
+var FacetFields = []string{"level", "service", "host", "source", "tags"}
+
+// facetDialect holds the SQL that differs between backends.
+type facetDialect struct {
+	// tag renders the value of a tag.
+	tag func(key string, args *sqlArgs) string
+	// tagKeys renders a query returning every tag key of the logs matching
+	// where, once per log, as column v.
+	tagKeys func(where string) string
+	where   func(query models.LogQuery, args *sqlArgs) (string, error)
+	// prefix renders a case-insensitive prefix match of column v.
+	prefix func(prefix string, args *sqlArgs) string
+}
+
+func validateFacet(query *models.FacetQuery) error {
+	if err := ValidateQuery(&query.Query); err != nil {
+		return err
+	}
+	key, isTag := strings.CutPrefix(query.Field, "tags.")
+	if _, ok := groupColumns[query.Field]; !ok && query.Field != "tags" && (!isTag || key == "") {
+		return fmt.Errorf("%w: no facet for %q", ErrInvalidQuery, query.Field)
+	}
+	if query.Limit < 0 {
+		return fmt.Errorf("%w: limit must not be negative", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// withoutField drops the filters on field from query, so the facet of a
+// field lists the alternatives to the values already picked.
+func withoutField(query models.LogQuery, field string) models.LogQuery {
+	switch field {
+	case "level":
+		query.Level = nil
+	case "service":
+		query.Service = nil
+	case "host":
+		query.Host = nil
+	case "source":
+		query.Source = nil
+	}
+	if key, ok := strings.CutPrefix(field, "tags."); ok && query.Tags[key] != "" {
+		query.Tags = maps.Clone(query.Tags)
+		delete(query.Tags, key)
+	}
+	query.Filters = slices.DeleteFunc(slices.Clone(query.Filters), func(f models.FieldFilter) bool {
+		return f.Field == field
+	})
+	return query
+}
+
+// facetSQL renders a facet query returning values and their counts, one row
+// past the limit so truncation can be told.
+func facetSQL(query models.FacetQuery, limit int, dialect facetDialect, args *sqlArgs) (string, error) {
+	logQuery := withoutField(query.Query, query.Field)
+
+	var values string
+	if query.Field == "tags" {
+		where, err := dialect.where(logQuery, args)
+		if err != nil {
+			return "", err
+		}
+		values = dialect.tagKeys(where)
+	} else {
+		expr, ok := groupColumns[query.Field]
+		if !ok {
+			expr = dialect.tag(strings.TrimPrefix(query.Field, "tags."), args)
+		}
+		where, err := dialect.where(logQuery, args)
+		if err != nil {
+			return "", err
+		}
+		values = fmt.Sprintf("SELECT %s AS v FROM logs%s", expr, where)
+	}
+
+	where := "v IS NOT NULL"
+	if query.Prefix != "" {
+		where += " AND " + dialect.prefix(query.Prefix, args)
+	}
+	return fmt.Sprintf("SELECT v, COUNT(*) AS n FROM (%s) f WHERE %s GROUP BY v ORDER BY n DESC, v LIMIT %d",
+		values, where, limit+1), nil
+}
+
+func facetLimit(query models.FacetQuery) int {
+	if query.Limit == 0 {
+		return DefaultFacetLimit
+	}
+	return query.Limit
+}
+
+func scanFacet(rows *sql.Rows, query models.FacetQuery, limit int) (models.Facet, error) {
+	facet := models.Facet{Field: query.Field, Values: []models.FacetValue{}}
+	for rows.Next() {
+		var value models.FacetValue
+		if err := rows.Scan(&value.Value, &value.Count); err != nil {
+			return models.Facet{}, err
+		}
+		if len(facet.Values) == limit {
+			facet.Truncated = true
+			break
+		}
+		facet.Values = append(facet.Values, value)
+	}
+	return facet, rows.Err()
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestWithoutField(t *testing.T) {
+	query := models.LogQuery{
+		Level:   []models.LogLevel{models.ERROR},
+		Service: []string{"api"},
+		Host:    []string{"host-1"},
+		Source:  []string{"app.log"},
+		Tags:    map[string]string{"env": "prod", "region": "eu"},
+		Filters: []models.FieldFilter{
+			{Field: "service", Op: models.FilterEquals, Value: "worker", Not: true},
+			{Field: "tags.env", Op: models.FilterEquals, Value: "dev", Not: true},
+			{Field: "message", Op: models.FilterEquals, Value: "boom"},
+		},
+	}
+	filterFields := func(q models.LogQuery) []string {
+		var fields []string
+		for _, f := range q.Filters {
+			fields = append(fields, f.Field)
+		}
+		return fields
+	}
+
+	got := withoutField(query, "service")
+	if got.Service != nil || !slices.Equal(got.Level, query.Level) || !slices.Equal(got.Host, query.Host) {
+		t.Errorf("service facet kept %v and dropped %v", got.Service, got.Level)
+	}
+	if fields := filterFields(got); !slices.Equal(fields, []string{"tags.env", "message"}) {
+		t.Errorf("service facet kept filters %v", fields)
+	}
+
+	got = withoutField(query, "tags.env")
+	if !maps.Equal(got.Tags, map[string]string{"region": "eu"}) || !slices.Equal(got.Service, query.Service) {
+		t.Errorf("tag facet kept tags %v and services %v", got.Tags, got.Service)
+	}
+	if fields := filterFields(got); !slices.Equal(fields, []string{"service", "message"}) {
+		t.Errorf("tag facet kept filters %v", fields)
+	}
+
+	// the tag key facet lists keys, so the tag filters still apply
+	got = withoutField(query, "tags")
+	if !maps.Equal(got.Tags, query.Tags) || len(got.Filters) != 3 {
+		t.Errorf("tags facet dropped filters: %+v", got)
+	}
+
+	// the query passed in is left alone
+	if len(query.Tags) != 2 || len(query.Filters) != 3 || query.Service == nil {
+		t.Errorf("withoutField modified its argument: %+v", query)
+	}
+}
+
+func TestFacetSQL(t *testing.T) {
+	query := models.LogQuery{
+		Service: []string{"api"},
+		Tags:    map[string]string{"env": "prod"},
+	}
+	tests := []struct {
+		name  string
+		facet models.FacetQuery
+		want  string
+		args  []interface{}
+	}{
+		{
+			name:  "a column",
+			facet: models.FacetQuery{Query: query, Field: "service"},
+			want: `SELECT v, COUNT(*) AS n FROM (SELECT service AS v FROM logs WHERE tags @> $1::jsonb) f ` +
+				`WHERE v IS NOT NULL GROUP BY v ORDER BY n DESC, v LIMIT 11`,
+			args: []interface{}{`{"env":"prod"}`},
+		},
+		{
+			name:  "the values of a tag",
+			facet: models.FacetQuery{Query: query, Field: "tags.env", Prefix: "pr"},
+			want: `SELECT v, COUNT(*) AS n FROM (SELECT tags->>$1::text AS v FROM logs WHERE service = ANY($2)) f ` +
+				`WHERE v IS NOT NULL AND v ILIKE $3 GROUP BY v ORDER BY n DESC, v LIMIT 11`,
+			args: []interface{}{"env", "{\"api\"}", "pr%"},
+		},
+		{
+			name:  "the tag keys",
+			facet: models.FacetQuery{Query: query, Field: "tags"},
+			want: `SELECT v, COUNT(*) AS n FROM (SELECT jsonb_object_keys(tags) AS v FROM logs WHERE service = ANY($1) AND tags @> $2::jsonb) f ` +
+				`WHERE v IS NOT NULL GROUP BY v ORDER BY n DESC, v LIMIT 11`,
+			args: []interface{}{"{\"api\"}", `{"env":"prod"}`},
+		},
+		{
+			name:  "a prefix with LIKE wildcards",
+			facet: models.FacetQuery{Field: "host", Prefix: "db_1%"},
+			want: `SELECT v, COUNT(*) AS n FROM (SELECT host AS v FROM logs) f ` +
+				`WHERE v IS NOT NULL AND v ILIKE $1 GROUP BY v ORDER BY n DESC, v LIMIT 11`,
+			args: []interface{}{`db\_1\%%`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &sqlArgs{}
+			got, err := facetSQL(tt.facet, 10, postgresFacet, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(argStrings(args.args), argStrings(tt.args)) {
+				t.Errorf("got args %v, want %v", argStrings(args.args), argStrings(tt.args))
+			}
+		})
+	}
+}
+
+// argStrings renders arguments the way the driver would see them, so array
+// arguments compare by value.
+func argStrings(args []interface{}) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if v, ok := arg.(driver.Valuer); ok {
+			arg, _ = v.Value()
+		}
+		switch a := arg.(type) {
+		case []byte:
+			out[i] = string(a)
+		case string:
+			out[i] = a
+		default:
+			out[i] = strings.TrimSpace(fmt.Sprint(a))
+		}
+	}
+	return out
+}
+
+func TestValidateFacet(t *testing.T) {
+	for _, field := range []string{"level", "service", "host", "source", "tags", "tags.env"} {
+		if err := validateFacet(&models.FacetQuery{Field: field}); err != nil {
+			t.Errorf("%s: got %v", field, err)
+		}
+	}
+	for _, query := range []models.FacetQuery{
+		{Field: "message"},
+		{Field: "tags."},
+		{Field: "metadata.user"},
+		{Field: "level", Limit: -1},
+	} {
+		if err := validateFacet(&query); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%+v: got %v, want ErrInvalidQuery", query, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/lib/pq"
+)
+
+// filterCondition renders a validated filter for PostgreSQL.
+func filterCondition(f models.FieldFilter, args *sqlArgs) string {
+	var condition string
+
+	if key, ok := f.TagKey(); ok {
+		switch f.Op {
+		case models.FilterExists:
+			condition = "tags ? " + args.add(key)
+		case models.FilterEquals:
+			// containment rather than ->> so the GIN index on tags is used
+			condition = fmt.Sprintf("tags @> jsonb_build_object(%s::text, %s::text)", args.add(key), args.add(f.Value))
+		default:
+			condition = fmt.Sprintf("tags->>%s::text LIKE %s", args.add(key), args.add(likePattern(f)))
+		}
+	} else if path, ok := f.MetadataPath(); ok {
+		placeholder := args.add(pq.Array(path))
+		switch f.Op {
+		case models.FilterExists:
+			condition = fmt.Sprintf("metadata #> %s::text[] IS NOT NULL", placeholder)
+		case models.FilterEquals:
+			condition = fmt.Sprintf("metadata #>> %s::text[] = %s", placeholder, args.add(f.Value))
+		default:
+			condition = fmt.Sprintf("metadata #>> %s::text[] LIKE %s", placeholder, args.add(likePattern(f)))
+		}
+	} else if f.Op == models.FilterContains {
+		condition = fmt.Sprintf("%s @> %s::jsonb", f.Field, args.add(f.Value))
+	} else if f.Op == models.FilterEquals {
+		condition = fmt.Sprintf("%s = %s", f.Field, args.add(f.Value))
+	} else {
+		condition = fmt.Sprintf("%s LIKE %s", f.Field, args.add(likePattern(f)))
+	}
+
+	if f.Not {
+		// a missing tag or metadata path makes the condition NULL, and the
+		// negation should still match it
+		return "NOT COALESCE(" + condition + ", false)"
+	}
+	return condition
+}
+
+// likePattern converts a prefix or wildcard filter into a LIKE pattern with
+// backslash escapes.
+func likePattern(f models.FieldFilter) string {
+	if f.Op == models.FilterPrefix {
+		return escapeLike(f.Value) + "%"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(f.Value); i++ {
+		switch c := f.Value[i]; {
+		case c == '\\' && i+1 < len(f.Value):
+			i++
+			writeLikeLiteral(&b, f.Value[i])
+		case c == '*':
+			b.WriteByte('%')
+		case c == '?':
+			b.WriteByte('_')
+		default:
+			writeLikeLiteral(&b, c)
+		}
+	}
+	return b.String()
+}
+
+func writeLikeLiteral(b *strings.Builder, c byte) {
+	if c == '%' || c == '_' || c == '\\' {
+		b.WriteByte('\\')
+	}
+	b.WriteByte(c)
+}
+
+// tagsJSON encodes the legacy LogQuery.Tags map for a containment match.
+func tagsJSON(tags map[string]string) string {
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
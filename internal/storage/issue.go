@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// DefaultIssueLimit is the number of issues listed when a query does not ask
+// for a limit.
+const DefaultIssueLimit = 50
+
+// maxIssueHosts caps the affected hosts kept per issue.
+const maxIssueHosts = 100
+
+var ErrIssueNotFound = errors.New("issue not found")
+
+// IssueStore is implemented by stores that group errors into issues.
+type IssueStore interface {
+	// RecordIssues adds occurrences, one per fingerprint with the count,
+	// time range and hosts seen in a batch, to their issues, creating new
+	// ones as open. It returns the resolved issues that occurred again after
+	// being resolved, which are reopened as regressed.
+	RecordIssues(occurrences []models.Issue) ([]models.Issue, error)
+	ListIssues(query models.IssueQuery) ([]models.Issue, error)
+	GetIssue(id string) (models.Issue, error)
+	SetIssueStatus(id string, status models.IssueStatus) (models.Issue, error)
+}
+
+// issueDialect holds what differs between backends.
+type issueDialect struct {
+	positional bool
+	// time converts a timestamp to the type the store keeps it as.
+	time func(time.Time) interface{}
+	// lock is appended to reads of rows about to be updated.
+	lock string
+	// least and greatest name the functions returning the smaller and the
+	// larger of two values.
+	least, greatest string
+}
+
+const issueColumns = "id, service, level, title, first_seen, last_seen, count, hosts, status, regressed, resolved_at, regressed_at, last_log_id"
+
+// scanTime reads a timestamp kept as a time by PostgreSQL or as nanoseconds
+// since the epoch by SQLite.
+type scanTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *scanTime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+	case time.Time:
+		t.Time, t.Valid = v, true
+	case int64:
+		t.Time, t.Valid = time.Unix(0, v).UTC(), true
+	default:
+		return fmt.Errorf("cannot scan %T into a timestamp", src)
+	}
+	return nil
+}
+
+func (t scanTime) ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func scanIssue(row interface{ Scan(...interface{}) error }) (models.Issue, error) {
+	var issue models.Issue
+	var hosts string
+	var firstSeen, lastSeen, resolvedAt, regressedAt scanTime
+	err := row.Scan(&issue.ID, &issue.Service, &issue.Level, &issue.Title, &firstSeen, &lastSeen,
+		&issue.Count, &hosts, &issue.Status, &issue.Regressed, &resolvedAt, &regressedAt, &issue.LastLogID)
+	if err != nil {
+		return models.Issue{}, err
+	}
+	if err := json.Unmarshal([]byte(hosts), &issue.Hosts); err != nil {
+		return models.Issue{}, fmt.Errorf("invalid hosts of issue %s: %w", issue.ID, err)
+	}
+	issue.FirstSeen, issue.LastSeen = firstSeen.Time, lastSeen.Time
+	issue.ResolvedAt, issue.RegressedAt = resolvedAt.ptr(), regressedAt.ptr()
+	return issue, nil
+}
+
+func getIssue(q sqlx.Queryer, d issueDialect, id string, lock bool) (models.Issue, error) {
+	args := &sqlArgs{positional: d.positional}
+	query := "SELECT " + issueColumns + " FROM issues WHERE id = " + args.add(id)
+	if lock {
+		query += d.lock
+	}
+	issue, err := scanIssue(q.QueryRowx(query, args.args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Issue{}, fmt.Errorf("%w: %s", ErrIssueNotFound, id)
+	}
+	return issue, err
+}
+
+// issueValues returns the values of issueColumns for issue.
+func issueValues(d issueDialect, issue models.Issue) ([]interface{}, error) {
+	hosts, err := json.Marshal(issue.Hosts)
+	if err != nil {
+		return nil, err
+	}
+	var resolvedAt, regressedAt interface{}
+	if issue.ResolvedAt != nil {
+		resolvedAt = d.time(*issue.ResolvedAt)
+	}
+	if issue.RegressedAt != nil {
+		regressedAt = d.time(*issue.RegressedAt)
+	}
+
+	return []interface{}{
+		issue.ID, issue.Service, string(issue.Level), issue.Title,
+		d.time(issue.FirstSeen), d.time(issue.LastSeen), issue.Count,
+		string(hosts), string(issue.Status), issue.Regressed,
+		resolvedAt, regressedAt, issue.LastLogID,
+	}, nil
+}
+
+// saveIssue replaces the row with the ID of issue.
+func saveIssue(tx *sqlx.Tx, d issueDialect, issue models.Issue) error {
+	values, err := issueValues(d, issue)
+	if err != nil {
+		return err
+	}
+
+	// SQLite binds arguments in the order of the SQL text
+	args := &sqlArgs{positional: d.positional}
+	columns := strings.Split(issueColumns, ", ")
+	set := make([]string, 0, len(columns)-1)
+	for i, column := range columns[1:] {
+		set = append(set, column+" = "+args.add(values[i+1]))
+	}
+	query := "UPDATE issues SET " + strings.Join(set, ", ") + " WHERE id = " + args.add(issue.ID)
+	_, err = tx.Exec(query, args.args...)
+	return err
+}
+
+// upsertIssue creates the issue of occ as open, or adds its count and time
+// range to the existing one. Doing both in one statement keeps concurrent
+// writers from racing to create the same issue.
+func upsertIssue(tx *sqlx.Tx, d issueDialect, occ models.Issue) error {
+	occ.Status, occ.Regressed, occ.ResolvedAt, occ.RegressedAt = models.IssueOpen, false, nil, nil
+	occ.Hosts = mergeHosts(nil, occ.Hosts)
+	values, err := issueValues(d, occ)
+	if err != nil {
+		return err
+	}
+
+	args := &sqlArgs{positional: d.positional}
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = args.add(v)
+	}
+	query := "INSERT INTO issues(" + issueColumns + ") VALUES (" + strings.Join(placeholders, ", ") + ")" +
+		" ON CONFLICT (id) DO UPDATE SET count = issues.count + excluded.count" +
+		", first_seen = " + d.least + "(issues.first_seen, excluded.first_seen)" +
+		", last_log_id = CASE WHEN excluded.last_seen > issues.last_seen THEN excluded.last_log_id ELSE issues.last_log_id END" +
+		", last_seen = " + d.greatest + "(issues.last_seen, excluded.last_seen)"
+	_, err = tx.Exec(query, args.args...)
+	return err
+}
+
+func recordIssues(db *sqlx.DB, d issueDialect, occurrences []models.Issue) ([]models.Issue, error) {
+	if len(occurrences) == 0 {
+		return nil, nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var regressed []models.Issue
+	for _, occ := range occurrences {
+		if err := upsertIssue(tx, d, occ); err != nil {
+			return nil, fmt.Errorf("failed to record issue %s: %w", occ.ID, err)
+		}
+		// the upsert locked the row, so the rest of the update cannot
+		// interleave with another writer
+		issue, err := getIssue(tx, d, occ.ID, false)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts := mergeHosts(issue.Hosts, occ.Hosts)
+		changed := !slices.Equal(hosts, issue.Hosts)
+		issue.Hosts = hosts
+		// errors logged before the issue was resolved are not regressions
+		if issue.Status == models.IssueResolved && (issue.ResolvedAt == nil || occ.LastSeen.After(*issue.ResolvedAt)) {
+			at := occ.LastSeen
+			issue.Status, issue.Regressed, issue.RegressedAt = models.IssueOpen, true, &at
+			regressed = append(regressed, issue)
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if err := saveIssue(tx, d, issue); err != nil {
+			return nil, fmt.Errorf("failed to update issue %s: %w", issue.ID, err)
+		}
+	}
+
+	return regressed, tx.Commit()
+}
+
+// mergeHosts returns the sorted union of hosts and more, up to maxIssueHosts.
+func mergeHosts(hosts, more []string) []string {
+	merged := slices.Concat(hosts, more)
+	slices.Sort(merged)
+	merged = slices.Compact(merged)
+	if len(merged) > maxIssueHosts {
+		merged = merged[:maxIssueHosts]
+	}
+	return merged
+}
+
+func listIssues(db *sqlx.DB, d issueDialect, query models.IssueQuery) ([]models.Issue, error) {
+	if query.Limit < 0 || query.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidQuery)
+	}
+
+	args := &sqlArgs{positional: d.positional}
+	var conditions []string
+	if len(query.Status) > 0 {
+		statuses := make([]string, len(query.Status))
+		for i, status := range query.Status {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "status IN "+args.addList(statuses))
+	}
+	if len(query.Service) > 0 {
+		conditions = append(conditions, "service IN "+args.addList(query.Service))
+	}
+	if query.Regressed {
+		conditions = append(conditions, "regressed = "+args.add(true))
+	}
+
+	sqlQuery := "SELECT " + issueColumns + " FROM issues"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = DefaultIssueLimit
+	}
+	sqlQuery += " ORDER BY last_seen DESC, id LIMIT " + args.add(limit)
+	if query.Offset > 0 {
+		sqlQuery += " OFFSET " + args.add(query.Offset)
+	}
+
+	rows, err := db.Query(sqlQuery, args.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []models.Issue{}
+	for rows.Next() {
+		issue, err := scanIssue(rows)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// setIssueStatus changes the status of an issue. Resolving it records when,
+// so later occurrences are flagged as a regression, and clears the flag of
+// an earlier one.
+func setIssueStatus(db *sqlx.DB, d issueDialect, id string, status models.IssueStatus) (models.Issue, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return models.Issue{}, err
+	}
+	defer tx.Rollback()
+
+	issue, err := getIssue(tx, d, id, true)
+	if err != nil {
+		return models.Issue{}, err
+	}
+	if status == models.IssueResolved && issue.Status != models.IssueResolved {
+		now := time.Now().UTC()
+		issue.ResolvedAt, issue.Regressed, issue.RegressedAt = &now, false, nil
+	}
+	issue.Status = status
+	if err := saveIssue(tx, d, issue); err != nil {
+		return models.Issue{}, err
+	}
+	return issue, tx.Commit()
+}
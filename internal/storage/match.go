@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+// Matcher evaluates a LogQuery against logs in memory, for logs that are not
+// in a database such as archived ones. Full-text search matches whole words
+// and phrases without stemming, so it can differ slightly from PostgreSQL.
+type Matcher struct {
+	preds []func(*logView) bool
+}
+
+// logView decodes the metadata of a log at most once.
+type logView struct {
+	log      *models.LogEntry
+	metadata interface{}
+	decoded  bool
+	words    string
+}
+
+// NewMatcher compiles the filters, search, expression and cursor of query.
+func NewMatcher(query models.LogQuery) (*Matcher, error) {
+	if err := ValidateQuery(&query); err != nil {
+		return nil, err
+	}
+	m := &Matcher{}
+
+	if query.StartTime != nil {
+		start := *query.StartTime
+		m.add(func(v *logView) bool { return !v.log.Timestamp.Before(start) })
+	}
+	if query.EndTime != nil {
+		end := *query.EndTime
+		m.add(func(v *logView) bool { return !v.log.Timestamp.After(end) })
+	}
+	if len(query.Level) > 0 {
+		levels := make([]string, len(query.Level))
+		for i, level := range query.Level {
+			levels[i] = string(level)
+		}
+		m.oneOf("level", levels)
+	}
+	m.oneOf("source", query.Source)
+	m.oneOf("service", query.Service)
+	m.oneOf("host", query.Host)
+	for key, value := range query.Tags {
+		m.add(matchFilter(models.FieldFilter{Field: "tags." + key, Op: models.FilterEquals, Value: value}))
+	}
+	for _, f := range query.Filters {
+		m.add(matchFilter(f))
+	}
+
+	if query.ExprNode != nil {
+		m.add(matchNode(query.ExprNode))
+	}
+
+	if query.Search != "" {
+		switch query.SearchMode {
+		case models.SearchFullText:
+			m.add(matchSearch(query.Search))
+		case models.SearchRegex:
+			re, err := regexp.Compile(query.Search)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+			}
+			m.add(func(v *logView) bool { return re.MatchString(v.log.Message) })
+		default:
+			m.add(matchContains(query.Search))
+		}
+	}
+
+	if query.Cursor != nil {
+		cursor := *query.Cursor
+		_, compare := keyset(query)
+		m.add(func(v *logView) bool {
+			c := v.log.Timestamp.Compare(cursor.Timestamp)
+			if c == 0 {
+				c = strings.Compare(v.log.ID, cursor.ID)
+			}
+			if compare == "<" {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+	return m, nil
+}
+
+func (m *Matcher) add(pred func(*logView) bool) {
+	m.preds = append(m.preds, pred)
+}
+
+func (m *Matcher) oneOf(field string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	m.add(func(v *logView) bool {
+		value, _ := v.field(field)
+		for _, want := range values {
+			if value == want {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Match reports whether log matches the query.
+func (m *Matcher) Match(log models.LogEntry) bool {
+	v := &logView{log: &log}
+	for _, pred := range m.preds {
+		if !pred(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// FieldValue returns the text of a column, tag or metadata path of log, named
+// as in filters, and whether log has it.
+func FieldValue(log models.LogEntry, field string) (string, bool) {
+	v := &logView{log: &log}
+	return v.field(field)
+}
+
+// field returns the text of a column, tag or metadata path, as PostgreSQL's
+// ->> and #>> operators would, and whether the log has it.
+func (v *logView) field(field string) (string, bool) {
+	switch field {
+	case "id":
+		return v.log.ID, true
+	case "level":
+		return string(v.log.Level), true
+	case "message":
+		return v.log.Message, true
+	case "source":
+		return v.log.Source, true
+	case "service":
+		return v.log.Service, true
+	case "host":
+		return v.log.Host, true
+	case "template_id":
+		return v.log.TemplateID, v.log.TemplateID != ""
+	case "fingerprint":
+		return v.log.Fingerprint, v.log.Fingerprint != ""
+	case "trace_id":
+		return v.log.TraceID, v.log.TraceID != ""
+	case "span_id":
+		return v.log.SpanID, v.log.SpanID != ""
+	case "parent_span_id":
+		return v.log.ParentSpanID, v.log.ParentSpanID != ""
+	}
+
+	if key, ok := strings.CutPrefix(field, "tags."); ok {
+		value, ok := v.log.Tags[key]
+		return value, ok
+	}
+
+	path, ok := strings.CutPrefix(field, "metadata.")
+	if !ok {
+		return "", false
+	}
+	node := v.meta()
+	for _, segment := range strings.Split(path, ".") {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if node, ok = n[segment]; !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(n) {
+				return "", false
+			}
+			node = n[i]
+		default:
+			return "", false
+		}
+	}
+	switch n := node.(type) {
+	case nil:
+		return "", false
+	case string:
+		return n, true
+	}
+	text, _ := json.Marshal(node)
+	return string(text), true
+}
+
+func (v *logView) meta() interface{} {
+	if !v.decoded {
+		v.decoded = true
+		if len(v.log.Metadata) > 0 {
+			json.Unmarshal(v.log.Metadata, &v.metadata)
+		}
+	}
+	return v.metadata
+}
+
+// matchFilter evaluates a validated filter. Like the SQL it mirrors, negated
+// filters also match logs without the field.
+func matchFilter(f models.FieldFilter) func(*logView) bool {
+	var match func(*logView) bool
+	switch f.Op {
+	case models.FilterExists:
+		match = func(v *logView) bool {
+			_, ok := v.field(f.Field)
+			return ok
+		}
+
+	case models.FilterContains:
+		var want interface{}
+		json.Unmarshal([]byte(f.Value), &want)
+		match = func(v *logView) bool {
+			if f.Field == "tags" {
+				have := make(map[string]interface{}, len(v.log.Tags))
+				for key, value := range v.log.Tags {
+					have[key] = value
+				}
+				return jsonContains(have, want)
+			}
+			return jsonContains(v.meta(), want)
+		}
+
+	case models.FilterEquals:
+		match = func(v *logView) bool {
+			value, ok := v.field(f.Field)
+			return ok && value == f.Value
+		}
+
+	case models.FilterPrefix:
+		match = func(v *logView) bool {
+			value, ok := v.field(f.Field)
+			return ok && strings.HasPrefix(value, f.Value)
+		}
+
+	default:
+		re := globRegexp(f.Value)
+		match = func(v *logView) bool {
+			value, ok := v.field(f.Field)
+			return ok && re.MatchString(value)
+		}
+	}
+
+	if f.Not {
+		return func(v *logView) bool { return !match(v) }
+	}
+	return match
+}
+
+// globRegexp compiles a glob of a wildcard filter.
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '*':
+			b.WriteString(".*")
+		case c == '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
+
+// jsonContains mirrors the JSONB @> operator.
+func jsonContains(have, want interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		h, ok := have.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range w {
+			if !jsonContains(h[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		h, ok := have.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, value := range w {
+			found := false
+			for _, candidate := range h {
+				if jsonContains(candidate, value) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(have, want)
+}
+
+func matchContains(text string) func(*logView) bool {
+	text = strings.ToLower(text)
+	return func(v *logView) bool {
+		return strings.Contains(strings.ToLower(v.log.Message), text)
+	}
+}
+
+// matchSearch evaluates web search syntax: terms are ANDed, or joins its
+// neighbours and negated terms must be absent.
+func matchSearch(search string) func(*logView) bool {
+	var groups [][]string
+	var negated []string
+	or := false
+	for _, term := range searchTerms(search) {
+		text := normalizeWords(term.text)
+		switch {
+		case text == "":
+		case term.or:
+			or = len(groups) > 0
+		case term.negated:
+			negated = append(negated, text)
+			or = false
+		case or:
+			groups[len(groups)-1] = append(groups[len(groups)-1], text)
+			or = false
+		default:
+			groups = append(groups, []string{text})
+		}
+	}
+
+	return func(v *logView) bool {
+		if len(groups) == 0 {
+			return false
+		}
+		words := v.normalized()
+		for _, text := range negated {
+			if containsWords(words, text) {
+				return false
+			}
+		}
+		for _, group := range groups {
+			found := false
+			for _, text := range group {
+				if containsWords(words, text) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// normalized returns the words of the message, lower case and separated by
+// single spaces, with a space on either side.
+func (v *logView) normalized() string {
+	if v.words == "" {
+		v.words = " " + normalizeWords(v.log.Message) + " "
+	}
+	return v.words
+}
+
+func normalizeWords(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func containsWords(words, text string) bool {
+	return strings.Contains(words, " "+text+" ")
+}
+
+// matchNode evaluates a parsed expression like exprCondition compiles it.
+func matchNode(node querylang.Node) func(*logView) bool {
+	switch n := node.(type) {
+	case *querylang.And:
+		left, right := matchNode(n.Left), matchNode(n.Right)
+		return func(v *logView) bool { return left(v) && right(v) }
+	case *querylang.Or:
+		left, right := matchNode(n.Left), matchNode(n.Right)
+		return func(v *logView) bool { return left(v) || right(v) }
+	case *querylang.Not:
+		x := matchNode(n.X)
+		return func(v *logView) bool { return !x(v) }
+	case *querylang.Term:
+		return matchTerm(n)
+	}
+	panic("unknown query node")
+}
+
+func matchTerm(term *querylang.Term) func(*logView) bool {
+	switch {
+	case term.Field == "":
+		if term.Quoted {
+			return matchSearch(`"` + term.Value + `"`)
+		}
+		return matchSearch(term.Value)
+
+	case term.Field == "message" && (term.Quoted || !strings.ContainsAny(term.Value, "*?")):
+		match := matchContains(term.Value)
+		if term.Negate {
+			return func(v *logView) bool { return !match(v) }
+		}
+		return match
+	}
+
+	value := term.Value
+	if term.Field == "level" {
+		value = strings.ToUpper(value)
+	}
+	f := models.FieldFilter{Field: term.Field, Op: models.FilterEquals, Value: value}
+	if !term.Quoted {
+		f = models.GlobFilter(term.Field, value)
+	}
+	f.Not = term.Negate
+	return matchFilter(f)
+}
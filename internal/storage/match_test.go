@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestMatcher(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after := ts.Add(-time.Minute), ts.Add(time.Minute)
+	log := models.LogEntry{
+		ID:        "log-1",
+		Timestamp: ts,
+		Level:     models.ERROR,
+		Message:   "Payment failed: card declined for order-42",
+		Source:    "app.log",
+		Service:   "payments",
+		Host:      "web-3",
+		Tags:      map[string]string{"env": "prod", "region": "eu"},
+		Metadata:  []byte(`{"user":{"id":42,"roles":["admin","billing"]},"retry":true,"amount":12.5}`),
+		TraceID:   "trace-1",
+	}
+
+	filter := func(s string) models.LogQuery {
+		f, err := models.ParseFilter(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return models.LogQuery{Filters: []models.FieldFilter{f}}
+	}
+
+	tests := []struct {
+		name  string
+		query models.LogQuery
+		want  bool
+	}{
+		{"empty", models.LogQuery{}, true},
+		{"in range", models.LogQuery{StartTime: &before, EndTime: &after}, true},
+		{"bounds are inclusive", models.LogQuery{StartTime: &ts, EndTime: &ts}, true},
+		{"after end", models.LogQuery{EndTime: &before}, false},
+		{"before start", models.LogQuery{StartTime: &after}, false},
+		{"level", models.LogQuery{Level: []models.LogLevel{models.WARN, models.ERROR}}, true},
+		{"other level", models.LogQuery{Level: []models.LogLevel{models.INFO}}, false},
+		{"service", models.LogQuery{Service: []string{"auth", "payments"}}, true},
+		{"other host", models.LogQuery{Host: []string{"web-1"}}, false},
+		{"tags", models.LogQuery{Tags: map[string]string{"env": "prod"}}, true},
+		{"other tag value", models.LogQuery{Tags: map[string]string{"env": "dev"}}, false},
+
+		{"equals", filter("trace_id=trace-1"), true},
+		{"not equals", filter("host!=web-3"), false},
+		{"prefix", filter("host=web-*"), true},
+		{"wildcard", filter("host=w?b-*"), true},
+		{"escaped wildcard", filter(`host=web-\*`), false},
+		{"tag exists", filter("tags.region"), true},
+		{"tag missing", filter("tags.team"), false},
+		{"negated filter matches missing fields", filter("tags.team!=core"), true},
+		{"metadata number", filter("metadata.user.id=42"), true},
+		{"metadata bool", filter("metadata.retry=true"), true},
+		{"metadata float", filter("metadata.amount=12.5"), true},
+		{"metadata object is json", filter(`metadata.user=*"id":42*`), true},
+		{"metadata missing", filter("!metadata.user.name"), true},
+		{"tags contain", filter(`tags@>{"env":"prod"}`), true},
+		{"tags do not contain", filter(`tags@>{"env":"prod","team":"core"}`), false},
+		{"metadata contains nested", filter(`metadata@>{"user":{"roles":["billing"]}}`), true},
+		{"metadata does not contain", filter(`metadata@>{"user":{"roles":["root"]}}`), false},
+
+		{"substring", models.LogQuery{Search: "CARD DECL", SearchMode: models.SearchSubstring}, true},
+		{"substring is the default", models.LogQuery{Search: "order-4"}, true},
+		{"regex", models.LogQuery{Search: `order-\d+$`, SearchMode: models.SearchRegex}, true},
+		{"full-text words", models.LogQuery{Search: "payment declined", SearchMode: models.SearchFullText}, true},
+		{"full-text whole words only", models.LogQuery{Search: "declin", SearchMode: models.SearchFullText}, false},
+		{"full-text phrase", models.LogQuery{Search: `"card declined"`, SearchMode: models.SearchFullText}, true},
+		{"full-text phrase out of order", models.LogQuery{Search: `"declined card"`, SearchMode: models.SearchFullText}, false},
+		{"full-text or", models.LogQuery{Search: "timeout or declined", SearchMode: models.SearchFullText}, true},
+		{"full-text negation", models.LogQuery{Search: "payment -card", SearchMode: models.SearchFullText}, false},
+
+		{"expression", models.LogQuery{Expr: "level:error service:pay* AND tags.env:prod"}, true},
+		{"expression or", models.LogQuery{Expr: "service:auth OR host:web-3"}, true},
+		{"expression not", models.LogQuery{Expr: "NOT msg:declined"}, false},
+		{"expression quoted glob is literal", models.LogQuery{Expr: `host:"web-*"`}, false},
+		{"expression free text", models.LogQuery{Expr: `"card declined"`}, true},
+		{"expression metadata", models.LogQuery{Expr: "metadata.user.id:4*"}, true},
+		{"expression field list", models.LogQuery{Expr: "service!=(auth OR payments)"}, false},
+
+		{"cursor after", models.LogQuery{Cursor: &models.Cursor{Timestamp: after, ID: "x"}}, true},
+		{"cursor same time, lower id", models.LogQuery{Cursor: &models.Cursor{Timestamp: ts, ID: "log-0"}}, false},
+		{"cursor same time, higher id", models.LogQuery{Cursor: &models.Cursor{Timestamp: ts, ID: "log-2"}}, true},
+		{"cursor ascending", models.LogQuery{Order: models.OrderAsc, Cursor: &models.Cursor{Timestamp: before, ID: "x"}}, true},
+		{"cursor before", models.LogQuery{Cursor: &models.Cursor{Timestamp: after, ID: "x", Before: true}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Match(log); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherRejectsInvalidQueries(t *testing.T) {
+	tests := map[string]models.LogQuery{
+		"expression":  {Expr: "sevice:api"},
+		"regex":       {Search: "(", SearchMode: models.SearchRegex},
+		"search mode": {Search: "a", SearchMode: "fuzzy"},
+		"filter":      {Filters: []models.FieldFilter{{Field: "colour", Op: models.FilterEquals, Value: "red"}}},
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewMatcher(query); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("got %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// MemoryQueue is a bounded in-process queue. Its contents are lost when the
+// process exits, so it suits tests and small single-node deployments.
+type MemoryQueue struct {
+	mu                sync.Mutex
+	capacity          int
+	visibilityTimeout time.Duration
+	logs              []models.LogEntry
+	// inflight holds the dequeued logs by ID, oldest first, as agents may
+	// send the same ID more than once
+	inflight      map[string][]inflightLog
+	inflightCount int
+	dequeued      int64 // number of logs dequeued so far, orders inflight
+}
+
+type inflightLog struct {
+	log      models.LogEntry
+	deadline time.Time
+	seq      int64
+}
+
+// NewMemoryQueue creates a queue holding at most capacity logs, counting both
+// queued and in-flight ones. A capacity of zero or less means unbounded.
+// Logs not acknowledged within visibilityTimeout of being dequeued are
+// delivered again.
+func NewMemoryQueue(capacity int, visibilityTimeout time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		capacity:          capacity,
+		visibilityTimeout: visibilityTimeout,
+		inflight:          make(map[string][]inflightLog),
+	}
+}
+
+func (q *MemoryQueue) Enqueue(log models.LogEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.capacity > 0 && len(q.logs)+q.inflightCount >= q.capacity {
+		return ErrQueueFull
+	}
+	q.logs = append(q.logs, log)
+	return nil
+}
+
+func (q *MemoryQueue) Dequeue(count int64) ([]models.LogEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.reclaim(time.Now())
+	n := min(int(count), len(q.logs))
+	if n == 0 {
+		return nil, nil
+	}
+
+	logs := make([]models.LogEntry, n)
+	copy(logs, q.logs)
+	clear(q.logs[:n])
+	q.logs = q.logs[n:]
+
+	deadline := time.Now().Add(q.visibilityTimeout)
+	for _, log := range logs {
+		q.inflight[log.ID] = append(q.inflight[log.ID], inflightLog{log: log, deadline: deadline, seq: q.dequeued})
+		q.dequeued++
+	}
+	q.inflightCount += len(logs)
+	return logs, nil
+}
+
+// reclaim puts in-flight logs whose visibility timeout passed back at the
+// front of the queue, oldest first.
+func (q *MemoryQueue) reclaim(now time.Time) {
+	var expired []inflightLog
+	for id, entries := range q.inflight {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if now.After(entry.deadline) {
+				expired = append(expired, entry)
+			} else {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			delete(q.inflight, id)
+		} else {
+			q.inflight[id] = kept
+		}
+	}
+	q.inflightCount -= len(expired)
+	if len(expired) == 0 {
+		return
+	}
+
+	slices.SortFunc(expired, func(a, b inflightLog) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	logs := make([]models.LogEntry, 0, len(expired)+len(q.logs))
+	for _, entry := range expired {
+		logs = append(logs, entry.log)
+	}
+	q.logs = append(logs, q.logs...)
+}
+
+func (q *MemoryQueue) Ack(logs []models.LogEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// each acknowledgement releases the oldest in-flight log with its ID
+	for _, log := range logs {
+		entries := q.inflight[log.ID]
+		switch len(entries) {
+		case 0:
+			continue
+		case 1:
+			delete(q.inflight, log.ID)
+		default:
+			q.inflight[log.ID] = entries[1:]
+		}
+		q.inflightCount--
+	}
+	return nil
+}
+
+func (q *MemoryQueue) Len() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return int64(len(q.logs)), nil
+}
+
+func (q *MemoryQueue) Close() error {
+	return nil
+}
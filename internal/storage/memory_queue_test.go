@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestMemoryQueueReclaim(t *testing.T) {
+	q := NewMemoryQueue(0, time.Minute)
+	enqueueIDs(t, q, "a0", "a1", "a2", "a3")
+	dequeueIDs(t, q, 2)
+	now := time.Now()
+
+	q.mu.Lock()
+	q.reclaim(now)
+	q.mu.Unlock()
+	if n, _ := q.Len(); n != 2 {
+		t.Fatalf("reclaimed before the deadline, length %d", n)
+	}
+
+	// expired logs go back to the front, oldest first, acknowledged ones
+	// are gone
+	dequeueIDs(t, q, 1)
+	ackIDs(t, q, "a1")
+	q.mu.Lock()
+	q.reclaim(now.Add(2 * time.Minute))
+	q.mu.Unlock()
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, []string{"a0", "a2", "a3"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestMemoryQueueRedeliversOnDequeue(t *testing.T) {
+	q := NewMemoryQueue(0, 10*time.Millisecond)
+	enqueueIDs(t, q, "a0", "a1")
+	dequeueIDs(t, q, 1)
+	time.Sleep(20 * time.Millisecond)
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, []string{"a0", "a1"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestMemoryQueueDuplicateIDs(t *testing.T) {
+	q := NewMemoryQueue(3, time.Minute)
+	enqueueIDs(t, q, "a", "a", "b")
+	dequeueIDs(t, q, 3)
+
+	// one acknowledgement releases one copy
+	ackIDs(t, q, "a")
+	enqueueIDs(t, q, "c")
+	if err := q.Enqueue(models.LogEntry{ID: "d"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+
+	// the other copy is still in flight and comes back on expiry
+	q.mu.Lock()
+	q.reclaim(time.Now().Add(2 * time.Minute))
+	q.mu.Unlock()
+	if got := dequeueIDs(t, q, 100); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("got %v", got)
+	}
+	ackIDs(t, q, "a", "b", "c", "a")
+	if q.inflightCount != 0 || len(q.inflight) != 0 {
+		t.Errorf("got %d logs in flight", q.inflightCount)
+	}
+}
+
+func TestMemoryQueueFull(t *testing.T) {
+	q := NewMemoryQueue(2, time.Minute)
+	enqueueIDs(t, q, "a0", "a1")
+	dequeueIDs(t, q, 1)
+	if err := q.Enqueue(models.LogEntry{ID: "a2"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+	ackIDs(t, q, "a0")
+	enqueueIDs(t, q, "a2")
+}
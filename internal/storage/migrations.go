@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/krishnaGauss/SoCode/internal/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// NewMigrator returns a migrator for the PostgreSQL schema, built from the
+// SQL files under migrations/.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, files)
+}
+
+// migrateSchema brings the schema up to date, or with auto disabled only
+// checks that it is.
+func (s *PostgresStorage) migrateSchema(auto bool) error {
+	migrator, err := NewMigrator(s.db.DB)
+	if err != nil {
+		return err
+	}
+
+	if auto {
+		if _, err := migrator.Up(); err != nil {
+			return fmt.Errorf("failed to migrate schema: %w", err)
+		}
+		return nil
+	}
+
+	pending, err := migrator.Pending()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		slog.Warn("database schema is out of date, run migrate up", slog.Int("pending", pending))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+-- Databases created before migrations existed already have a logs table,
+-- partitioned or not, which is left as it is.
+DO $$
+BEGIN
+	IF to_regclass('logs') IS NULL THEN
+		CREATE TABLE logs(
+			id VARCHAR(255) NOT NULL,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			level VARCHAR(20) NOT NULL,
+			message TEXT NOT NULL,
+			source VARCHAR(255) NOT NULL,
+			service VARCHAR(255) NOT NULL,
+			host VARCHAR(255) NOT NULL,
+			tags JSONB,
+			metadata JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (id, timestamp)
+		) PARTITION BY RANGE (timestamp);
+
+		CREATE TABLE logs_default PARTITION OF logs DEFAULT;
+	END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_level ON logs(level);
+CREATE INDEX IF NOT EXISTS idx_logs_source ON logs(source);
+CREATE INDEX IF NOT EXISTS idx_logs_service ON logs(service);
+CREATE INDEX IF NOT EXISTS idx_logs_host ON logs(host);
+CREATE INDEX IF NOT EXISTS idx_logs_tags ON logs USING GIN(tags);
+CREATE INDEX IF NOT EXISTS idx_logs_message ON logs USING GIN(to_tsvector('english', message));
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
+DROP INDEX IF EXISTS idx_logs_timestamp_id;
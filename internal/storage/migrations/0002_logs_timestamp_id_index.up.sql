@@ -0,0 +1,3 @@
+-- Keyset pagination orders and seeks on (timestamp, id).
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp_id ON logs(timestamp, id);
+DROP INDEX IF EXISTS idx_logs_timestamp;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS log_patterns;
+DROP INDEX IF EXISTS idx_logs_template_id;
+ALTER TABLE logs DROP COLUMN IF EXISTS template_id;
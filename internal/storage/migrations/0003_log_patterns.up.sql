@@ -0,0 +1,12 @@
+-- Message templates mined by the log processor, see package patterns.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS template_id VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_logs_template_id ON logs(template_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS log_patterns(
+	id VARCHAR(32) PRIMARY KEY,
+	service VARCHAR(255) NOT NULL,
+	template TEXT NOT NULL,
+	first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+	last_seen TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_log_patterns_service ON log_patterns(service);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS issues;
+DROP INDEX IF EXISTS idx_logs_fingerprint;
+ALTER TABLE logs DROP COLUMN IF EXISTS fingerprint;
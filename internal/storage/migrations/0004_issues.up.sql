@@ -0,0 +1,21 @@
+-- ERROR and FATAL logs grouped by fingerprint, see package issues.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_logs_fingerprint ON logs(fingerprint, timestamp);
+
+CREATE TABLE IF NOT EXISTS issues(
+	id VARCHAR(32) PRIMARY KEY,
+	service VARCHAR(255) NOT NULL,
+	level VARCHAR(20) NOT NULL,
+	title TEXT NOT NULL,
+	first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+	last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+	count BIGINT NOT NULL,
+	hosts JSONB NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'open',
+	regressed BOOLEAN NOT NULL DEFAULT false,
+	resolved_at TIMESTAMP WITH TIME ZONE,
+	regressed_at TIMESTAMP WITH TIME ZONE,
+	last_log_id VARCHAR(255) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_issues_last_seen ON issues(last_seen);
+CREATE INDEX IF NOT EXISTS idx_issues_status ON issues(status);
@@ -0,0 +1,7 @@
+-- W3C trace context of the log, so the logs of a trace can be gathered
+-- across services.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id VARCHAR(64);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS span_id VARCHAR(64);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS parent_span_id VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_logs_trace_id ON logs(trace_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_span_id ON logs(span_id, timestamp);
@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/lib/pq"
+)
+
+// partitionLockKey is the advisory lock held while partitions are created or
+// migrated, so concurrent instances do not race each other.
+const partitionLockKey = 0x50c0de01
+
+type PartitionInterval string
+
+const (
+	PartitionDaily  PartitionInterval = "daily"
+	PartitionHourly PartitionInterval = "hourly"
+)
+
+func ParsePartitionInterval(s string) (PartitionInterval, error) {
+	switch PartitionInterval(s) {
+	case "", PartitionDaily:
+		return PartitionDaily, nil
+	case PartitionHourly:
+		return PartitionHourly, nil
+	}
+	return "", fmt.Errorf("unknown partition interval %q, want daily or hourly", s)
+}
+
+// Truncate returns the start of the partition containing t, in UTC.
+func (i PartitionInterval) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	if i == PartitionHourly {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Next returns the start of the partition following the one starting at t.
+func (i PartitionInterval) Next(t time.Time) time.Time {
+	if i == PartitionHourly {
+		return t.Add(time.Hour)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func (i PartitionInterval) name(start time.Time) string {
+	if i == PartitionHourly {
+		return "logs_p" + start.Format("2006010215")
+	}
+	return "logs_p" + start.Format("20060102")
+}
+
+// Partition is a child table of logs covering [From, To).
+type Partition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// Partitioned reports whether logs is a range partitioned table. It is false
+// for databases created before partitioning that have not been migrated.
+func (s *PostgresStorage) Partitioned() bool {
+	return s.partitioned
+}
+
+// checkPartitioning detects a logs table created before partitioning and,
+// when migrate is set, converts it. Otherwise it keeps working unpartitioned.
+func (s *PostgresStorage) checkPartitioning(migrate bool) error {
+	partitioned, err := s.isPartitioned(s.db)
+	if err != nil {
+		return err
+	}
+
+	if !partitioned && migrate {
+		if err := s.migrateToPartitioned(); err != nil {
+			return fmt.Errorf("failed to partition logs table: %w", err)
+		}
+		partitioned = true
+	} else if !partitioned {
+		slog.Warn("logs table is not partitioned, set DB_MIGRATE_PARTITIONS=true to convert it")
+	}
+
+	s.partitioned = partitioned
+	return nil
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *PostgresStorage) isPartitioned(q queryer) (bool, error) {
+	var kind string
+	err := q.QueryRowContext(context.Background(), `SELECT relkind FROM pg_class WHERE oid = 'logs'::regclass`).Scan(&kind)
+	if err != nil {
+		return false, err
+	}
+	return kind == "p", nil
+}
+
+// legacyKeyIndex is the (id, timestamp) index built on an unpartitioned logs
+// table before it becomes a partition, matching the primary key of the
+// partitioned table.
+const legacyKeyIndex = "logs_id_timestamp"
+
+// migrateToPartitioned renames an unpartitioned logs table to logs_legacy,
+// creates the partitioned logs table in its place and attaches logs_legacy as
+// a single partition spanning its existing rows. No rows are copied and the
+// (id, timestamp) index is built concurrently beforehand, so writes are only
+// blocked while the range check scans the table; logs_legacy goes away once
+// retention drops it.
+func (s *PostgresStorage) migrateToPartitioned() error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// a session lock, as the index build cannot run in a transaction
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, partitionLockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, partitionLockKey)
+
+	// another instance may have migrated while we waited for the lock
+	if partitioned, err := s.isPartitioned(conn); err != nil || partitioned {
+		return err
+	}
+
+	if err := buildLegacyKey(ctx, conn); err != nil {
+		return fmt.Errorf("failed to index logs by (id, timestamp): %w", err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE logs IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	var minTS, maxTS sql.NullTime
+	if err := tx.QueryRow(`SELECT MIN(timestamp), MAX(timestamp) FROM logs`).Scan(&minTS, &maxTS); err != nil {
+		return err
+	}
+
+	// the new table gets the same indexes, whichever migrations created them
+	indexes, err := logsIndexes(tx)
+	if err != nil {
+		return err
+	}
+
+	// free the table, constraint and index names for the new table
+	rename := fmt.Sprintf(`
+		ALTER TABLE logs RENAME TO logs_legacy;
+		ALTER TABLE logs_legacy RENAME CONSTRAINT logs_pkey TO logs_legacy_pkey;
+		ALTER INDEX %s RENAME TO logs_legacy_id_timestamp;
+	`, legacyKeyIndex)
+	for _, index := range indexes {
+		rename += fmt.Sprintf("ALTER INDEX %s RENAME TO %s;\n", pq.QuoteIdentifier(index.name), pq.QuoteIdentifier(legacyIndexName(index.name)))
+	}
+	if _, err := tx.Exec(rename); err != nil {
+		return err
+	}
+
+	create := `
+		CREATE TABLE logs (LIKE logs_legacy INCLUDING DEFAULTS, PRIMARY KEY (id, timestamp)) PARTITION BY RANGE (timestamp);
+		CREATE TABLE logs_default PARTITION OF logs DEFAULT;
+	`
+	for _, index := range indexes {
+		create += index.definition + ";\n"
+	}
+	if _, err := tx.Exec(create); err != nil {
+		return err
+	}
+
+	if !minTS.Valid {
+		_, err := tx.Exec(`DROP TABLE logs_legacy`)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	from := s.interval.Truncate(minTS.Time)
+	to := s.interval.Next(s.interval.Truncate(maxTS.Time))
+
+	// the check constraint lets ATTACH skip scanning the table again, and
+	// the unique index satisfies the (id, timestamp) primary key of the
+	// parent
+	attach := fmt.Sprintf(`
+		ALTER TABLE logs_legacy ADD CONSTRAINT logs_legacy_range CHECK (timestamp >= %[1]s AND timestamp < %[2]s);
+		ALTER TABLE logs ATTACH PARTITION logs_legacy FOR VALUES FROM (%[1]s) TO (%[2]s);
+	`, timestampLiteral(from), timestampLiteral(to))
+	if _, err := tx.Exec(attach); err != nil {
+		return err
+	}
+
+	slog.Info("partitioned logs table", slog.Time("legacy_from", from), slog.Time("legacy_to", to))
+	return tx.Commit()
+}
+
+// buildLegacyKey creates the unique (id, timestamp) index on the logs table
+// without blocking writes. An invalid index left by an interrupted build is
+// dropped and built again.
+func buildLegacyKey(ctx context.Context, conn *sql.Conn) error {
+	var valid bool
+	err := conn.QueryRowContext(ctx, `SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1)`, legacyKeyIndex).Scan(&valid)
+	switch {
+	case err == nil && valid:
+		return nil
+	case err == nil:
+		if _, err := conn.ExecContext(ctx, "DROP INDEX CONCURRENTLY "+legacyKeyIndex); err != nil {
+			return err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("CREATE UNIQUE INDEX CONCURRENTLY %s ON logs (id, timestamp)", legacyKeyIndex))
+	return err
+}
+
+type logsIndex struct {
+	name       string
+	definition string
+}
+
+// logsIndexes returns the secondary indexes of the logs table.
+func logsIndexes(tx *sql.Tx) ([]logsIndex, error) {
+	rows, err := tx.Query(`
+		SELECT indexname, indexdef FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = 'logs' AND indexname NOT IN ('logs_pkey', $1)
+	`, legacyKeyIndex)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []logsIndex
+	for rows.Next() {
+		var index logsIndex
+		if err := rows.Scan(&index.name, &index.definition); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// legacyIndexName renames idx_logs_level to idx_logs_legacy_level.
+func legacyIndexName(name string) string {
+	if rest, ok := strings.CutPrefix(name, "idx_logs_"); ok {
+		return "idx_logs_legacy_" + rest
+	}
+	return name + "_legacy"
+}
+
+// EnsurePartitions creates the partition containing now plus ahead future
+// ones. Rows already sitting in the default partition for a new range are
+// moved into it.
+func (s *PostgresStorage) EnsurePartitions(now time.Time, ahead int) error {
+	if !s.partitioned {
+		return nil
+	}
+
+	existing, err := s.Partitions()
+	if err != nil {
+		return err
+	}
+
+	start := s.interval.Truncate(now)
+	for i := 0; i <= ahead; i++ {
+		end := s.interval.Next(start)
+		if !overlapsAny(existing, start, end) {
+			if err := s.createPartition(start, end); err != nil {
+				return fmt.Errorf("failed to create partition %s: %w", s.interval.name(start), err)
+			}
+		}
+		start = end
+	}
+	return nil
+}
+
+func overlapsAny(partitions []Partition, from, to time.Time) bool {
+	for _, p := range partitions {
+		if p.From.Before(to) && from.Before(p.To) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PostgresStorage) createPartition(from, to time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, partitionLockKey).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil // another instance is maintaining partitions
+	}
+
+	name := s.interval.name(from)
+	var exists sql.NullString
+	if err := tx.QueryRow(`SELECT to_regclass($1)::text`, name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists.Valid {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+		CREATE TABLE %[1]s (LIKE logs INCLUDING DEFAULTS);
+		WITH moved AS (
+			DELETE FROM logs_default WHERE timestamp >= %[2]s AND timestamp < %[3]s RETURNING *
+		)
+		INSERT INTO %[1]s SELECT * FROM moved;
+		ALTER TABLE logs ATTACH PARTITION %[1]s FOR VALUES FROM (%[2]s) TO (%[3]s);
+	`, name, timestampLiteral(from), timestampLiteral(to))
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	slog.Info("created partition", slog.String("partition", name))
+	return tx.Commit()
+}
+
+var partitionBoundRe = regexp.MustCompile(`FROM \('([^']+)'\) TO \('([^']+)'\)`)
+
+// Partitions lists the range partitions of logs, oldest first. The default
+// partition is not included.
+func (s *PostgresStorage) Partitions() ([]Partition, error) {
+	rows, err := s.db.Query(`
+		SELECT c.relname, pg_get_expr(c.relpartbound, c.oid)
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'logs'::regclass
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []Partition
+	for rows.Next() {
+		var name, bound string
+		if err := rows.Scan(&name, &bound); err != nil {
+			return nil, err
+		}
+
+		m := partitionBoundRe.FindStringSubmatch(bound)
+		if m == nil {
+			continue // DEFAULT
+		}
+		from, err := parsePGTimestamp(m[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parsePGTimestamp(m[2])
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, Partition{Name: name, From: from, To: to})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(partitions, func(a, b Partition) int {
+		return a.From.Compare(b.From)
+	})
+	return partitions, nil
+}
+
+func parsePGTimestamp(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999-07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unexpected partition bound %q", s)
+}
+
+func timestampLiteral(t time.Time) string {
+	return "'" + t.UTC().Format(time.RFC3339) + "'"
+}
+
+// PartitionManager keeps future partitions of the logs table created ahead of
+// time.
+type PartitionManager struct {
+	storage  *PostgresStorage
+	ahead    int
+	interval time.Duration
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+func NewPartitionManager(storage *PostgresStorage, cfg *config.DatabaseConfig) *PartitionManager {
+	return &PartitionManager{
+		storage:  storage,
+		ahead:    cfg.PartitionPremake,
+		interval: 10 * time.Minute,
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+}
+
+func (m *PartitionManager) Start() {
+	defer close(m.doneChan)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.storage.EnsurePartitions(time.Now(), m.ahead); err != nil {
+			slog.Warn("partition maintenance failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+func (m *PartitionManager) Stop() {
+	close(m.stopChan)
+	<-m.doneChan
+}
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// openTestPostgres connects to the database in DATABASE_URL, like the write
+// benchmarks, with a fresh schema first on the search path so tests can
+// create and migrate their own logs table. It skips the test when
+// DATABASE_URL is not set.
+func openTestPostgres(t *testing.T) *PostgresStorage {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("socode_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	// lib/pq sends parameters it does not know as run-time settings
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PostgresStorage{db: db, interval: PartitionDaily}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// createLegacyTable creates logs the way versions before partitioning did,
+// holding the given logs.
+func createLegacyTable(t *testing.T, s *PostgresStorage, logs ...models.LogEntry) {
+	t.Helper()
+	_, err := s.db.Exec(`
+		CREATE TABLE logs(
+			id VARCHAR(255) PRIMARY KEY,
+			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+			level VARCHAR(20) NOT NULL,
+			message TEXT NOT NULL,
+			source VARCHAR(255) NOT NULL,
+			service VARCHAR(255) NOT NULL,
+			host VARCHAR(255) NOT NULL,
+			tags JSONB,
+			metadata JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX idx_logs_level ON logs(level);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, log := range logs {
+		_, err := s.db.Exec(`INSERT INTO logs (id, timestamp, level, message, source, service, host) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			log.ID, log.Timestamp, log.Level, log.Message, log.Source, log.Service, log.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func partitionLog(id string, ts time.Time, message string) models.LogEntry {
+	return models.LogEntry{ID: id, Timestamp: ts, Level: models.INFO, Message: message, Source: "app.log", Service: "api", Host: "web-1"}
+}
+
+// partitionOf returns the partition holding the log with id and timestamp.
+func partitionOf(t *testing.T, s *PostgresStorage, id string, ts time.Time) string {
+	t.Helper()
+	var name string
+	if err := s.db.QueryRow(`SELECT tableoid::regclass::text FROM logs WHERE id = $1 AND timestamp = $2`, id, ts).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func partitionNames(t *testing.T, s *PostgresStorage) []string {
+	t.Helper()
+	partitions, err := s.Partitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range partitions {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func indexNames(t *testing.T, s *PostgresStorage, table string) []string {
+	t.Helper()
+	var names []string
+	if err := s.db.Select(&names, `SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1`, table); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestPartitionInterval(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 13, 45, 0, 0, time.FixedZone("CEST", 2*3600))
+	tests := []struct {
+		interval PartitionInterval
+		start    time.Time
+		next     time.Time
+		name     string
+	}{
+		{PartitionDaily, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), "logs_p20240501"},
+		{PartitionHourly, time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "logs_p2024050111"},
+	}
+	for _, tt := range tests {
+		start := tt.interval.Truncate(ts)
+		if !start.Equal(tt.start) || !tt.interval.Next(start).Equal(tt.next) || tt.interval.name(start) != tt.name {
+			t.Errorf("%s: got %s to %s named %s", tt.interval, start, tt.interval.Next(start), tt.interval.name(start))
+		}
+	}
+
+	if _, err := ParsePartitionInterval("weekly"); err == nil {
+		t.Error("got no error for weekly")
+	}
+}
+
+func TestEnsurePartitions(t *testing.T) {
+	s := openTestPostgres(t)
+	if err := s.migrateSchema(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkPartitioning(false); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Partitioned() {
+		t.Fatal("a new logs table is not partitioned")
+	}
+
+	today := s.interval.Truncate(time.Now())
+	tomorrow := s.interval.Next(today)
+	// a log written before its partition exists lands in the default one
+	early := partitionLog("early", tomorrow.Add(time.Hour), "early")
+	if err := s.StoreLogs([]models.LogEntry{early}); err != nil {
+		t.Fatal(err)
+	}
+	if got := partitionOf(t, s, "early", early.Timestamp); got != "logs_default" {
+		t.Fatalf("got partition %s, want logs_default", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.EnsurePartitions(time.Now(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{s.interval.name(today), s.interval.name(tomorrow)}
+	if got := partitionNames(t, s); !slices.Equal(got, want) {
+		t.Errorf("got partitions %v, want %v", got, want)
+	}
+	// and is moved once it does
+	if got := partitionOf(t, s, "early", early.Timestamp); got != want[1] {
+		t.Errorf("got partition %s, want %s", got, want[1])
+	}
+}
+
+func TestMigrateLegacyTable(t *testing.T) {
+	s := openTestPostgres(t)
+	today := s.interval.Truncate(time.Now())
+	day := 24 * time.Hour
+	legacy := []models.LogEntry{
+		partitionLog("old-1", today.Add(-3*day+time.Hour), "three days ago"),
+		partitionLog("old-2", today.Add(-2*day), "two days ago"),
+		partitionLog("old-3", today.Add(-day+time.Hour), "yesterday"),
+	}
+	createLegacyTable(t, s, legacy...)
+	if err := s.migrateSchema(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the flag the table is left alone
+	if err := s.checkPartitioning(false); err != nil {
+		t.Fatal(err)
+	}
+	if s.Partitioned() {
+		t.Fatal("converted without DB_MIGRATE_PARTITIONS")
+	}
+	if err := s.EnsurePartitions(time.Now(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.checkPartitioning(true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !s.Partitioned() {
+		t.Fatal("logs is not partitioned")
+	}
+
+	partitions, err := s.Partitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Partition{Name: "logs_legacy", From: today.Add(-3 * day), To: today}
+	if len(partitions) != 1 || partitions[0].Name != want.Name || !partitions[0].From.Equal(want.From) || !partitions[0].To.Equal(want.To) {
+		t.Fatalf("got partitions %+v, want %+v", partitions, want)
+	}
+
+	// the legacy rows are attached, not copied
+	for _, log := range legacy {
+		if got := partitionOf(t, s, log.ID, log.Timestamp); got != "logs_legacy" {
+			t.Errorf("%s is in %s", log.ID, got)
+		}
+	}
+	logs, err := s.QueryLogs(models.LogQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 3 {
+		t.Errorf("got %d logs, want 3", len(logs))
+	}
+
+	// the indexes moved with the table and were recreated on logs
+	if got := indexNames(t, s, "logs_legacy"); !slices.Contains(got, "idx_logs_legacy_level") || !slices.Contains(got, "logs_legacy_id_timestamp") {
+		t.Errorf("got legacy indexes %v", got)
+	}
+	if got := indexNames(t, s, "logs"); !slices.Contains(got, "idx_logs_level") || slices.Contains(got, legacyKeyIndex) {
+		t.Errorf("got indexes %v", got)
+	}
+
+	// a redelivered legacy log is skipped, the same ID at another time is
+	// a different log
+	inserted, err := s.InsertLogs([]models.LogEntry{legacy[0], partitionLog("old-1", today.Add(time.Hour), "today")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 1 || inserted[0].Message != "today" {
+		t.Errorf("inserted %+v", inserted)
+	}
+	log, err := GetLog(s, "old-1")
+	if err != nil || log.Message != "today" {
+		t.Errorf("got %+v, %v, want the most recent old-1", log, err)
+	}
+}
+
+func TestMigrateEmptyLegacyTable(t *testing.T) {
+	s := openTestPostgres(t)
+	createLegacyTable(t, s)
+	if err := s.migrateSchema(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkPartitioning(true); err != nil {
+		t.Fatal(err)
+	}
+	if got := partitionNames(t, s); len(got) != 0 {
+		t.Errorf("got partitions %v", got)
+	}
+	var legacy *string
+	if err := s.db.Get(&legacy, `SELECT to_regclass('logs_legacy')::text`); err != nil || legacy != nil {
+		t.Errorf("logs_legacy was kept: %v, %v", legacy, err)
+	}
+}
+
+func TestDropPartitionsBefore(t *testing.T) {
+	s := openTestPostgres(t)
+	today := s.interval.Truncate(time.Now())
+	createLegacyTable(t, s, partitionLog("old", today.Add(-48*time.Hour), "old"))
+	if err := s.migrateSchema(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkPartitioning(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnsurePartitions(time.Now(), 1); err != nil {
+		t.Fatal(err)
+	}
+	current := partitionLog("current", today.Add(time.Hour), "current")
+	if err := s.StoreLogs([]models.LogEntry{current}); err != nil {
+		t.Fatal(err)
+	}
+
+	// partitions ending after the cutoff are kept, even when they start
+	// before it
+	dropped, err := s.DropPartitionsBefore(today.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(dropped, []string{"logs_legacy"}) {
+		t.Errorf("dropped %v", dropped)
+	}
+	want := []string{s.interval.name(today), s.interval.name(s.interval.Next(today))}
+	if got := partitionNames(t, s); !slices.Equal(got, want) {
+		t.Errorf("got partitions %v, want %v", got, want)
+	}
+	logs, err := s.QueryLogs(models.LogQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].ID != "current" {
+		t.Errorf("got %+v", logs)
+	}
+}
+
+func TestPartitionManager(t *testing.T) {
+	s := openTestPostgres(t)
+	if err := s.migrateSchema(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkPartitioning(false); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewPartitionManager(s, &config.DatabaseConfig{PartitionPremake: 2})
+	go m.Start()
+	// the first pass runs before Start checks for Stop
+	m.Stop()
+
+	got := partitionNames(t, s)
+	start := s.interval.Truncate(time.Now())
+	var want []string
+	for i := 0; i < 3; i++ {
+		want = append(want, s.interval.name(start))
+		start = s.interval.Next(start)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got partitions %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// DefaultPatternLimit is the number of patterns listed when a pattern query
+// does not ask for a limit.
+const DefaultPatternLimit = 20
+
+// PatternStore is implemented by stores that keep the message templates
+// mined by the log processor.
+type PatternStore interface {
+	// LoadPatterns returns every saved pattern.
+	LoadPatterns() ([]models.Pattern, error)
+	// SavePatterns adds or updates patterns by ID, widening the first and
+	// last seen times of those already saved.
+	SavePatterns(patterns []models.Pattern) error
+	// TopPatterns lists the patterns covering the most logs matching
+	// query.Query, most common first.
+	TopPatterns(query models.PatternQuery) ([]models.PatternCount, error)
+}
+
+func validatePatternQuery(query *models.PatternQuery) error {
+	if err := ValidateQuery(&query.Query); err != nil {
+		return err
+	}
+	if query.Limit < 0 || query.Examples < 0 {
+		return fmt.Errorf("%w: limit and examples must not be negative", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// patternSQL renders the top patterns of query, selecting the columns of
+// log_patterns followed by the count.
+func patternSQL(query models.PatternQuery, where func(models.LogQuery, *sqlArgs) (string, error), args *sqlArgs) (string, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = DefaultPatternLimit
+	}
+	filter, err := where(query.Query, args)
+	if err != nil {
+		return "", err
+	}
+	counts := "SELECT template_id, COUNT(*) AS n FROM logs" +
+		andWhere(filter, "template_id IS NOT NULL") +
+		" GROUP BY template_id ORDER BY n DESC, template_id LIMIT " + args.add(limit)
+	return "SELECT p.id, p.service, p.template, p.first_seen, p.last_seen, c.n FROM (" + counts + ") c" +
+		" JOIN log_patterns p ON p.id = c.template_id ORDER BY c.n DESC, p.id", nil
+}
+
+// addExamples fills in the newest logs of each pattern matching query.Query.
+func addExamples(store LogStore, query models.PatternQuery, patterns []models.PatternCount) error {
+	for i := range patterns {
+		patterns[i].Examples = []models.LogEntry{}
+		if query.Examples == 0 {
+			continue
+		}
+
+		examples := query.Query
+		examples.Filters = append(examples.Filters[:len(examples.Filters):len(examples.Filters)],
+			models.FieldFilter{Field: "template_id", Op: models.FilterEquals, Value: patterns[i].ID})
+		examples.Sort, examples.Order = "", ""
+		examples.Limit, examples.Offset, examples.Cursor = query.Examples, 0, nil
+
+		logs, err := store.QueryLogs(examples)
+		if err != nil {
+			return err
+		}
+		if logs != nil {
+			patterns[i].Examples = logs
+		}
+	}
+	return nil
+}
@@ -1,24 +1,151 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/krishnaGauss/SoCode/internal/config"
 	"github.com/krishnaGauss/SoCode/internal/models"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+const insertChunkSize = 1000
+
+var logColumns = []string{"id", "timestamp", "level", "message", "source", "service", "host", "tags", "metadata", "template_id", "fingerprint", "trace_id", "span_id", "parent_span_id"}
+
+// logRecord is the database representation of a models.LogEntry, with the
+// JSONB columns already encoded.
+type logRecord struct {
+	ID        string    `db:"id"`
+	Timestamp time.Time `db:"timestamp"`
+	Level     string    `db:"level"`
+	Message   string    `db:"message"`
+	Source    string    `db:"source"`
+	Service   string    `db:"service"`
+	Host      string    `db:"host"`
+	Tags      *string   `db:"tags"`
+	Metadata  *string   `db:"metadata"`
+	// the optional columns are NULL when unset
+	TemplateID   *string `db:"template_id"`
+	Fingerprint  *string `db:"fingerprint"`
+	TraceID      *string `db:"trace_id"`
+	SpanID       *string `db:"span_id"`
+	ParentSpanID *string `db:"parent_span_id"`
+}
+
+func toLogRecords(logs []models.LogEntry) ([]logRecord, error) {
+	records := make([]logRecord, 0, len(logs))
+	for _, log := range logs {
+		record := logRecord{
+			ID:        log.ID,
+			Timestamp: log.Timestamp,
+			Level:     string(log.Level),
+			Message:   log.Message,
+			Source:    log.Source,
+			Service:   log.Service,
+			Host:      log.Host,
+		}
+		record.TemplateID = nullString(log.TemplateID)
+		record.Fingerprint = nullString(log.Fingerprint)
+		record.TraceID = nullString(log.TraceID)
+		record.SpanID = nullString(log.SpanID)
+		record.ParentSpanID = nullString(log.ParentSpanID)
+
+		if len(log.Tags) > 0 {
+			tags, err := json.Marshal(log.Tags)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode tags for log %s: %w", log.ID, err)
+			}
+			tagsStr := string(tags)
+			record.Tags = &tagsStr
+		}
+
+		if len(log.Metadata) > 0 {
+			metadata := string(log.Metadata)
+			record.Metadata = &metadata
+		}
+
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// optionalColumns are the nullable text columns selected after metadata.
+const optionalColumns = "template_id, fingerprint, trace_id, span_id, parent_span_id"
+
+// optionalFields scans optionalColumns into a models.LogEntry.
+type optionalFields struct {
+	templateID, fingerprint, traceID, spanID, parentSpanID sql.NullString
+}
+
+func (o *optionalFields) dest() []interface{} {
+	return []interface{}{&o.templateID, &o.fingerprint, &o.traceID, &o.spanID, &o.parentSpanID}
+}
+
+func (o *optionalFields) set(log *models.LogEntry) {
+	log.TemplateID = o.templateID.String
+	log.Fingerprint = o.fingerprint.String
+	log.TraceID = o.traceID.String
+	log.SpanID = o.spanID.String
+	log.ParentSpanID = o.parentSpanID.String
+}
+
+// PostgresStorage keeps logs in a table range partitioned by timestamp.
+//
+// Logs are keyed by (id, timestamp), as the primary key of a partitioned
+// table must include the partition key, so an ID is only unique together
+// with its timestamp. A log the queue delivers again keeps its timestamp and
+// is skipped by ON CONFLICT DO NOTHING, but two logs sent with the same ID
+// and different timestamps are both stored.
 type PostgresStorage struct {
-	db *sqlx.DB
+	db          *sqlx.DB
+	interval    PartitionInterval
+	partitioned bool
 }
 
 func NewPostgresStorage(cfg *config.DatabaseConfig) (*PostgresStorage, error) {
+	db, err := OpenPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := ParsePartitionInterval(cfg.PartitionInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &PostgresStorage{db: db, interval: interval}
+
+	if err := storage.migrateSchema(cfg.AutoMigrate); err != nil {
+		return nil, err
+	}
+
+	if err := storage.checkPartitioning(cfg.MigratePartitions); err != nil {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// OpenPostgres connects to the database described by cfg without touching
+// the schema.
+func OpenPostgres(cfg *config.DatabaseConfig) (*sqlx.DB, error) {
 	// fmt.Println("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", 
 	// 	cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.Database, cfg.SSLMode)
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", 
@@ -36,126 +163,195 @@ func NewPostgresStorage(cfg *config.DatabaseConfig) (*PostgresStorage, error) {
 		return nil, err
 	}
 
-	storage := &PostgresStorage{db: db}
-
-	if err := storage.createTables(); err != nil {
-		slog.Info("couldn't create table")
-		return nil, err
-	}
+	return db, nil
+}
 
-	return storage, nil
+func (s *PostgresStorage) StoreLogs(logs []models.LogEntry) error {
+	_, err := s.insertLogs(logs)
+	return err
 }
 
-func (s *PostgresStorage) createTables() error {
-	//query here
-	query := `
-		CREATE TABLE IF NOT EXISTS logs(
-			id VARCHAR(255) PRIMARY KEY,
-			timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
-			level VARCHAR(20) NOT NULL,
-			message TEXT NOT NULL,
-			source VARCHAR(255) NOT NULL,
-			service VARCHAR(255) NOT NULL,
-			host VARCHAR(255) NOT NULL,
-        	tags JSONB,
-        	metadata JSONB,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
-		CREATE INDEX IF NOT EXISTS idx_logs_level ON logs(level);
-    	CREATE INDEX IF NOT EXISTS idx_logs_source ON logs(source);
-    	CREATE INDEX IF NOT EXISTS idx_logs_service ON logs(service);
-    	CREATE INDEX IF NOT EXISTS idx_logs_host ON logs(host);
-    	CREATE INDEX IF NOT EXISTS idx_logs_tags ON logs USING GIN(tags);
-    	CREATE INDEX IF NOT EXISTS idx_logs_message ON logs USING GIN(to_tsvector('english', message));
-		`
-
-	_, err := s.db.Exec(query)
+func (s *PostgresStorage) StoreLogsCopy(logs []models.LogEntry) error {
+	_, err := s.copyLogs(logs)
 	return err
 }
 
-func (s *PostgresStorage) StoreLogs(logs []models.LogEntry) error {
+func (s *PostgresStorage) InsertLogs(logs []models.LogEntry, bulk bool) ([]models.LogEntry, error) {
+	insert := s.insertLogs
+	if bulk {
+		insert = s.copyLogs
+	}
+	ids, err := insert(logs)
+	if err != nil {
+		return nil, err
+	}
+	return insertedLogs(logs, ids), nil
+}
+
+// insertLogs stores logs with multi-row inserts and returns the IDs of the
+// ones that were not stored before.
+func (s *PostgresStorage) insertLogs(logs []models.LogEntry) ([]string, error) {
 	if len(logs) == 0 {
 		slog.Debug("log length is zero")
-		return nil
+		return nil, nil
 	}
 
 	query := `
-		INSERT INTO logs(id, timestamp, level, message, source, service, host, tags, metadata) 
-		VALUES (:id, :timestamp, :level, :message, :source, :service, :host, :tags, :metadata)
-		ON CONFLICT (id) DO NOTHING;
+		INSERT INTO logs(id, timestamp, level, message, source, service, host, tags, metadata, template_id, fingerprint, trace_id, span_id, parent_span_id) 
+		VALUES (:id, :timestamp, :level, :message, :source, :service, :host, :tags, :metadata, :template_id, :fingerprint, :trace_id, :span_id, :parent_span_id)
+		ON CONFLICT DO NOTHING
+		RETURNING id;
 	`
 
-	_, err := s.db.NamedExec(query, logs)
-	return err
+	records, err := toLogRecords(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the chunks share a transaction so a failed batch can be retried
+	// without its first chunks counting as already stored
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// a multi-row insert binds 14 parameters per row, so large batches are
+	// split to stay under the 65535 parameter limit of the wire protocol
+	var ids []string
+	for start := 0; start < len(records); start += insertChunkSize {
+		end := min(start+insertChunkSize, len(records))
+		rows, err := tx.NamedQuery(query, records[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if ids, err = appendIDs(ids, rows.Rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, tx.Commit()
 }
 
-func (s *PostgresStorage) QueryLogs(query models.LogQuery) ([]models.LogEntry, error) {
-	var conditions []string
-	var args []interface{}
-	argCount := 0
+// copyLogs streams the batch into a transaction-scoped staging table with
+// COPY FROM STDIN and merges it into logs, skipping logs that already exist,
+// and returns the IDs of the ones it inserted. It is considerably faster than
+// insertLogs for large batches.
+func (s *PostgresStorage) copyLogs(logs []models.LogEntry) ([]string, error) {
+	if len(logs) == 0 {
+		slog.Debug("log length is zero")
+		return nil, nil
+	}
 
-	baseQuery := "SELECT id, timestamp, level, message, source, service, host, tags, metadata FROM logs"
+	records, err := toLogRecords(logs)
+	if err != nil {
+		return nil, err
+	}
 
-	if query.StartTime != nil {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", argCount))
-		args = append(args, *query.StartTime)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	if query.EndTime != nil {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("timestamp <= $%d", argCount))
-		args = append(args, *query.EndTime)
+	if _, err := tx.Exec(`CREATE TEMP TABLE logs_staging (LIKE logs INCLUDING DEFAULTS) ON COMMIT DROP`); err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
 	}
 
-	if len(query.Level) > 0 {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("level = ANY($%d)", argCount))
-		levels := make([]string, len(query.Level))
-		for i, level := range query.Level {
-			levels[i] = string(level)
+	stmt, err := tx.Prepare(pq.CopyIn("logs_staging", logColumns...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start copy: %w", err)
+	}
+
+	for _, r := range records {
+		if _, err := stmt.Exec(r.ID, r.Timestamp, r.Level, r.Message, r.Source, r.Service, r.Host, r.Tags, r.Metadata, r.TemplateID, r.Fingerprint, r.TraceID, r.SpanID, r.ParentSpanID); err != nil {
+			stmt.Close()
+			return nil, fmt.Errorf("failed to copy log %s: %w", r.ID, err)
 		}
+	}
 
-		args = append(args, levels)
+	// an empty Exec flushes the buffered rows to the server
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return nil, fmt.Errorf("failed to flush copy: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, err
 	}
 
-	if len(query.Source) > 0 {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("source = ANY($%d)", argCount))
-		args = append(args, query.Source)
+	columns := strings.Join(logColumns, ", ")
+	merge := fmt.Sprintf(`INSERT INTO logs(%s) SELECT %s FROM logs_staging ON CONFLICT DO NOTHING RETURNING id`, columns, columns)
+	rows, err := tx.Query(merge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge staging table: %w", err)
+	}
+	ids, err := appendIDs(nil, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge staging table: %w", err)
 	}
 
-	if query.Search != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("message ILIKE $%d", argCount))
-		args = append(args, "%"+query.Search+"%")
+	return ids, tx.Commit()
+}
+
+// appendIDs appends the IDs read from rows and closes them.
+func appendIDs(ids []string, rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
+	return ids, rows.Err()
+}
 
-	if len(conditions) > 0 {
-		baseQuery += "WHERE" + strings.Join(conditions, " AND ")
+func (s *PostgresStorage) QueryLogs(query models.LogQuery) ([]models.LogEntry, error) {
+	return s.QueryLogsContext(context.Background(), query)
+}
+
+func (s *PostgresStorage) QueryLogsContext(ctx context.Context, query models.LogQuery) ([]models.LogEntry, error) {
+	if err := ValidateQuery(&query); err != nil {
+		return nil, err
 	}
 
-	baseQuery += "ORDER BY timestamp DESC" //remember to put ;
+	args := &sqlArgs{}
+	ranked := rankedSearch(query)
+	baseQuery := "SELECT id, timestamp, level, message, source, service, host, tags, metadata, " + optionalColumns
+	if ranked {
+		tsQuery := "websearch_to_tsquery('english', " + args.add(query.Search) + ")"
+		baseQuery += fmt.Sprintf(", ts_rank(to_tsvector('english', message), %[1]s) AS search_rank, ts_headline('english', message, %[1]s, %[2]s)",
+			tsQuery, args.add(headlineOptions))
+	}
+	baseQuery += " FROM logs"
+	where, err := whereClause(query, args)
+	if err != nil {
+		return nil, err
+	}
+	direction, compare := keyset(query)
+	if query.Cursor != nil {
+		where = andWhere(where, fmt.Sprintf("(timestamp, id) %s (%s, %s)", compare, args.add(query.Cursor.Timestamp), args.add(query.Cursor.ID)))
+	}
+	baseQuery += where
+	if query.Sort == models.SortRelevance {
+		baseQuery += " ORDER BY search_rank DESC, timestamp DESC, id DESC"
+	} else {
+		baseQuery += fmt.Sprintf(" ORDER BY timestamp %[1]s, id %[1]s", direction)
+	}
 
 	if query.Limit > 0 {
-		argCount++
-		baseQuery += fmt.Sprintf(" LIMIT $%d", argCount)
-		args = append(args, query.Limit)
+		baseQuery += " LIMIT " + args.add(query.Limit)
 	}
 
 	if query.Offset > 0 {
-		argCount++
-		baseQuery += fmt.Sprintf(" OFFSET $%d", argCount)
-		args = append(args, query.Offset)
+		baseQuery += " OFFSET " + args.add(query.Offset)
 	}
 
-	rows, err := s.db.Query(baseQuery, args...) //using ... to help in using argument as an interface slice individually
+	rows, err := s.db.QueryContext(ctx, baseQuery, args.args...) //using ... to help in using argument as an interface slice individually
 
 	if err != nil {
 		slog.Debug("cannot execute query in postgres")
-		return nil, err
+		return nil, queryError(err)
 	}
 
 	defer rows.Close()
@@ -165,10 +361,17 @@ func (s *PostgresStorage) QueryLogs(query models.LogQuery) ([]models.LogEntry, e
 		var log models.LogEntry
 		var tagsJSON, metadataJSON sql.NullString
 
-		err := rows.Scan(
+		dest := []interface{}{
 			&log.ID, &log.Timestamp, &log.Level, &log.Message,
 			&log.Source, &log.Service, &log.Host, &tagsJSON, &metadataJSON,
-		)
+		}
+		var optional optionalFields
+		dest = append(dest, optional.dest()...)
+		if ranked {
+			dest = append(dest, &log.Rank, &log.Highlight)
+		}
+
+		err := rows.Scan(dest...)
 
 		if err != nil {
 			return nil, err
@@ -180,14 +383,367 @@ func (s *PostgresStorage) QueryLogs(query models.LogQuery) ([]models.LogEntry, e
         if metadataJSON.Valid {
             log.Metadata = json.RawMessage(metadataJSON.String)
         }
+		optional.set(&log)
 
-        logs = append(logs, log)
+		logs = append(logs, log)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, queryError(err)
+	}
+
+	if query.Cursor != nil && query.Cursor.Before {
+		slices.Reverse(logs)
+	}
 	return logs, nil
 
 }
 
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2", highlightStart, highlightStop)
+
+// queryError marks errors caused by the query, such as an invalid regular
+// expression, as ErrInvalidQuery.
+func queryError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "2201B" { // invalid_regular_expression
+		return fmt.Errorf("%w: %s", ErrInvalidQuery, pqErr.Message)
+	}
+	return err
+}
+
+func (s *PostgresStorage) CountLogs(query models.LogQuery, limit int) (models.Total, error) {
+	if err := ValidateQuery(&query); err != nil {
+		return models.Total{}, err
+	}
+
+	args := &sqlArgs{}
+	where, err := whereClause(query, args)
+	if err != nil {
+		return models.Total{}, err
+	}
+
+	if limit <= 0 {
+		var count int64
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM logs"+where, args.args...).Scan(&count); err != nil {
+			return models.Total{}, queryError(err)
+		}
+		return models.Total{Value: count, Relation: models.TotalExact}, nil
+	}
+
+	// counting at most limit + 1 rows tells an exact total from a larger one
+	// while bounding the work
+	var count int64
+	bounded := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM logs%s LIMIT %d) bounded", where, limit+1)
+	if err := s.db.QueryRow(bounded, args.args...).Scan(&count); err != nil {
+		return models.Total{}, queryError(err)
+	}
+	if count <= int64(limit) {
+		return models.Total{Value: count, Relation: models.TotalExact}, nil
+	}
+
+	estimate, err := s.estimateRows("SELECT 1 FROM logs"+where, args.args)
+	if err != nil {
+		return models.Total{}, queryError(err)
+	}
+	if estimate > int64(limit) {
+		return models.Total{Value: estimate, Relation: models.TotalEstimate}, nil
+	}
+	return models.Total{Value: int64(limit), Relation: models.TotalAtLeast}, nil
+}
+
+// estimateRows returns the planner's estimate of the rows query returns.
+func (s *PostgresStorage) estimateRows(query string, args []interface{}) (int64, error) {
+	var plan []byte
+	if err := s.db.QueryRow("EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return 0, err
+	}
+
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explained); err != nil || len(explained) == 0 {
+		return 0, fmt.Errorf("unexpected plan: %s", plan)
+	}
+	return int64(explained[0].Plan.Rows), nil
+}
+
+// AggregateLogs counts the logs matching query.Query per time bucket and
+// group.
+func (s *PostgresStorage) AggregateLogs(query models.AggregateQuery) ([]models.AggregateBucket, error) {
+	if err := validateAggregate(&query); err != nil {
+		return nil, err
+	}
+
+	args := &sqlArgs{}
+	statement, err := aggregateSQL(query, postgresAggregate, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(statement, args.args...)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	defer rows.Close()
+
+	return scanAggregate(rows, query, postgresAggregate)
+}
+
+var postgresAggregate = aggregateDialect{
+	bucket: func(interval time.Duration) string {
+		if interval == 0 {
+			return "NULL::timestamptz"
+		}
+		seconds := int64(interval / time.Second)
+		return fmt.Sprintf("to_timestamp(floor(extract(epoch FROM timestamp) / %[1]d) * %[1]d)", seconds)
+	},
+	tag: func(key string, args *sqlArgs) string {
+		return "tags->>" + args.add(key) + "::text"
+	},
+	where:       whereClause,
+	notDistinct: "IS NOT DISTINCT FROM",
+	scanBucket: func(src interface{}) (*time.Time, error) {
+		if src == nil {
+			return nil, nil
+		}
+		t, ok := src.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("unexpected bucket %v", src)
+		}
+		t = t.UTC()
+		return &t, nil
+	},
+}
+
+// FacetLogs lists the most common values of a field among the logs matching
+// query.Query.
+func (s *PostgresStorage) FacetLogs(query models.FacetQuery) (models.Facet, error) {
+	if err := validateFacet(&query); err != nil {
+		return models.Facet{}, err
+	}
+
+	limit := facetLimit(query)
+	args := &sqlArgs{}
+	statement, err := facetSQL(query, limit, postgresFacet, args)
+	if err != nil {
+		return models.Facet{}, err
+	}
+	rows, err := s.db.Query(statement, args.args...)
+	if err != nil {
+		return models.Facet{}, queryError(err)
+	}
+	defer rows.Close()
+
+	return scanFacet(rows, query, limit)
+}
+
+var postgresFacet = facetDialect{
+	tag: postgresAggregate.tag,
+	tagKeys: func(where string) string {
+		return "SELECT jsonb_object_keys(tags) AS v FROM logs" + where
+	},
+	where: whereClause,
+	prefix: func(prefix string, args *sqlArgs) string {
+		return "v ILIKE " + args.add(escapeLike(prefix)+"%")
+	},
+}
+
+func (s *PostgresStorage) LoadPatterns() ([]models.Pattern, error) {
+	var patterns []models.Pattern
+	err := s.db.Select(&patterns, `SELECT id, service, template, first_seen, last_seen FROM log_patterns`)
+	return patterns, err
+}
+
+func (s *PostgresStorage) SavePatterns(patterns []models.Pattern) error {
+	query := `
+		INSERT INTO log_patterns(id, service, template, first_seen, last_seen)
+		VALUES (:id, :service, :template, :first_seen, :last_seen)
+		ON CONFLICT (id) DO UPDATE SET
+			template = EXCLUDED.template,
+			first_seen = LEAST(log_patterns.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(log_patterns.last_seen, EXCLUDED.last_seen);
+	`
+	for start := 0; start < len(patterns); start += insertChunkSize {
+		end := min(start+insertChunkSize, len(patterns))
+		if _, err := s.db.NamedExec(query, patterns[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) TopPatterns(query models.PatternQuery) ([]models.PatternCount, error) {
+	if err := validatePatternQuery(&query); err != nil {
+		return nil, err
+	}
+
+	args := &sqlArgs{}
+	statement, err := patternSQL(query, whereClause, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(statement, args.args...)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	defer rows.Close()
+
+	patterns := []models.PatternCount{}
+	for rows.Next() {
+		var p models.PatternCount
+		if err := rows.Scan(&p.ID, &p.Service, &p.Template, &p.FirstSeen, &p.LastSeen, &p.Count); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(err)
+	}
+
+	return patterns, addExamples(s, query, patterns)
+}
+
+var postgresIssues = issueDialect{
+	time:     func(t time.Time) interface{} { return t },
+	lock:     " FOR UPDATE",
+	least:    "LEAST",
+	greatest: "GREATEST",
+}
+
+func (s *PostgresStorage) RecordIssues(occurrences []models.Issue) ([]models.Issue, error) {
+	return recordIssues(s.db, postgresIssues, occurrences)
+}
+
+func (s *PostgresStorage) ListIssues(query models.IssueQuery) ([]models.Issue, error) {
+	return listIssues(s.db, postgresIssues, query)
+}
+
+func (s *PostgresStorage) GetIssue(id string) (models.Issue, error) {
+	return getIssue(s.db, postgresIssues, id, false)
+}
+
+func (s *PostgresStorage) SetIssueStatus(id string, status models.IssueStatus) (models.Issue, error) {
+	return setIssueStatus(s.db, postgresIssues, id, status)
+}
+
+// DeleteLogs removes every log matching the filters of query. Limit and
+// Offset are ignored, and a query without any filter is rejected rather than
+// emptying the table.
+func (s *PostgresStorage) DeleteLogs(query models.LogQuery) (int64, error) {
+	if err := ValidateQuery(&query); err != nil {
+		return 0, err
+	}
+
+	args := &sqlArgs{}
+	where, err := whereClause(query, args)
+	if err != nil {
+		return 0, err
+	}
+	if where == "" {
+		return 0, fmt.Errorf("%w: refusing to delete without a filter", ErrInvalidQuery)
+	}
+
+	result, err := s.db.Exec("DELETE FROM logs"+where, args.args...)
+	if err != nil {
+		return 0, queryError(err)
+	}
+	return result.RowsAffected()
+}
+
+// sqlArgs collects query arguments, handing out numbered ($1) placeholders
+// or, for SQLite, positional (?) ones.
+type sqlArgs struct {
+	args       []interface{}
+	positional bool
+}
+
+// add appends an argument and returns its placeholder.
+func (a *sqlArgs) add(v interface{}) string {
+	a.args = append(a.args, v)
+	if a.positional {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", len(a.args))
+}
+
+// addList appends every value and returns a parenthesized placeholder list.
+func (a *sqlArgs) addList(values []string) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = a.add(v)
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
+
+// whereClause renders the filters of query, including the leading " WHERE",
+// or returns an empty string when nothing is filtered. It fails when the
+// expression of query does not parse.
+func whereClause(query models.LogQuery, args *sqlArgs) (string, error) {
+	var conditions []string
+
+	if query.StartTime != nil {
+		conditions = append(conditions, "timestamp >= "+args.add(*query.StartTime))
+	}
+
+	if query.EndTime != nil {
+		conditions = append(conditions, "timestamp <= "+args.add(*query.EndTime))
+	}
+
+	if len(query.Level) > 0 {
+		levels := make([]string, len(query.Level))
+		for i, level := range query.Level {
+			levels[i] = string(level)
+		}
+		conditions = append(conditions, fmt.Sprintf("level = ANY(%s)", args.add(pq.Array(levels))))
+	}
+
+	if len(query.Source) > 0 {
+		conditions = append(conditions, fmt.Sprintf("source = ANY(%s)", args.add(pq.Array(query.Source))))
+	}
+
+	if len(query.Service) > 0 {
+		conditions = append(conditions, fmt.Sprintf("service = ANY(%s)", args.add(pq.Array(query.Service))))
+	}
+
+	if len(query.Host) > 0 {
+		conditions = append(conditions, fmt.Sprintf("host = ANY(%s)", args.add(pq.Array(query.Host))))
+	}
+
+	if len(query.Tags) > 0 {
+		conditions = append(conditions, fmt.Sprintf("tags @> %s::jsonb", args.add(tagsJSON(query.Tags))))
+	}
+
+	for _, f := range query.Filters {
+		conditions = append(conditions, filterCondition(f, args))
+	}
+
+	node, err := exprNode(query)
+	if err != nil {
+		return "", err
+	}
+	if node != nil {
+		conditions = append(conditions, exprCondition(node, postgresExpr, args))
+	}
+
+	if query.Search != "" {
+		switch query.SearchMode {
+		case models.SearchFullText:
+			// matches the expression of idx_logs_message so the index is used
+			conditions = append(conditions, "to_tsvector('english', message) @@ websearch_to_tsquery('english', "+args.add(query.Search)+")")
+		case models.SearchRegex:
+			conditions = append(conditions, "message ~ "+args.add(query.Search))
+		default:
+			conditions = append(conditions, "message ILIKE "+args.add("%"+escapeLike(query.Search)+"%"))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), nil
+}
+
 
 func (s *PostgresStorage) Close() error{
 	return s.db.Close()
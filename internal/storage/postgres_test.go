@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+// benchmarkBatch is the number of logs written per iteration.
+const benchmarkBatch = 5000
+
+// openBenchmarkPostgres connects to the database in DATABASE_URL, a
+// postgres:// URL, and skips the benchmark when it is not set.
+func openBenchmarkPostgres(b *testing.B) *PostgresStorage {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		b.Skip("DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", url)
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := &PostgresStorage{db: db, interval: PartitionDaily}
+	if err := s.migrateSchema(true); err != nil {
+		b.Fatal(err)
+	}
+	if err := s.checkPartitioning(false); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		s.db.Exec(`DELETE FROM logs WHERE service = 'benchmark'`)
+		s.Close()
+	})
+	return s
+}
+
+func benchmarkLogs(run, batch int) []models.LogEntry {
+	now := time.Now().UTC()
+	logs := make([]models.LogEntry, batch)
+	for i := range logs {
+		logs[i] = models.LogEntry{
+			ID:        fmt.Sprintf("bench-%d-%d-%d", now.UnixNano(), run, i),
+			Timestamp: now,
+			Level:     models.INFO,
+			Message:   "GET /api/orders/42 200 13ms",
+			Source:    "access.log",
+			Service:   "benchmark",
+			Host:      "bench-1",
+			Tags:      map[string]string{"env": "bench"},
+			Metadata:  []byte(`{"status":200,"latency_ms":13}`),
+		}
+	}
+	return logs
+}
+
+func benchmarkStore(b *testing.B, store func(*PostgresStorage, []models.LogEntry) error) {
+	s := openBenchmarkPostgres(b)
+	batches := make([][]models.LogEntry, b.N)
+	for i := range batches {
+		batches[i] = benchmarkLogs(i, benchmarkBatch)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store(s, batches[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*benchmarkBatch)/b.Elapsed().Seconds(), "logs/s")
+}
+
+func BenchmarkStoreLogs(b *testing.B) {
+	benchmarkStore(b, (*PostgresStorage).StoreLogs)
+}
+
+func BenchmarkStoreLogsCopy(b *testing.B) {
+	benchmarkStore(b, (*PostgresStorage).StoreLogsCopy)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+var ErrQueueFull = errors.New("queue is full")
+
+// Queue buffers logs between ingestion and the processor. Dequeued logs stay
+// in flight until they are acknowledged, so a consumer that dies mid-batch
+// does not lose them.
+type Queue interface {
+	Enqueue(log models.LogEntry) error
+	Dequeue(count int64) ([]models.LogEntry, error)
+	Ack(logs []models.LogEntry) error
+	// Len reports the number of logs waiting to be dequeued.
+	Len() (int64, error)
+	Close() error
+}
+
+// Lane is a class of log levels that is queued separately, so a flood of low
+// severity logs cannot delay high severity ones.
+type Lane string
+
+const (
+	LaneCritical Lane = "critical" // ERROR and FATAL
+	LaneDefault  Lane = "default"  // WARN, INFO and unknown levels
+	LaneDebug    Lane = "debug"    // DEBUG
+)
+
+// Lanes lists every lane from highest to lowest priority.
+var Lanes = []Lane{LaneCritical, LaneDefault, LaneDebug}
+
+func LaneFor(level models.LogLevel) Lane {
+	switch models.LogLevel(strings.ToUpper(string(level))) {
+	case models.ERROR, models.FATAL:
+		return LaneCritical
+	case models.DEBUG:
+		return LaneDebug
+	}
+	return LaneDefault
+}
+
+// LaneQueue is implemented by queues that keep a FIFO per lane. Dequeue on a
+// LaneQueue drains lanes in strict priority order; consumers that want to
+// share capacity between lanes use DequeueLane.
+type LaneQueue interface {
+	Queue
+	DequeueLane(lane Lane, count int64) ([]models.LogEntry, error)
+	LaneLen() (map[Lane]int64, error)
+}
+
+// NewQueue builds the queue backend selected by cfg.Backend.
+func NewQueue(cfg *config.QueueConfig, redisCfg *config.RedisConfig) (Queue, error) {
+	switch cfg.Backend {
+	case "", "redis":
+		return NewRedisQueue(redisCfg, cfg.VisibilityTimeout)
+	case "memory":
+		return NewMemoryQueue(cfg.Capacity, cfg.VisibilityTimeout), nil
+	case "disk":
+		return NewDiskQueue(cfg.Dir, cfg.Capacity, cfg.SegmentSize, cfg.VisibilityTimeout)
+	}
+	return nil, fmt.Errorf("unknown queue backend %q", cfg.Backend)
+}
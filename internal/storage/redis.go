@@ -3,70 +3,192 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/krishnaGauss/SoCode/internal/config"
 	"github.com/krishnaGauss/SoCode/internal/models"
 	"github.com/redis/go-redis/v9"
 )
 
+// laneKeys maps each lane to its list key. The default lane keeps the key
+// used before lanes existed so an existing backlog is still drained. Every
+// lane has its own "<key>:inflight" hash and "<key>:deadlines" sorted set.
+var laneKeys = map[Lane]string{
+	LaneCritical: "log_queue:critical",
+	LaneDefault:  "log_queue",
+	LaneDebug:    "log_queue:debug",
+}
+
+// dequeueScript first returns in-flight logs of a lane whose visibility
+// deadline passed to the head of that lane, then pops up to ARGV[1] logs and
+// records them as in flight until ARGV[3].
+var dequeueScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[2])
+for _, id in ipairs(expired) do
+	local data = redis.call('HGET', KEYS[2], id)
+	if data then
+		redis.call('RPUSH', KEYS[1], data)
+	end
+	redis.call('HDEL', KEYS[2], id)
+	redis.call('ZREM', KEYS[3], id)
+end
+
+local items = redis.call('RPOP', KEYS[1], ARGV[1])
+if not items then
+	return {}
+end
+for _, data in ipairs(items) do
+	local ok, entry = pcall(cjson.decode, data)
+	if ok and type(entry) == 'table' and type(entry.id) == 'string' then
+		redis.call('HSET', KEYS[2], entry.id, data)
+		redis.call('ZADD', KEYS[3], ARGV[3], entry.id)
+	end
+end
+return items
+`)
+
 type RedisQueue struct {
-	client *redis.Client
-	ctx context.Context
+	client            *redis.Client
+	ctx               context.Context
+	visibilityTimeout time.Duration
 }
 
-func NewRedisQueue(cfg *config.RedisConfig) (*RedisQueue, error){
-	client:=redis.NewClient(&redis.Options{
+func NewRedisQueue(cfg *config.RedisConfig, visibilityTimeout time.Duration) (*RedisQueue, error) {
+	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-        Password: cfg.Password,
-        DB:       cfg.DB,
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 
 	ctx := context.Background()
-    _, err := client.Ping(ctx).Result()
-    if err != nil {
-        return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-    }
-
-    return &RedisQueue{
-        client: client,
-        ctx:    ctx,
-    }, nil
+	_, err := client.Ping(ctx).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &RedisQueue{
+		client:            client,
+		ctx:               ctx,
+		visibilityTimeout: visibilityTimeout,
+	}, nil
 }
 
-func (r *RedisQueue) EnqueueLog(log models.LogEntry) error{
-	data,err:=json.Marshal(log)
-	if err!=nil{
+func (r *RedisQueue) Enqueue(log models.LogEntry) error {
+	data, err := json.Marshal(log)
+	if err != nil {
 		slog.Debug("error in marshalling redis enqueue")
 		return err
 	}
 
-	return r.client.LPush(r.ctx, "log_queue", data).Err()
+	return r.client.LPush(r.ctx, laneKeys[LaneFor(log.Level)], data).Err()
 }
 
-func (r *RedisQueue) DequeueLogs(count int64) ([]models.LogEntry, error){
-	results, err := r.client.RPopCount(r.ctx, "log_queue", int(count)).Result()
-    if err != nil {
-        return nil, err
-    }
-
-    logs := make([]models.LogEntry, 0, len(results))
-    for _, result := range results {
-        var log models.LogEntry
-        if err := json.Unmarshal([]byte(result), &log); err != nil {
-            continue // Skip malformed logs
-        }
-        logs = append(logs, log)
-    }
-
-    return logs, nil
+// Dequeue pops up to count logs, taking from higher priority lanes first.
+func (r *RedisQueue) Dequeue(count int64) ([]models.LogEntry, error) {
+	var logs []models.LogEntry
+	for _, lane := range Lanes {
+		if int64(len(logs)) >= count {
+			break
+		}
+		laneLogs, err := r.DequeueLane(lane, count-int64(len(logs)))
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, laneLogs...)
+	}
+	return logs, nil
 }
 
-func (r *RedisQueue) QueueLength() (int64, error) {
-    return r.client.LLen(r.ctx, "log_queue").Result()
+// DequeueLane pops up to count logs from a single lane. They are redelivered
+// by a later dequeue if they are not acknowledged within the visibility
+// timeout.
+func (r *RedisQueue) DequeueLane(lane Lane, count int64) ([]models.LogEntry, error) {
+	key := laneKeys[lane]
+	now := time.Now()
+	results, err := dequeueScript.Run(r.ctx, r.client,
+		[]string{key, key + ":inflight", key + ":deadlines"},
+		count, now.UnixMilli(), now.Add(r.visibilityTimeout).UnixMilli(),
+	).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]models.LogEntry, 0, len(results))
+	for _, result := range results {
+		var log models.LogEntry
+		if err := json.Unmarshal([]byte(result), &log); err != nil {
+			continue // Skip malformed logs
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, nil
+}
+
+func (r *RedisQueue) Ack(logs []models.LogEntry) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	ids := make(map[Lane][]string)
+	for _, log := range logs {
+		lane := LaneFor(log.Level)
+		ids[lane] = append(ids[lane], log.ID)
+	}
+
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for lane, laneIDs := range ids {
+			key := laneKeys[lane]
+			members := make([]interface{}, len(laneIDs))
+			for i, id := range laneIDs {
+				members[i] = id
+			}
+			pipe.HDel(r.ctx, key+":inflight", laneIDs...)
+			pipe.ZRem(r.ctx, key+":deadlines", members...)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisQueue) Len() (int64, error) {
+	lens, err := r.LaneLen()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, n := range lens {
+		total += n
+	}
+	return total, nil
+}
+
+func (r *RedisQueue) LaneLen() (map[Lane]int64, error) {
+	cmds := make(map[Lane]*redis.IntCmd, len(Lanes))
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for _, lane := range Lanes {
+			cmds[lane] = pipe.LLen(r.ctx, laneKeys[lane])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lens := make(map[Lane]int64, len(Lanes))
+	for lane, cmd := range cmds {
+		lens[lane] = cmd.Val()
+	}
+	return lens, nil
 }
 
 func (r *RedisQueue) Close() error {
-    return r.client.Close()
-}
\ No newline at end of file
+	return r.client.Close()
+}
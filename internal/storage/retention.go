@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/lib/pq"
+)
+
+// RetentionStore is implemented by stores that can enforce retention
+// policies.
+type RetentionStore interface {
+	// DeleteExpired deletes at most limit logs in scope and returns how many
+	// were deleted.
+	DeleteExpired(scope models.RetentionScope, limit int) (int64, error)
+	CountExpired(scope models.RetentionScope) (int64, error)
+	// OldestExpired returns the timestamp of the oldest log in scope at or
+	// after since, and false when there is none.
+	OldestExpired(scope models.RetentionScope, since time.Time) (time.Time, bool, error)
+}
+
+// retentionWhereClause renders the logs selected by scope. before is the
+// cutoff in the representation the store keeps timestamps in.
+func retentionWhereClause(scope models.RetentionScope, before interface{}, args *sqlArgs) string {
+	conditions := []string{"timestamp < " + args.add(before)}
+	conditions = append(conditions, policyConditions(scope.Policy, args)...)
+
+	for _, other := range scope.Except {
+		conditions = append(conditions, "NOT ("+strings.Join(policyConditions(other, args), " AND ")+")")
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func policyConditions(policy models.RetentionPolicy, args *sqlArgs) []string {
+	var conditions []string
+	if policy.Service != "" {
+		conditions = append(conditions, "service = "+args.add(policy.Service))
+	}
+	if policy.Level != "" {
+		// agents do not agree on the case of levels
+		conditions = append(conditions, "UPPER(level) = "+args.add(strings.ToUpper(string(policy.Level))))
+	}
+	return conditions
+}
+
+// DeleteExpired deletes one chunk of expired logs. Deleting in chunks keeps
+// each transaction, and the locks and WAL it produces, small.
+func (s *PostgresStorage) DeleteExpired(scope models.RetentionScope, limit int) (int64, error) {
+	args := &sqlArgs{}
+	where := retentionWhereClause(scope, scope.Before, args)
+	query := "DELETE FROM logs WHERE (id, timestamp) IN (SELECT id, timestamp FROM logs" + where + " LIMIT " + args.add(limit) + ")"
+
+	result, err := s.db.Exec(query, args.args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *PostgresStorage) CountExpired(scope models.RetentionScope) (int64, error) {
+	args := &sqlArgs{}
+	query := "SELECT COUNT(*) FROM logs" + retentionWhereClause(scope, scope.Before, args)
+
+	var count int64
+	err := s.db.QueryRow(query, args.args...).Scan(&count)
+	return count, err
+}
+
+func (s *PostgresStorage) OldestExpired(scope models.RetentionScope, since time.Time) (time.Time, bool, error) {
+	args := &sqlArgs{}
+	query := "SELECT MIN(timestamp) FROM logs" + retentionWhereClause(scope, scope.Before, args) +
+		" AND timestamp >= " + args.add(since)
+
+	var oldest sql.NullTime
+	err := s.db.QueryRow(query, args.args...).Scan(&oldest)
+	return oldest.Time, oldest.Valid, err
+}
+
+// ExpiredPartitions lists the partitions that end at or before cutoff.
+func (s *PostgresStorage) ExpiredPartitions(cutoff time.Time) ([]Partition, error) {
+	if !s.partitioned {
+		return nil, nil
+	}
+
+	partitions, err := s.Partitions()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []Partition
+	for _, p := range partitions {
+		if !p.To.After(cutoff) {
+			expired = append(expired, p)
+		}
+	}
+	return expired, nil
+}
+
+// DropPartitionsBefore drops every partition that ends at or before cutoff
+// and returns their names.
+func (s *PostgresStorage) DropPartitionsBefore(cutoff time.Time) ([]string, error) {
+	expired, err := s.ExpiredPartitions(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, p := range expired {
+		if _, err := s.db.Exec("DROP TABLE IF EXISTS " + pq.QuoteIdentifier(p.Name)); err != nil {
+			return dropped, err
+		}
+		slog.Info("dropped expired partition", slog.String("partition", p.Name))
+		dropped = append(dropped, p.Name)
+	}
+	return dropped, nil
+}
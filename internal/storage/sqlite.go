@@ -0,0 +1,860 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStorage keeps logs in a single local database file, with an FTS5
+// index over messages. Timestamps are stored as Unix nanoseconds.
+//
+// It needs cgo and the sqlite_fts5 build tag:
+//
+//	go build -tags sqlite_fts5 ./...
+type SQLiteStorage struct {
+	db *sqlx.DB
+}
+
+// sqliteDriver is go-sqlite3 with a regexp function, which SQLite needs to
+// evaluate the REGEXP operator.
+const sqliteDriver = "sqlite3_socode"
+
+func init() {
+	var patterns sync.Map
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", func(pattern, s string) (bool, error) {
+				re, ok := patterns.Load(pattern)
+				if !ok {
+					compiled, err := regexp.Compile(pattern)
+					if err != nil {
+						return false, err
+					}
+					re, _ = patterns.LoadOrStore(pattern, compiled)
+				}
+				return re.(*regexp.Regexp).MatchString(s), nil
+			}, true)
+		},
+	})
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	// WAL lets the API server read while the log server writes
+	// LIKE is made case sensitive to match PostgreSQL
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_cslike=true", path)
+	db, err := sqlx.Connect(sqliteDriver, dsn)
+	if err != nil {
+		slog.Info("couldn't open sqlite database.")
+		return nil, err
+	}
+
+	storage := &SQLiteStorage{db: db}
+
+	if err := storage.createTables(); err != nil {
+		slog.Info("couldn't create table")
+		db.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+func (s *SQLiteStorage) createTables() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS logs(
+			id TEXT PRIMARY KEY,
+			timestamp INTEGER NOT NULL,
+			level TEXT NOT NULL,
+			message TEXT NOT NULL,
+			source TEXT NOT NULL,
+			service TEXT NOT NULL,
+			host TEXT NOT NULL,
+			tags TEXT,
+			metadata TEXT,
+			template_id TEXT,
+			fingerprint TEXT,
+			trace_id TEXT,
+			span_id TEXT,
+			parent_span_id TEXT,
+			created_at INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER))
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
+		CREATE INDEX IF NOT EXISTS idx_logs_timestamp_id ON logs(timestamp, id);
+		CREATE INDEX IF NOT EXISTS idx_logs_level ON logs(level);
+		CREATE INDEX IF NOT EXISTS idx_logs_source ON logs(source);
+		CREATE INDEX IF NOT EXISTS idx_logs_service ON logs(service);
+		CREATE INDEX IF NOT EXISTS idx_logs_host ON logs(host);
+
+		CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(message, content='logs', content_rowid='rowid');
+
+		CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+			INSERT INTO logs_fts(rowid, message) VALUES (new.rowid, new.message);
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES ('delete', old.rowid, old.message);
+		END;
+
+		CREATE TABLE IF NOT EXISTS log_patterns(
+			id TEXT PRIMARY KEY,
+			service TEXT NOT NULL,
+			template TEXT NOT NULL,
+			first_seen INTEGER NOT NULL,
+			last_seen INTEGER NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_log_patterns_service ON log_patterns(service);
+
+		CREATE TABLE IF NOT EXISTS issues(
+			id TEXT PRIMARY KEY,
+			service TEXT NOT NULL,
+			level TEXT NOT NULL,
+			title TEXT NOT NULL,
+			first_seen INTEGER NOT NULL,
+			last_seen INTEGER NOT NULL,
+			count INTEGER NOT NULL,
+			hosts TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'open',
+			regressed INTEGER NOT NULL DEFAULT 0,
+			resolved_at INTEGER,
+			regressed_at INTEGER,
+			last_log_id TEXT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_issues_last_seen ON issues(last_seen);
+		CREATE INDEX IF NOT EXISTS idx_issues_status ON issues(status);
+	`
+
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	// databases created before a column existed get it added
+	for _, column := range []string{"template_id", "fingerprint", "trace_id", "span_id", "parent_span_id"} {
+		if err := s.addColumn("logs", column, "TEXT"); err != nil {
+			return err
+		}
+		index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_logs_%[1]s ON logs(%[1]s, timestamp)", column)
+		if _, err := s.db.Exec(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) addColumn(table, column, definition string) error {
+	var count int
+	err := s.db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (s *SQLiteStorage) StoreLogs(logs []models.LogEntry) error {
+	_, err := s.InsertLogs(logs, false)
+	return err
+}
+
+// InsertLogs stores logs and returns the ones that were not stored before.
+// SQLite has no bulk path, so bulk is ignored.
+func (s *SQLiteStorage) InsertLogs(logs []models.LogEntry, bulk bool) ([]models.LogEntry, error) {
+	if len(logs) == 0 {
+		slog.Debug("log length is zero")
+		return nil, nil
+	}
+
+	records, err := toLogRecords(logs)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO logs(id, timestamp, level, message, source, service, host, tags, metadata, template_id, fingerprint, trace_id, span_id, parent_span_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var inserted []models.LogEntry
+	for i, r := range records {
+		result, err := stmt.Exec(r.ID, r.Timestamp.UnixNano(), r.Level, r.Message, r.Source, r.Service, r.Host, r.Tags, r.Metadata, r.TemplateID, r.Fingerprint, r.TraceID, r.SpanID, r.ParentSpanID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert log %s: %w", r.ID, err)
+		}
+		// ignored duplicates affect no rows
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n > 0 {
+			inserted = append(inserted, logs[i])
+		}
+	}
+
+	return inserted, tx.Commit()
+}
+
+func (s *SQLiteStorage) QueryLogs(query models.LogQuery) ([]models.LogEntry, error) {
+	return s.QueryLogsContext(context.Background(), query)
+}
+
+func (s *SQLiteStorage) QueryLogsContext(ctx context.Context, query models.LogQuery) ([]models.LogEntry, error) {
+	if err := sqliteValidateQuery(&query); err != nil {
+		return nil, err
+	}
+
+	args := &sqlArgs{positional: true}
+	ranked := rankedSearch(query)
+	baseQuery := "SELECT id, timestamp, level, message, source, service, host, tags, metadata, " + optionalColumns
+	if ranked {
+		// bm25 is lower for better matches, so it is negated into a rank
+		match := "FROM logs_fts WHERE logs_fts MATCH %s AND logs_fts.rowid = logs.rowid"
+		baseQuery += fmt.Sprintf(", (SELECT -bm25(logs_fts) "+match+") AS search_rank", args.add(ftsQuery(query.Search)))
+		baseQuery += fmt.Sprintf(", (SELECT snippet(logs_fts, 0, %s, %s, '...', 24) "+match+")",
+			args.add(highlightStart), args.add(highlightStop), args.add(ftsQuery(query.Search)))
+	}
+	baseQuery += " FROM logs"
+	where, err := sqliteWhereClause(query, args)
+	if err != nil {
+		return nil, err
+	}
+	direction, compare := keyset(query)
+	if query.Cursor != nil {
+		where = andWhere(where, fmt.Sprintf("(timestamp, id) %s (%s, %s)", compare, args.add(query.Cursor.Timestamp.UnixNano()), args.add(query.Cursor.ID)))
+	}
+	baseQuery += where
+	if query.Sort == models.SortRelevance {
+		baseQuery += " ORDER BY search_rank DESC, timestamp DESC, id DESC"
+	} else {
+		baseQuery += fmt.Sprintf(" ORDER BY timestamp %[1]s, id %[1]s", direction)
+	}
+
+	if query.Limit > 0 {
+		baseQuery += " LIMIT " + args.add(query.Limit)
+		if query.Offset > 0 {
+			baseQuery += " OFFSET " + args.add(query.Offset)
+		}
+	} else if query.Offset > 0 {
+		baseQuery += " LIMIT -1 OFFSET " + args.add(query.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, baseQuery, args.args...)
+	if err != nil {
+		slog.Debug("cannot execute query in sqlite")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []models.LogEntry
+	for rows.Next() {
+		var log models.LogEntry
+		var timestamp int64
+		var tagsJSON, metadataJSON sql.NullString
+
+		dest := []interface{}{
+			&log.ID, &timestamp, &log.Level, &log.Message,
+			&log.Source, &log.Service, &log.Host, &tagsJSON, &metadataJSON,
+		}
+		var optional optionalFields
+		dest = append(dest, optional.dest()...)
+		if ranked {
+			dest = append(dest, &log.Rank, &log.Highlight)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		log.Timestamp = time.Unix(0, timestamp).UTC()
+		if tagsJSON.Valid {
+			json.Unmarshal([]byte(tagsJSON.String), &log.Tags)
+		}
+		if metadataJSON.Valid {
+			log.Metadata = json.RawMessage(metadataJSON.String)
+		}
+		optional.set(&log)
+
+		logs = append(logs, log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if query.Cursor != nil && query.Cursor.Before {
+		slices.Reverse(logs)
+	}
+	return logs, nil
+}
+
+// CountLogs counts exactly up to limit. SQLite has no row estimates, so
+// larger totals are reported as at least limit.
+func (s *SQLiteStorage) CountLogs(query models.LogQuery, limit int) (models.Total, error) {
+	if err := sqliteValidateQuery(&query); err != nil {
+		return models.Total{}, err
+	}
+
+	args := &sqlArgs{positional: true}
+	where, err := sqliteWhereClause(query, args)
+	if err != nil {
+		return models.Total{}, err
+	}
+	countQuery := "SELECT COUNT(*) FROM (SELECT 1 FROM logs" + where
+	if limit > 0 {
+		countQuery += " LIMIT " + args.add(limit+1)
+	}
+	countQuery += ")"
+
+	var count int64
+	if err := s.db.QueryRow(countQuery, args.args...).Scan(&count); err != nil {
+		return models.Total{}, err
+	}
+	if limit > 0 && count > int64(limit) {
+		return models.Total{Value: int64(limit), Relation: models.TotalAtLeast}, nil
+	}
+	return models.Total{Value: count, Relation: models.TotalExact}, nil
+}
+
+// AggregateLogs counts the logs matching query.Query per time bucket and
+// group.
+func (s *SQLiteStorage) AggregateLogs(query models.AggregateQuery) ([]models.AggregateBucket, error) {
+	if err := validateAggregate(&query); err != nil {
+		return nil, err
+	}
+	if err := sqliteValidateQuery(&query.Query); err != nil {
+		return nil, err
+	}
+
+	args := &sqlArgs{positional: true}
+	statement, err := aggregateSQL(query, sqliteAggregate, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(statement, args.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAggregate(rows, query, sqliteAggregate)
+}
+
+var sqliteAggregate = aggregateDialect{
+	bucket: func(interval time.Duration) string {
+		if interval == 0 {
+			return "NULL"
+		}
+		return fmt.Sprintf("(timestamp / %[1]d) * %[1]d", interval.Nanoseconds())
+	},
+	tag: func(key string, args *sqlArgs) string {
+		return "json_extract(tags, " + args.add(sqliteJSONPath([]string{key}, false)) + ")"
+	},
+	where:       sqliteWhereClause,
+	notDistinct: "IS",
+	scanBucket: func(src interface{}) (*time.Time, error) {
+		if src == nil {
+			return nil, nil
+		}
+		nanos, ok := src.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected bucket %v", src)
+		}
+		t := time.Unix(0, nanos).UTC()
+		return &t, nil
+	},
+}
+
+// FacetLogs lists the most common values of a field among the logs matching
+// query.Query.
+func (s *SQLiteStorage) FacetLogs(query models.FacetQuery) (models.Facet, error) {
+	if err := validateFacet(&query); err != nil {
+		return models.Facet{}, err
+	}
+	if err := sqliteValidateQuery(&query.Query); err != nil {
+		return models.Facet{}, err
+	}
+
+	limit := facetLimit(query)
+	args := &sqlArgs{positional: true}
+	statement, err := facetSQL(query, limit, sqliteFacet, args)
+	if err != nil {
+		return models.Facet{}, err
+	}
+	rows, err := s.db.Query(statement, args.args...)
+	if err != nil {
+		return models.Facet{}, err
+	}
+	defer rows.Close()
+
+	return scanFacet(rows, query, limit)
+}
+
+var sqliteFacet = facetDialect{
+	tag: sqliteAggregate.tag,
+	tagKeys: func(where string) string {
+		return "SELECT j.key AS v FROM (SELECT tags FROM logs" + where + ") l, json_each(l.tags) j"
+	},
+	where: sqliteWhereClause,
+	prefix: func(prefix string, args *sqlArgs) string {
+		return `LOWER(v) LIKE LOWER(` + args.add(escapeLike(prefix)+"%") + `) ESCAPE '\'`
+	},
+}
+
+func (s *SQLiteStorage) LoadPatterns() ([]models.Pattern, error) {
+	rows, err := s.db.Query(`SELECT id, service, template, first_seen, last_seen FROM log_patterns`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patterns []models.Pattern
+	for rows.Next() {
+		p, err := scanSQLitePattern(rows)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+func (s *SQLiteStorage) SavePatterns(patterns []models.Pattern) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO log_patterns(id, service, template, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			template = excluded.template,
+			first_seen = MIN(first_seen, excluded.first_seen),
+			last_seen = MAX(last_seen, excluded.last_seen)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, p := range patterns {
+		if _, err := stmt.Exec(p.ID, p.Service, p.Template, p.FirstSeen.UnixNano(), p.LastSeen.UnixNano()); err != nil {
+			return fmt.Errorf("failed to save pattern %s: %w", p.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) TopPatterns(query models.PatternQuery) ([]models.PatternCount, error) {
+	if err := validatePatternQuery(&query); err != nil {
+		return nil, err
+	}
+	if err := sqliteValidateQuery(&query.Query); err != nil {
+		return nil, err
+	}
+
+	args := &sqlArgs{positional: true}
+	statement, err := patternSQL(query, sqliteWhereClause, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(statement, args.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	patterns := []models.PatternCount{}
+	for rows.Next() {
+		var count int64
+		p, err := scanSQLitePattern(rows, &count)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, models.PatternCount{Pattern: p, Count: count})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, addExamples(s, query, patterns)
+}
+
+// writes are serialized by the database lock, so rows need no locking
+var sqliteIssues = issueDialect{
+	positional: true,
+	time:       func(t time.Time) interface{} { return t.UnixNano() },
+	least:      "MIN",
+	greatest:   "MAX",
+}
+
+func (s *SQLiteStorage) RecordIssues(occurrences []models.Issue) ([]models.Issue, error) {
+	return recordIssues(s.db, sqliteIssues, occurrences)
+}
+
+func (s *SQLiteStorage) ListIssues(query models.IssueQuery) ([]models.Issue, error) {
+	return listIssues(s.db, sqliteIssues, query)
+}
+
+func (s *SQLiteStorage) GetIssue(id string) (models.Issue, error) {
+	return getIssue(s.db, sqliteIssues, id, false)
+}
+
+func (s *SQLiteStorage) SetIssueStatus(id string, status models.IssueStatus) (models.Issue, error) {
+	return setIssueStatus(s.db, sqliteIssues, id, status)
+}
+
+// scanSQLitePattern scans the columns of log_patterns followed by extra.
+func scanSQLitePattern(rows *sql.Rows, extra ...interface{}) (models.Pattern, error) {
+	var p models.Pattern
+	var firstSeen, lastSeen int64
+	dest := append([]interface{}{&p.ID, &p.Service, &p.Template, &firstSeen, &lastSeen}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return models.Pattern{}, err
+	}
+	p.FirstSeen = time.Unix(0, firstSeen).UTC()
+	p.LastSeen = time.Unix(0, lastSeen).UTC()
+	return p, nil
+}
+
+// DeleteLogs removes every log matching the filters of query. A query without
+// any filter is rejected rather than emptying the table.
+func (s *SQLiteStorage) DeleteLogs(query models.LogQuery) (int64, error) {
+	if err := sqliteValidateQuery(&query); err != nil {
+		return 0, err
+	}
+
+	args := &sqlArgs{positional: true}
+	where, err := sqliteWhereClause(query, args)
+	if err != nil {
+		return 0, err
+	}
+	if where == "" {
+		return 0, fmt.Errorf("%w: refusing to delete without a filter", ErrInvalidQuery)
+	}
+
+	result, err := s.db.Exec("DELETE FROM logs"+where, args.args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpired deletes one chunk of expired logs.
+func (s *SQLiteStorage) DeleteExpired(scope models.RetentionScope, limit int) (int64, error) {
+	args := &sqlArgs{positional: true}
+	where := retentionWhereClause(scope, scope.Before.UnixNano(), args)
+	query := "DELETE FROM logs WHERE rowid IN (SELECT rowid FROM logs" + where + " LIMIT " + args.add(limit) + ")"
+
+	result, err := s.db.Exec(query, args.args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLiteStorage) CountExpired(scope models.RetentionScope) (int64, error) {
+	args := &sqlArgs{positional: true}
+	query := "SELECT COUNT(*) FROM logs" + retentionWhereClause(scope, scope.Before.UnixNano(), args)
+
+	var count int64
+	err := s.db.QueryRow(query, args.args...).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStorage) OldestExpired(scope models.RetentionScope, since time.Time) (time.Time, bool, error) {
+	args := &sqlArgs{positional: true}
+	query := "SELECT MIN(timestamp) FROM logs" + retentionWhereClause(scope, scope.Before.UnixNano(), args) +
+		" AND timestamp >= " + args.add(since.UnixNano())
+
+	var oldest sql.NullInt64
+	if err := s.db.QueryRow(query, args.args...).Scan(&oldest); err != nil || !oldest.Valid {
+		return time.Time{}, false, err
+	}
+	return time.Unix(0, oldest.Int64).UTC(), true, nil
+}
+
+func sqliteWhereClause(query models.LogQuery, args *sqlArgs) (string, error) {
+	var conditions []string
+
+	if query.StartTime != nil {
+		conditions = append(conditions, "timestamp >= "+args.add(query.StartTime.UnixNano()))
+	}
+
+	if query.EndTime != nil {
+		conditions = append(conditions, "timestamp <= "+args.add(query.EndTime.UnixNano()))
+	}
+
+	if len(query.Level) > 0 {
+		levels := make([]string, len(query.Level))
+		for i, level := range query.Level {
+			levels[i] = string(level)
+		}
+		conditions = append(conditions, "level IN "+args.addList(levels))
+	}
+
+	if len(query.Source) > 0 {
+		conditions = append(conditions, "source IN "+args.addList(query.Source))
+	}
+
+	if len(query.Service) > 0 {
+		conditions = append(conditions, "service IN "+args.addList(query.Service))
+	}
+
+	if len(query.Host) > 0 {
+		conditions = append(conditions, "host IN "+args.addList(query.Host))
+	}
+
+	for key, value := range query.Tags {
+		conditions = append(conditions, fmt.Sprintf("json_extract(tags, %s) = %s", args.add(sqliteJSONPath([]string{key}, false)), args.add(value)))
+	}
+
+	for _, f := range query.Filters {
+		conditions = append(conditions, sqliteFilterCondition(f, args))
+	}
+
+	node, err := exprNode(query)
+	if err != nil {
+		return "", err
+	}
+	if node != nil {
+		conditions = append(conditions, exprCondition(node, sqliteExpr, args))
+	}
+
+	if query.Search != "" {
+		switch query.SearchMode {
+		case models.SearchFullText:
+			conditions = append(conditions, "rowid IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH "+args.add(ftsQuery(query.Search))+")")
+		case models.SearchRegex:
+			conditions = append(conditions, "message REGEXP "+args.add(query.Search))
+		default:
+			conditions = append(conditions, "LOWER(message) LIKE LOWER("+args.add("%"+escapeLike(query.Search)+"%")+`) ESCAPE '\'`)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+var sqliteExpr = exprDialect{
+	filter: sqliteFilterCondition,
+	search: func(text string, args *sqlArgs) string {
+		match := ftsQuery(text)
+		if match == "" {
+			return "0"
+		}
+		return "rowid IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH " + args.add(match) + ")"
+	},
+	contains: func(text string, args *sqlArgs) string {
+		return "LOWER(message) LIKE LOWER(" + args.add("%"+escapeLike(text)+"%") + `) ESCAPE '\'`
+	},
+}
+
+// sqliteValidateQuery extends ValidateQuery with checks specific to
+// SQLite, where regular expressions use Go syntax.
+func sqliteValidateQuery(query *models.LogQuery) error {
+	if err := ValidateQuery(query); err != nil {
+		return err
+	}
+	if query.SearchMode == models.SearchRegex {
+		if _, err := regexp.Compile(query.Search); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+	}
+	for _, f := range query.Filters {
+		if f.Op == models.FilterContains && containsArray(f.Value) {
+			return fmt.Errorf("%w: sqlite cannot match arrays in containment filters", ErrInvalidQuery)
+		}
+	}
+	if rankedSearch(*query) && ftsQuery(query.Search) == "" {
+		return fmt.Errorf("%w: search %q has no terms to match", ErrInvalidQuery, query.Search)
+	}
+	return nil
+}
+
+// sqliteFilterCondition renders a validated filter for SQLite.
+func sqliteFilterCondition(f models.FieldFilter, args *sqlArgs) string {
+	var condition string
+
+	if key, ok := f.TagKey(); ok {
+		path := args.add(sqliteJSONPath([]string{key}, false))
+		switch f.Op {
+		case models.FilterExists:
+			condition = fmt.Sprintf("json_type(tags, %s) IS NOT NULL", path)
+		case models.FilterEquals:
+			condition = fmt.Sprintf("json_extract(tags, %s) = %s", path, args.add(f.Value))
+		default:
+			condition = fmt.Sprintf(`json_extract(tags, %s) LIKE %s ESCAPE '\'`, path, args.add(likePattern(f)))
+		}
+	} else if path, ok := f.MetadataPath(); ok {
+		jsonPath := sqliteJSONPath(path, true)
+		switch f.Op {
+		case models.FilterExists:
+			condition = fmt.Sprintf("json_type(metadata, %s) IS NOT NULL", args.add(jsonPath))
+		case models.FilterEquals:
+			condition = sqliteJSONText("metadata", jsonPath, args) + " = " + args.add(f.Value)
+		default:
+			condition = sqliteJSONText("metadata", jsonPath, args) + " LIKE " + args.add(likePattern(f)) + ` ESCAPE '\'`
+		}
+	} else if f.Op == models.FilterContains {
+		condition = sqliteContains(f.Field, f.Value, args)
+	} else if f.Op == models.FilterEquals {
+		condition = fmt.Sprintf("%s = %s", f.Field, args.add(f.Value))
+	} else {
+		condition = fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, f.Field, args.add(likePattern(f)))
+	}
+
+	if f.Not {
+		return "NOT COALESCE(" + condition + ", 0)"
+	}
+	return condition
+}
+
+// sqliteJSONPath builds a JSON path from keys. With arrays set, numeric
+// segments index arrays, as they do in PostgreSQL paths.
+func sqliteJSONPath(segments []string, arrays bool) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); arrays && err == nil {
+			b.WriteString("[" + segment + "]")
+		} else {
+			b.WriteString(`."` + segment + `"`)
+		}
+	}
+	return b.String()
+}
+
+// sqliteJSONText renders the value at path as text the way PostgreSQL's #>>
+// operator does, so booleans read "true" rather than 1.
+func sqliteJSONText(column, path string, args *sqlArgs) string {
+	return fmt.Sprintf(`CASE json_type(%[1]s, %[2]s) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' WHEN 'null' THEN NULL ELSE CAST(json_extract(%[1]s, %[3]s) AS TEXT) END`,
+		column, args.add(path), args.add(path))
+}
+
+// sqliteContains emulates the JSONB @> operator for objects by matching
+// every leaf value of the object.
+func sqliteContains(column, value string, args *sqlArgs) string {
+	var object map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	decoder.Decode(&object)
+
+	var conditions []string
+	var walk func(path []string, v interface{})
+	walk = func(path []string, v interface{}) {
+		jsonPath := sqliteJSONPath(path, false)
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 {
+				conditions = append(conditions, fmt.Sprintf("json_type(%s, %s) = 'object'", column, args.add(jsonPath)))
+			}
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				walk(append(path[:len(path):len(path)], key), v[key])
+			}
+		case nil:
+			conditions = append(conditions, fmt.Sprintf("json_type(%s, %s) = 'null'", column, args.add(jsonPath)))
+		case bool:
+			conditions = append(conditions, fmt.Sprintf("json_type(%s, %s) = %s", column, args.add(jsonPath), args.add(strconv.FormatBool(v))))
+		case json.Number:
+			f, _ := v.Float64()
+			conditions = append(conditions, fmt.Sprintf("json_extract(%s, %s) = %s", column, args.add(jsonPath), args.add(f)))
+		case string:
+			conditions = append(conditions, fmt.Sprintf("json_extract(%s, %s) = %s", column, args.add(jsonPath), args.add(v)))
+		}
+	}
+	walk(nil, object)
+
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// containsArray reports whether a JSON document has an array anywhere.
+func containsArray(value string) bool {
+	var v interface{}
+	json.Unmarshal([]byte(value), &v)
+
+	var walk func(v interface{}) bool
+	walk = func(v interface{}) bool {
+		switch v := v.(type) {
+		case []interface{}:
+			return true
+		case map[string]interface{}:
+			for _, child := range v {
+				if walk(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(v)
+}
+
+// ftsQuery translates web search syntax, as accepted by PostgreSQL's
+// websearch_to_tsquery, into an FTS5 query. Every term is quoted so
+// punctuation is never read as FTS5 syntax. Negated terms need something to
+// be subtracted from, so leading ones are dropped.
+func ftsQuery(search string) string {
+	var b strings.Builder
+	or := false
+	for _, term := range searchTerms(search) {
+		quoted := `"` + strings.ReplaceAll(term.text, `"`, `""`) + `"`
+		switch {
+		case term.or:
+			or = b.Len() > 0
+		case term.negated:
+			if b.Len() > 0 && !or {
+				b.WriteString(" NOT " + quoted)
+			}
+			or = false
+		default:
+			if b.Len() > 0 && or {
+				b.WriteString(" OR ")
+			} else if b.Len() > 0 {
+				b.WriteString(" AND ")
+			}
+			b.WriteString(quoted)
+			or = false
+		}
+	}
+	return b.String()
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
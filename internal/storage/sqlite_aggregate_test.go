@@ -0,0 +1,175 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestSQLiteAggregateLogs(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+
+	buckets, err := s.AggregateLogs(models.AggregateQuery{GroupBy: []string{"service"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 1 {
+		t.Fatalf("got %d buckets, want 1", len(buckets))
+	}
+	counts := make(map[string]int64)
+	for _, group := range buckets[0].Groups {
+		counts[group.Group["service"]] = group.Count
+	}
+	if counts["api"] != 5 || counts["worker"] != 5 {
+		t.Errorf("got counts %v, want 5 per service", counts)
+	}
+}
+
+// describeBuckets renders each bucket as its offset from testStart followed
+// by its groups in order, as host=count or other=count.
+func describeBuckets(buckets []models.AggregateBucket) []string {
+	var out []string
+	for _, b := range buckets {
+		parts := []string{"-"}
+		if b.Start != nil {
+			parts[0] = b.Start.Sub(testStart).String()
+		}
+		for _, g := range b.Groups {
+			name := g.Group["host"]
+			if g.Other {
+				name = "other"
+			}
+			parts = append(parts, fmt.Sprintf("%s=%d", name, g.Count))
+		}
+		out = append(out, strings.Join(parts, " "))
+	}
+	return out
+}
+
+func TestSQLiteAggregateBuckets(t *testing.T) {
+	s := openTestSQLite(t)
+	logs := testLogs()
+	// h1 has 5 logs, h2 and h3 2 each and h4 1
+	for i, host := range []string{"h1", "h1", "h2", "h3", "h3", "h2", "h1", "h4", "h1", "h1"} {
+		logs[i].Host = host
+	}
+	if err := s.StoreLogs(logs); err != nil {
+		t.Fatal(err)
+	}
+	at := func(d time.Duration) *time.Time {
+		t := testStart.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		topN     int
+		start    time.Duration
+		end      time.Duration
+		want     []string
+	}{
+		{
+			name: "top N with other",
+			topN: 2,
+			end:  time.Minute,
+			// h2 and h3 tie, the lower value ranks first
+			want: []string{"- h1=5 h2=2 other=3"},
+		},
+		{
+			name: "top N covering every group",
+			topN: 10,
+			end:  time.Minute,
+			want: []string{"- h1=5 h2=2 h3=2 h4=1"},
+		},
+		{
+			name:     "top N per bucket",
+			interval: 5 * time.Second,
+			topN:     2,
+			end:      9 * time.Second,
+			want:     []string{"0s h1=2 h2=1 other=2", "5s h1=3 h2=1 other=1"},
+		},
+		{
+			name:     "buckets aligned on the epoch",
+			interval: 4 * time.Second,
+			topN:     1,
+			start:    time.Second,
+			end:      11 * time.Second,
+			want:     []string{"0s h1=1 other=2", "4s h1=1 other=3", "8s h1=2"},
+		},
+		{
+			name:     "gaps filled up to the end",
+			interval: 10 * time.Second,
+			topN:     1,
+			end:      30 * time.Second,
+			want:     []string{"0s h1=5 other=5", "10s", "20s", "30s"},
+		},
+		{
+			name:     "no logs",
+			interval: 10 * time.Second,
+			start:    time.Hour,
+			end:      time.Hour + 10*time.Second,
+			want:     []string{"1h0m0s", "1h0m10s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets, err := s.AggregateLogs(models.AggregateQuery{
+				Query:    models.LogQuery{StartTime: at(tt.start), EndTime: at(tt.end)},
+				GroupBy:  []string{"host"},
+				Interval: tt.interval,
+				TopN:     tt.topN,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describeBuckets(buckets); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteAggregateRejectsQueries(t *testing.T) {
+	s := openTestSQLite(t)
+	start := testStart
+	end := start.Add(maxBuckets * time.Second)
+	tooLate := end.Add(time.Second)
+
+	tests := []struct {
+		name  string
+		query models.AggregateQuery
+		err   string
+	}{
+		{"too many buckets", models.AggregateQuery{Query: models.LogQuery{StartTime: &start, EndTime: &tooLate}, Interval: time.Second}, "more than 10000 buckets"},
+		{"interval without a start", models.AggregateQuery{Interval: time.Minute}, "an interval needs a start_time"},
+		{"fractional interval", models.AggregateQuery{Query: models.LogQuery{StartTime: &start}, Interval: 1500 * time.Millisecond}, "interval must be whole seconds"},
+		{"unknown field", models.AggregateQuery{GroupBy: []string{"message"}}, `cannot group by "message"`},
+		{"field twice", models.AggregateQuery{GroupBy: []string{"host", "host"}}, `"host" is grouped by twice`},
+		{"negative top N", models.AggregateQuery{TopN: -1}, "top_n must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AggregateLogs(tt.query)
+			if !errors.Is(err, ErrInvalidQuery) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// exactly maxBuckets intervals is allowed
+	query := models.AggregateQuery{Query: models.LogQuery{StartTime: &start, EndTime: &end}, Interval: time.Second}
+	if _, err := s.AggregateLogs(query); err != nil {
+		t.Errorf("got %v for %d buckets", err, maxBuckets)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSQLiteLogContext(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+	log, err := GetLog(s, "log-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetLog(s, "missing"); !errors.Is(err, ErrLogNotFound) {
+		t.Errorf("got %v, want ErrLogNotFound", err)
+	}
+
+	tests := []struct {
+		name          string
+		scope         []string
+		before, after int
+		window        time.Duration
+		wantBefore    []string
+		wantAfter     []string
+	}{
+		{"no scope", nil, 2, 2, time.Hour, []string{"log-03", "log-04"}, []string{"log-06", "log-07"}},
+		{"service", []string{"service"}, 2, 1, time.Hour, []string{"log-01", "log-03"}, []string{"log-07"}},
+		{"window", nil, 5, 5, 2 * time.Second, []string{"log-03", "log-04"}, []string{"log-06", "log-07"}},
+		{"none", nil, 0, 0, time.Hour, []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, err := LogContext(s, log, tt.scope, tt.before, tt.after, tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := logIDs(before); !slices.Equal(got, tt.wantBefore) {
+				t.Errorf("got before %v, want %v", got, tt.wantBefore)
+			}
+			if got := logIDs(after); !slices.Equal(got, tt.wantAfter) {
+				t.Errorf("got after %v, want %v", got, tt.wantAfter)
+			}
+		})
+	}
+
+	if _, _, err := LogContext(s, log, []string{"level"}, 1, 1, time.Hour); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("got %v, want ErrInvalidQuery", err)
+	}
+}
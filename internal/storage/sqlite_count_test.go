@@ -0,0 +1,38 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestSQLiteCountLogs(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query models.LogQuery
+		limit int
+		want  models.Total
+	}{
+		{"exact", models.LogQuery{Service: []string{"api"}}, 0, models.Total{Value: 5, Relation: models.TotalExact}},
+		{"under limit", models.LogQuery{}, 10, models.Total{Value: 10, Relation: models.TotalExact}},
+		{"over limit", models.LogQuery{}, 3, models.Total{Value: 3, Relation: models.TotalAtLeast}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CountLogs(tt.query, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
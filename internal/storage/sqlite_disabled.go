@@ -0,0 +1,17 @@
+//go:build !sqlite_fts5
+
+package storage
+
+import "errors"
+
+// SQLiteStorage stands in for the SQLite backend in builds without it, so
+// both builds share the NewSQLiteStorage signature. No value is ever made.
+type SQLiteStorage struct {
+	LogStore
+}
+
+// NewSQLiteStorage reports that the binary was built without SQLite. Build
+// with -tags sqlite_fts5 (and cgo) to enable it.
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	return nil, errors.New("sqlite support not compiled in, rebuild with -tags sqlite_fts5")
+}
@@ -0,0 +1,76 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestSQLiteFacetLogs(t *testing.T) {
+	s := openTestSQLite(t)
+	logs := testLogs()
+	logs[0].Tags = map[string]string{"env": "prod", "region": "eu"}
+	logs[1].Tags = map[string]string{"env": "dev", "region": "us"}
+	logs[2].Tags = map[string]string{"env": "prod", "team": "payments"}
+	logs[3].Tags = nil
+	if err := s.StoreLogs(logs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     models.FacetQuery
+		want      []models.FacetValue
+		truncated bool
+	}{
+		{
+			name:  "a column ignores its own filter",
+			query: models.FacetQuery{Field: "service", Query: models.LogQuery{Service: []string{"api"}}},
+			want:  []models.FacetValue{{Value: "api", Count: 5}, {Value: "worker", Count: 5}},
+		},
+		{
+			name:  "the tag keys",
+			query: models.FacetQuery{Field: "tags"},
+			want: []models.FacetValue{
+				{Value: "env", Count: 9}, {Value: "region", Count: 2}, {Value: "team", Count: 1},
+			},
+		},
+		{
+			name:  "the tag keys of the logs matching a tag",
+			query: models.FacetQuery{Field: "tags", Query: models.LogQuery{Tags: map[string]string{"env": "prod"}}},
+			want: []models.FacetValue{
+				{Value: "env", Count: 8}, {Value: "region", Count: 1}, {Value: "team", Count: 1},
+			},
+		},
+		{
+			name:      "the tag keys with a limit",
+			query:     models.FacetQuery{Field: "tags", Limit: 1},
+			want:      []models.FacetValue{{Value: "env", Count: 9}},
+			truncated: true,
+		},
+		{
+			name:  "the tag keys with a prefix",
+			query: models.FacetQuery{Field: "tags", Prefix: "RE"},
+			want:  []models.FacetValue{{Value: "region", Count: 2}},
+		},
+		{
+			name:  "the values of a tag ignore the filter on that tag",
+			query: models.FacetQuery{Field: "tags.env", Query: models.LogQuery{Tags: map[string]string{"env": "dev"}}},
+			want:  []models.FacetValue{{Value: "prod", Count: 8}, {Value: "dev", Count: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facet, err := s.FacetLogs(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(facet.Values, tt.want) || facet.Truncated != tt.truncated {
+				t.Errorf("got %v truncated %v, want %v truncated %v", facet.Values, facet.Truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
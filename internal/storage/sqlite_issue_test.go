@@ -0,0 +1,55 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestSQLiteRecordIssues(t *testing.T) {
+	s := openTestSQLite(t)
+	occurrence := func(count int64, first, last time.Duration, lastLog string, hosts ...string) models.Issue {
+		return models.Issue{
+			ID: "issue-1", Service: "api", Level: models.ERROR, Title: "payment failed",
+			FirstSeen: testStart.Add(first), LastSeen: testStart.Add(last),
+			Count: count, Hosts: hosts, LastLogID: lastLog,
+		}
+	}
+
+	if _, err := s.RecordIssues([]models.Issue{occurrence(2, time.Minute, 2*time.Minute, "log-b", "web-2")}); err != nil {
+		t.Fatal(err)
+	}
+	// an older occurrence widens the range but keeps the latest log
+	if _, err := s.RecordIssues([]models.Issue{occurrence(3, 0, time.Minute, "log-a", "web-1", "web-2")}); err != nil {
+		t.Fatal(err)
+	}
+	issue, err := s.GetIssue("issue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Count != 5 || !issue.FirstSeen.Equal(testStart) || !issue.LastSeen.Equal(testStart.Add(2*time.Minute)) ||
+		issue.LastLogID != "log-b" || !slices.Equal(issue.Hosts, []string{"web-1", "web-2"}) || issue.Status != models.IssueOpen {
+		t.Errorf("got %+v", issue)
+	}
+
+	if _, err := s.SetIssueStatus("issue-1", models.IssueResolved); err != nil {
+		t.Fatal(err)
+	}
+	// errors logged before the issue was resolved are not regressions
+	regressed, err := s.RecordIssues([]models.Issue{occurrence(1, 0, time.Minute, "log-c")})
+	if err != nil || len(regressed) != 0 {
+		t.Fatalf("got %v, %v", regressed, err)
+	}
+	later := time.Since(testStart) + time.Hour
+	regressed, err = s.RecordIssues([]models.Issue{occurrence(1, later, later, "log-d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regressed) != 1 || !regressed[0].Regressed || regressed[0].Status != models.IssueOpen || regressed[0].LastLogID != "log-d" {
+		t.Errorf("got %+v, want the issue regressed", regressed)
+	}
+}
@@ -0,0 +1,308 @@
+//go:build sqlite_fts5
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+var testStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func openTestSQLite(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testLogs returns ten logs one second apart, alternating between the api
+// and worker services.
+func testLogs() []models.LogEntry {
+	logs := make([]models.LogEntry, 10)
+	for i := range logs {
+		service, level := "api", models.INFO
+		if i%2 == 1 {
+			service, level = "worker", models.ERROR
+		}
+		logs[i] = models.LogEntry{
+			ID:        fmt.Sprintf("log-%02d", i),
+			Timestamp: testStart.Add(time.Duration(i) * time.Second),
+			Level:     level,
+			Message:   fmt.Sprintf("payment %d failed for order-%d", i, 100+i),
+			Source:    "app.log",
+			Service:   service,
+			Host:      "host-1",
+			Tags:      map[string]string{"env": "prod"},
+			Metadata:  []byte(fmt.Sprintf(`{"user":{"id":"u%d"},"attempt":%d}`, i%3, i)),
+		}
+	}
+	return logs
+}
+
+func logIDs(logs []models.LogEntry) []string {
+	ids := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	return ids
+}
+
+func TestSQLiteStoreAndQuery(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+	// storing the same logs again is a no-op
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+
+	end := testStart.Add(3 * time.Second)
+	tests := []struct {
+		name  string
+		query models.LogQuery
+		want  []string
+	}{
+		{
+			name:  "all newest first",
+			query: models.LogQuery{Limit: 3},
+			want:  []string{"log-09", "log-08", "log-07"},
+		},
+		{
+			name:  "ascending",
+			query: models.LogQuery{Limit: 2, Order: "asc"},
+			want:  []string{"log-00", "log-01"},
+		},
+		{
+			name:  "time range",
+			query: models.LogQuery{StartTime: &testStart, EndTime: &end},
+			want:  []string{"log-03", "log-02", "log-01", "log-00"},
+		},
+		{
+			name:  "service and level",
+			query: models.LogQuery{Service: []string{"worker"}, Level: []models.LogLevel{models.ERROR}, Limit: 2},
+			want:  []string{"log-09", "log-07"},
+		},
+		{
+			name:  "substring search",
+			query: models.LogQuery{Search: "order-104", SearchMode: models.SearchSubstring},
+			want:  []string{"log-04"},
+		},
+		{
+			name:  "fulltext search",
+			query: models.LogQuery{Search: "payment", SearchMode: models.SearchFullText, Limit: 1},
+			want:  []string{"log-09"},
+		},
+		{
+			name:  "metadata filter",
+			query: models.LogQuery{Filters: []models.FieldFilter{{Field: "metadata.user.id", Op: models.FilterEquals, Value: "u1"}}},
+			want:  []string{"log-07", "log-04", "log-01"},
+		},
+		{
+			name:  "negated filter",
+			query: models.LogQuery{Filters: []models.FieldFilter{{Field: "service", Op: models.FilterEquals, Value: "api", Not: true}}, Limit: 1},
+			want:  []string{"log-09"},
+		},
+		{
+			name:  "tag",
+			query: models.LogQuery{Tags: map[string]string{"env": "dev"}},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := s.QueryLogs(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := logIDs(logs); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	s := openTestSQLite(t)
+	want := testLogs()[3]
+	if err := s.StoreLogs([]models.LogEntry{want}); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := s.QueryLogs(models.LogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+	got := logs[0]
+	if !got.Timestamp.Equal(want.Timestamp) || got.Message != want.Message || got.Service != want.Service ||
+		got.Level != want.Level || got.Tags["env"] != "prod" || string(got.Metadata) != string(want.Metadata) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSQLiteCursorPagination(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+
+	var pages [][]string
+	query := models.LogQuery{Limit: 4}
+	for {
+		logs, err := s.QueryLogs(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) == 0 {
+			break
+		}
+		pages = append(pages, logIDs(logs))
+		last := logs[len(logs)-1]
+		query.Cursor = &models.Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+
+	want := [][]string{
+		{"log-09", "log-08", "log-07", "log-06"},
+		{"log-05", "log-04", "log-03", "log-02"},
+		{"log-01", "log-00"},
+	}
+	if !slices.EqualFunc(pages, want, slices.Equal) {
+		t.Fatalf("got pages %v, want %v", pages, want)
+	}
+
+	// paging back from the second page returns the first, in display order
+	logs, err := s.QueryLogs(models.LogQuery{
+		Limit:  4,
+		Cursor: &models.Cursor{Timestamp: testStart.Add(5 * time.Second), ID: "log-05", Before: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logIDs(logs); !slices.Equal(got, want[0]) {
+		t.Errorf("got previous page %v, want %v", got, want[0])
+	}
+}
+
+func TestSQLiteDeleteLogs(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.DeleteLogs(models.LogQuery{}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("deleting without a filter: got %v, want ErrInvalidQuery", err)
+	}
+
+	deleted, err := s.DeleteLogs(models.LogQuery{Service: []string{"worker"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 5 {
+		t.Errorf("deleted %d logs, want 5", deleted)
+	}
+
+	// the full-text index follows deletes
+	logs, err := s.QueryLogs(models.LogQuery{Search: "payment", SearchMode: models.SearchFullText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 5 {
+		t.Errorf("got %d search results after delete, want 5", len(logs))
+	}
+}
+
+func TestSQLiteDeleteExpired(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.StoreLogs(testLogs()); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := models.RetentionScope{Before: testStart.Add(4 * time.Second)}
+	expired, err := s.CountExpired(scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 4 {
+		t.Fatalf("got %d expired logs, want 4", expired)
+	}
+
+	var deleted int64
+	for {
+		n, err := s.DeleteExpired(scope, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		deleted += n
+	}
+	if deleted != 4 {
+		t.Errorf("deleted %d logs, want 4", deleted)
+	}
+}
+
+func TestSQLiteOldestExpired(t *testing.T) {
+	s := openTestSQLite(t)
+	logs := testLogs()
+	logs[1].Level = "error"
+	if err := s.StoreLogs(logs); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := models.RetentionScope{
+		Policy: models.RetentionPolicy{Level: models.ERROR},
+		Before: testStart.Add(6 * time.Second),
+	}
+	tests := []struct {
+		since time.Time
+		want  time.Time
+		ok    bool
+	}{
+		// levels match whatever their case, as when deleting
+		{testStart, testStart.Add(time.Second), true},
+		{testStart.Add(2 * time.Second), testStart.Add(3 * time.Second), true},
+		{testStart.Add(4 * time.Second), testStart.Add(5 * time.Second), true},
+		{testStart.Add(6 * time.Second), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok, err := s.OldestExpired(scope, tt.since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("since %s: got %s %v, want %s %v", tt.since, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSQLiteInsertLogsSkipsDuplicates(t *testing.T) {
+	s := openTestSQLite(t)
+	logs := testLogs()
+	if _, err := s.InsertLogs(logs[:4], false); err != nil {
+		t.Fatal(err)
+	}
+
+	// a redelivered batch overlapping the stored logs
+	inserted, err := s.InsertLogs(logs[2:6], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := logIDs(inserted), []string{"log-04", "log-05"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+// Package storagetest provides an in-memory log store for tests of the
+// packages layered over storage.
+package storagetest
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/storage"
+)
+
+// MemoryStore answers queries over a slice of logs with a storage.Matcher,
+// ordered by timestamp, then ID, as the SQL stores page through them. It
+// counts the queries it ran. Methods it does not implement panic.
+type MemoryStore struct {
+	storage.LogStore
+
+	mu      sync.Mutex
+	logs    []models.LogEntry
+	queries int
+}
+
+func NewMemoryStore(logs ...models.LogEntry) *MemoryStore {
+	return &MemoryStore{logs: slices.Clone(logs)}
+}
+
+// Logs returns the logs stored, in the order they were stored.
+func (m *MemoryStore) Logs() []models.LogEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.logs)
+}
+
+// Queries returns the number of queries run so far.
+func (m *MemoryStore) Queries() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.queries
+}
+
+// StoreLogs adds logs, skipping those with the ID and timestamp of a log
+// already stored, as PostgreSQL does.
+func (m *MemoryStore) StoreLogs(logs []models.LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, log := range logs {
+		if !slices.ContainsFunc(m.logs, func(l models.LogEntry) bool {
+			return l.ID == log.ID && l.Timestamp.Equal(log.Timestamp)
+		}) {
+			m.logs = append(m.logs, log)
+		}
+	}
+	return nil
+}
+
+// Delete removes the logs remove returns true for and returns how many it
+// removed.
+func (m *MemoryStore) Delete(remove func(models.LogEntry) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.logs)
+	m.logs = slices.DeleteFunc(m.logs, remove)
+	return int64(n - len(m.logs))
+}
+
+func (m *MemoryStore) QueryLogs(query models.LogQuery) ([]models.LogEntry, error) {
+	return m.QueryLogsContext(context.Background(), query)
+}
+
+func (m *MemoryStore) QueryLogsContext(ctx context.Context, query models.LogQuery) ([]models.LogEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	matcher, err := storage.NewMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.queries++
+	var logs []models.LogEntry
+	for _, log := range m.logs {
+		if matcher.Match(log) {
+			logs = append(logs, log)
+		}
+	}
+	m.mu.Unlock()
+
+	desc := storage.Descending(query)
+	slices.SortFunc(logs, func(a, b models.LogEntry) int {
+		c := a.Timestamp.Compare(b.Timestamp)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+	logs = logs[min(query.Offset, len(logs)):]
+	if query.Limit > 0 && len(logs) > query.Limit {
+		logs = logs[:query.Limit]
+	}
+	if query.Cursor != nil && query.Cursor.Before {
+		slices.Reverse(logs)
+	}
+	return logs, nil
+}
+
+// CountLogs counts the logs matching query, stopping at limit when it is
+// positive.
+func (m *MemoryStore) CountLogs(query models.LogQuery, limit int) (models.Total, error) {
+	query.Cursor, query.Limit, query.Offset = nil, 0, 0
+	logs, err := m.QueryLogs(query)
+	if err != nil {
+		return models.Total{}, err
+	}
+	if limit > 0 && len(logs) > limit {
+		return models.Total{Value: int64(limit), Relation: models.TotalAtLeast}, nil
+	}
+	return models.Total{Value: int64(len(logs)), Relation: models.TotalExact}, nil
+}
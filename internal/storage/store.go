@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/krishnaGauss/SoCode/internal/config"
+	"github.com/krishnaGauss/SoCode/internal/models"
+	"github.com/krishnaGauss/SoCode/internal/querylang"
+)
+
+// ErrInvalidQuery wraps errors caused by the query itself rather than by the
+// backend, so callers can report them as bad requests.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// LogStore persists logs and answers queries over them.
+type LogStore interface {
+	StoreLogs(logs []models.LogEntry) error
+	QueryLogs(query models.LogQuery) ([]models.LogEntry, error)
+	// CountLogs counts the logs matching query, exactly up to limit and
+	// approximately beyond it. A limit of zero or less always counts exactly.
+	CountLogs(query models.LogQuery, limit int) (models.Total, error)
+	AggregateLogs(query models.AggregateQuery) ([]models.AggregateBucket, error)
+	FacetLogs(query models.FacetQuery) (models.Facet, error)
+	DeleteLogs(query models.LogQuery) (int64, error)
+	Close() error
+}
+
+// BulkLogStore is implemented by stores with a faster write path for large
+// batches.
+type BulkLogStore interface {
+	LogStore
+	StoreLogsCopy(logs []models.LogEntry) error
+}
+
+// ContextLogStore is implemented by stores whose queries stop once ctx is
+// done, such as when the client of a long export goes away.
+type ContextLogStore interface {
+	QueryLogsContext(ctx context.Context, query models.LogQuery) ([]models.LogEntry, error)
+}
+
+// QueryLogsContext runs query on store, passing ctx along when the store
+// takes one.
+func QueryLogsContext(ctx context.Context, store LogStore, query models.LogQuery) ([]models.LogEntry, error) {
+	if s, ok := store.(ContextLogStore); ok {
+		return s.QueryLogsContext(ctx, query)
+	}
+	return store.QueryLogs(query)
+}
+
+// InsertReporter is implemented by stores that can tell which logs of a batch
+// they inserted, leaving out the ones already stored, such as logs the queue
+// delivered again after a failed acknowledgement.
+type InsertReporter interface {
+	// InsertLogs stores logs like StoreLogs, or like StoreLogsCopy when bulk
+	// is set, and returns the ones that were not stored before.
+	InsertLogs(logs []models.LogEntry, bulk bool) ([]models.LogEntry, error)
+}
+
+// insertedLogs returns the logs whose IDs are in ids.
+func insertedLogs(logs []models.LogEntry, ids []string) []models.LogEntry {
+	remaining := make(map[string]int, len(ids))
+	for _, id := range ids {
+		remaining[id]++
+	}
+	inserted := make([]models.LogEntry, 0, len(ids))
+	for _, log := range logs {
+		if remaining[log.ID] > 0 {
+			remaining[log.ID]--
+			inserted = append(inserted, log)
+		}
+	}
+	return inserted
+}
+
+// groupColumns maps the fields logs can be grouped by to their column, the
+// same as the query language counts by.
+var groupColumns = func() map[string]string {
+	columns := make(map[string]string, len(querylang.GroupColumns))
+	for _, column := range querylang.GroupColumns {
+		columns[column] = column
+	}
+	return columns
+}()
+
+// Highlighted terms in search snippets are wrapped in these markers.
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// ValidateQuery rejects unknown search modes, sort orders and invalid
+// filters or expressions. It parses query.Expr into query.ExprNode unless
+// that is already set.
+func ValidateQuery(query *models.LogQuery) error {
+	for _, f := range query.Filters {
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+	}
+
+	node, err := exprNode(*query)
+	if err != nil {
+		return err
+	}
+	query.ExprNode = node
+
+	switch query.SearchMode {
+	case "", models.SearchFullText, models.SearchSubstring, models.SearchRegex:
+	default:
+		return fmt.Errorf("%w: unknown search mode %q", ErrInvalidQuery, query.SearchMode)
+	}
+
+	switch query.Sort {
+	case "", models.SortTimestamp:
+	case models.SortRelevance:
+		if !rankedSearch(*query) {
+			return fmt.Errorf("%w: sorting by relevance needs a full-text search", ErrInvalidQuery)
+		}
+	default:
+		return fmt.Errorf("%w: unknown sort order %q", ErrInvalidQuery, query.Sort)
+	}
+
+	switch query.Order {
+	case "", models.OrderDesc, models.OrderAsc:
+	default:
+		return fmt.Errorf("%w: order must be asc or desc, got %q", ErrInvalidQuery, query.Order)
+	}
+
+	if query.Cursor != nil && query.Sort == models.SortRelevance {
+		return fmt.Errorf("%w: cursors only page through results sorted by time", ErrInvalidQuery)
+	}
+	if query.Cursor != nil && query.Offset > 0 {
+		return fmt.Errorf("%w: cursor and offset cannot be combined", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// exprNode returns the parsed expression of query, parsing query.Expr when
+// ValidateQuery has not already.
+func exprNode(query models.LogQuery) (querylang.Node, error) {
+	if query.ExprNode != nil || query.Expr == "" {
+		return query.ExprNode, nil
+	}
+	node, err := querylang.ParseFilter(query.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+	}
+	return node, nil
+}
+
+// keyset returns the direction a page of query is read in and the comparison
+// on (timestamp, id) selecting the rows past its cursor. Pages before the
+// cursor are read in reverse and flipped back afterwards.
+func keyset(query models.LogQuery) (direction, compare string) {
+	desc := query.Order != models.OrderAsc
+	if query.Cursor != nil && query.Cursor.Before {
+		desc = !desc
+	}
+	if desc {
+		return "DESC", "<"
+	}
+	return "ASC", ">"
+}
+
+// Descending reports whether a page of query is read newest first.
+func Descending(query models.LogQuery) bool {
+	direction, _ := keyset(query)
+	return direction == "DESC"
+}
+
+// Unwrapper is implemented by stores layered over another store.
+type Unwrapper interface {
+	Unwrap() LogStore
+}
+
+// Unwrap returns the innermost store under layers such as the archive.
+func Unwrap(store LogStore) LogStore {
+	for {
+		u, ok := store.(Unwrapper)
+		if !ok {
+			return store
+		}
+		store = u.Unwrap()
+	}
+}
+
+// andWhere adds condition to a clause rendered by whereClause.
+func andWhere(where, condition string) string {
+	if where == "" {
+		return " WHERE " + condition
+	}
+	return where + " AND " + condition
+}
+
+// rankedSearch reports whether query is a full-text search, whose results
+// carry a rank and a highlighted snippet.
+func rankedSearch(query models.LogQuery) bool {
+	return query.Search != "" && query.SearchMode == models.SearchFullText
+}
+
+type searchTerm struct {
+	text    string
+	negated bool
+	or      bool
+}
+
+// searchTerms splits search into words and "quoted phrases", each possibly
+// prefixed with "-", and the keyword or.
+func searchTerms(search string) []searchTerm {
+	var terms []searchTerm
+	for s := strings.TrimSpace(search); s != ""; s = strings.TrimLeftFunc(s, unicode.IsSpace) {
+		var term searchTerm
+		if s[0] == '-' {
+			term.negated = true
+			s = s[1:]
+		}
+
+		quoted := strings.HasPrefix(s, `"`)
+		if quoted {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				term.text, s = s[1:], ""
+			} else {
+				term.text, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(s, unicode.IsSpace)
+			if end < 0 {
+				end = len(s)
+			}
+			term.text, s = s[:end], s[end:]
+		}
+
+		if strings.TrimSpace(term.text) == "" {
+			continue
+		}
+		term.or = !quoted && !term.negated && strings.EqualFold(term.text, "or")
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// escapeLike escapes the LIKE wildcards in s, using backslash as the escape
+// character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// NewLogStore opens the backend selected by cfg.Driver.
+func NewLogStore(cfg *config.DatabaseConfig) (LogStore, error) {
+	var (
+		store LogStore
+		err   error
+	)
+
+	switch cfg.Driver {
+	case "", "postgres":
+		store, err = NewPostgresStorage(cfg)
+	case "sqlite":
+		store, err = NewSQLiteStorage(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/krishnaGauss/SoCode/internal/models"
+)
+
+func TestValidateQueryParsesExpr(t *testing.T) {
+	query := models.LogQuery{Expr: "level:ERROR service:api"}
+	if err := ValidateQuery(&query); err != nil {
+		t.Fatal(err)
+	}
+	if query.ExprNode == nil {
+		t.Fatal("ExprNode is not set")
+	}
+
+	query = models.LogQuery{Expr: "sevice:api"}
+	err := ValidateQuery(&query)
+	if !errors.Is(err, ErrInvalidQuery) || !strings.HasSuffix(err.Error(), `unknown field "sevice" at position 1`) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestWhereClauseRejectsInvalidExpr(t *testing.T) {
+	// DeleteLogs must not widen to every log when an expression is dropped
+	for _, expr := range []string{"sevice:api", "(level:ERROR", "a | count"} {
+		where, err := whereClause(models.LogQuery{Service: []string{"api"}, Expr: expr}, &sqlArgs{})
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%q: got %q, %v, want ErrInvalidQuery", expr, where, err)
+		}
+	}
+
+	where, err := whereClause(models.LogQuery{Expr: "level:ERROR"}, &sqlArgs{})
+	if err != nil || !strings.Contains(where, "level") {
+		t.Errorf("got %q, %v", where, err)
+	}
+}
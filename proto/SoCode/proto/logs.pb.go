@@ -23,16 +23,29 @@ const (
 )
 
 type LogRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Level         string                 `protobuf:"bytes,3,opt,name=level,proto3" json:"level,omitempty"`
-	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
-	Source        string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
-	Service       string                 `protobuf:"bytes,6,opt,name=service,proto3" json:"service,omitempty"`
-	Host          string                 `protobuf:"bytes,7,opt,name=host,proto3" json:"host,omitempty"`
-	Tags          map[string]string      `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	Metadata      string                 `protobuf:"bytes,9,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Level     string                 `protobuf:"bytes,3,opt,name=level,proto3" json:"level,omitempty"`
+	Message   string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Source    string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	Service   string                 `protobuf:"bytes,6,opt,name=service,proto3" json:"service,omitempty"`
+	Host      string                 `protobuf:"bytes,7,opt,name=host,proto3" json:"host,omitempty"`
+	Tags      map[string]string      `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Metadata  string                 `protobuf:"bytes,9,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// set on full-text search results only
+	Rank      float64 `protobuf:"fixed64,10,opt,name=rank,proto3" json:"rank,omitempty"`
+	Highlight string  `protobuf:"bytes,11,opt,name=highlight,proto3" json:"highlight,omitempty"`
+	// set on query results read from the archive
+	Cold bool `protobuf:"varint,12,opt,name=cold,proto3" json:"cold,omitempty"`
+	// set on query results, the pattern the message was assigned
+	TemplateId string `protobuf:"bytes,13,opt,name=template_id,json=templateId,proto3" json:"template_id,omitempty"`
+	// set on query results of ERROR and FATAL logs, the issue they belong to
+	Fingerprint string `protobuf:"bytes,14,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// W3C trace context, hex encoded
+	TraceId       string `protobuf:"bytes,15,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	SpanId        string `protobuf:"bytes,16,opt,name=span_id,json=spanId,proto3" json:"span_id,omitempty"`
+	ParentSpanId  string `protobuf:"bytes,17,opt,name=parent_span_id,json=parentSpanId,proto3" json:"parent_span_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -130,6 +143,62 @@ func (x *LogRequest) GetMetadata() string {
 	return ""
 }
 
+func (x *LogRequest) GetRank() float64 {
+	if x != nil {
+		return x.Rank
+	}
+	return 0
+}
+
+func (x *LogRequest) GetHighlight() string {
+	if x != nil {
+		return x.Highlight
+	}
+	return ""
+}
+
+func (x *LogRequest) GetCold() bool {
+	if x != nil {
+		return x.Cold
+	}
+	return false
+}
+
+func (x *LogRequest) GetTemplateId() string {
+	if x != nil {
+		return x.TemplateId
+	}
+	return ""
+}
+
+func (x *LogRequest) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *LogRequest) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *LogRequest) GetSpanId() string {
+	if x != nil {
+		return x.SpanId
+	}
+	return ""
+}
+
+func (x *LogRequest) GetParentSpanId() string {
+	if x != nil {
+		return x.ParentSpanId
+	}
+	return ""
+}
+
 type LogResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -183,17 +252,34 @@ func (x *LogResponse) GetMessage() string {
 }
 
 type QueryRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	StartTime     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
-	EndTime       *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
-	Levels        []string               `protobuf:"bytes,3,rep,name=levels,proto3" json:"levels,omitempty"`
-	Sources       []string               `protobuf:"bytes,4,rep,name=sources,proto3" json:"sources,omitempty"`
-	Services      []string               `protobuf:"bytes,5,rep,name=services,proto3" json:"services,omitempty"`
-	Hosts         []string               `protobuf:"bytes,6,rep,name=hosts,proto3" json:"hosts,omitempty"`
-	Search        string                 `protobuf:"bytes,7,opt,name=search,proto3" json:"search,omitempty"`
-	Tags          map[string]string      `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	Limit         int32                  `protobuf:"varint,9,opt,name=limit,proto3" json:"limit,omitempty"`
-	Offset        int32                  `protobuf:"varint,10,opt,name=offset,proto3" json:"offset,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	StartTime *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Levels    []string               `protobuf:"bytes,3,rep,name=levels,proto3" json:"levels,omitempty"`
+	Sources   []string               `protobuf:"bytes,4,rep,name=sources,proto3" json:"sources,omitempty"`
+	Services  []string               `protobuf:"bytes,5,rep,name=services,proto3" json:"services,omitempty"`
+	Hosts     []string               `protobuf:"bytes,6,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	Search    string                 `protobuf:"bytes,7,opt,name=search,proto3" json:"search,omitempty"`
+	Tags      map[string]string      `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Limit     int32                  `protobuf:"varint,9,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset    int32                  `protobuf:"varint,10,opt,name=offset,proto3" json:"offset,omitempty"`
+	// substring (default), fulltext or regex
+	SearchMode string `protobuf:"bytes,11,opt,name=search_mode,json=searchMode,proto3" json:"search_mode,omitempty"`
+	// timestamp (default) or relevance
+	Sort string `protobuf:"bytes,12,opt,name=sort,proto3" json:"sort,omitempty"`
+	// filters such as "service!=api", "host=web-*", "tags.env" or
+	// "metadata.user.id=42"
+	Filters []string `protobuf:"bytes,13,rep,name=filters,proto3" json:"filters,omitempty"`
+	// next_cursor or prev_cursor of a previous response
+	Cursor string `protobuf:"bytes,14,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// desc (default) or asc
+	Order string `protobuf:"bytes,15,opt,name=order,proto3" json:"order,omitempty"`
+	// auto (default) counts exactly up to a limit and estimates beyond it,
+	// exact always counts exactly, none skips counting
+	TotalMode string `protobuf:"bytes,16,opt,name=total_mode,json=totalMode,proto3" json:"total_mode,omitempty"`
+	// query language expression such as
+	// `level:ERROR AND service:(auth OR payments) | count by host`
+	Q             string `protobuf:"bytes,17,opt,name=q,proto3" json:"q,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -298,10 +384,67 @@ func (x *QueryRequest) GetOffset() int32 {
 	return 0
 }
 
+func (x *QueryRequest) GetSearchMode() string {
+	if x != nil {
+		return x.SearchMode
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetFilters() []string {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetTotalMode() string {
+	if x != nil {
+		return x.TotalMode
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetQ() string {
+	if x != nil {
+		return x.Q
+	}
+	return ""
+}
+
 type QueryResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Logs          []*LogRequest          `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
-	Total         int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Logs  []*LogRequest          `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
+	// every log matching the query, see total_relation
+	Total int64 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	// empty when there is no page in that direction
+	NextCursor string `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	PrevCursor string `protobuf:"bytes,4,opt,name=prev_cursor,json=prevCursor,proto3" json:"prev_cursor,omitempty"`
+	// eq (exact), gte (at least total) or estimate
+	TotalRelation string `protobuf:"bytes,5,opt,name=total_relation,json=totalRelation,proto3" json:"total_relation,omitempty"`
+	// set instead of logs when q ends in a count pipeline
+	Counts        []*AggregateCount `protobuf:"bytes,6,rep,name=counts,proto3" json:"counts,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -343,7 +486,586 @@ func (x *QueryResponse) GetLogs() []*LogRequest {
 	return nil
 }
 
-func (x *QueryResponse) GetTotal() int32 {
+func (x *QueryResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *QueryResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+func (x *QueryResponse) GetPrevCursor() string {
+	if x != nil {
+		return x.PrevCursor
+	}
+	return ""
+}
+
+func (x *QueryResponse) GetTotalRelation() string {
+	if x != nil {
+		return x.TotalRelation
+	}
+	return ""
+}
+
+func (x *QueryResponse) GetCounts() []*AggregateCount {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+type AggregateRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// filters of the logs to count; limit, offset, cursor and sorting are
+	// ignored
+	Query *QueryRequest `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// columns (level, source, service, host) or "tags.<key>"
+	GroupBy []string `protobuf:"bytes,2,rep,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	// bucket size such as "5m" or "1d", empty for a single bucket
+	Interval string `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"`
+	// keep the top_n groups over the whole range, folding the rest into an
+	// "other" group
+	TopN          int32 `protobuf:"varint,4,opt,name=top_n,json=topN,proto3" json:"top_n,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateRequest) Reset() {
+	*x = AggregateRequest{}
+	mi := &file_logs_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateRequest) ProtoMessage() {}
+
+func (x *AggregateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateRequest.ProtoReflect.Descriptor instead.
+func (*AggregateRequest) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AggregateRequest) GetQuery() *QueryRequest {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *AggregateRequest) GetGroupBy() []string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return nil
+}
+
+func (x *AggregateRequest) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *AggregateRequest) GetTopN() int32 {
+	if x != nil {
+		return x.TopN
+	}
+	return 0
+}
+
+type AggregateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Buckets       []*AggregateBucket     `protobuf:"bytes,1,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateResponse) Reset() {
+	*x = AggregateResponse{}
+	mi := &file_logs_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateResponse) ProtoMessage() {}
+
+func (x *AggregateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateResponse.ProtoReflect.Descriptor instead.
+func (*AggregateResponse) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AggregateResponse) GetBuckets() []*AggregateBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type AggregateBucket struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// unset when the request has no interval
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	Groups        []*AggregateCount      `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateBucket) Reset() {
+	*x = AggregateBucket{}
+	mi := &file_logs_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateBucket) ProtoMessage() {}
+
+func (x *AggregateBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateBucket.ProtoReflect.Descriptor instead.
+func (*AggregateBucket) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AggregateBucket) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *AggregateBucket) GetGroups() []*AggregateCount {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type AggregateCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         map[string]string      `protobuf:"bytes,1,rep,name=group,proto3" json:"group,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Other         bool                   `protobuf:"varint,2,opt,name=other,proto3" json:"other,omitempty"`
+	Count         int64                  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateCount) Reset() {
+	*x = AggregateCount{}
+	mi := &file_logs_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateCount) ProtoMessage() {}
+
+func (x *AggregateCount) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateCount.ProtoReflect.Descriptor instead.
+func (*AggregateCount) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AggregateCount) GetGroup() map[string]string {
+	if x != nil {
+		return x.Group
+	}
+	return nil
+}
+
+func (x *AggregateCount) GetOther() bool {
+	if x != nil {
+		return x.Other
+	}
+	return false
+}
+
+func (x *AggregateCount) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type FacetRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// filters of the logs to count; a facet ignores the filters on its own
+	// field
+	Query *QueryRequest `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// level, service, host, source, tags (the tag keys) or "tags.<key>";
+	// all but tag values when empty
+	Fields []string `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"`
+	// only values starting with prefix, ignoring case
+	Prefix string `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// values per facet
+	Limit         int32 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FacetRequest) Reset() {
+	*x = FacetRequest{}
+	mi := &file_logs_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FacetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FacetRequest) ProtoMessage() {}
+
+func (x *FacetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FacetRequest.ProtoReflect.Descriptor instead.
+func (*FacetRequest) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FacetRequest) GetQuery() *QueryRequest {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *FacetRequest) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *FacetRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *FacetRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type FacetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Facets        []*Facet               `protobuf:"bytes,1,rep,name=facets,proto3" json:"facets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FacetResponse) Reset() {
+	*x = FacetResponse{}
+	mi := &file_logs_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FacetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FacetResponse) ProtoMessage() {}
+
+func (x *FacetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FacetResponse.ProtoReflect.Descriptor instead.
+func (*FacetResponse) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FacetResponse) GetFacets() []*Facet {
+	if x != nil {
+		return x.Facets
+	}
+	return nil
+}
+
+type Facet struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Field  string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Values []*FacetValue          `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
+	// more values exist than the limit allowed
+	Truncated     bool `protobuf:"varint,3,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Facet) Reset() {
+	*x = Facet{}
+	mi := &file_logs_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Facet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Facet) ProtoMessage() {}
+
+func (x *Facet) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Facet.ProtoReflect.Descriptor instead.
+func (*Facet) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Facet) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *Facet) GetValues() []*FacetValue {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *Facet) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+type FacetValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FacetValue) Reset() {
+	*x = FacetValue{}
+	mi := &file_logs_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FacetValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FacetValue) ProtoMessage() {}
+
+func (x *FacetValue) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FacetValue.ProtoReflect.Descriptor instead.
+func (*FacetValue) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *FacetValue) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *FacetValue) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type QueueStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueStatsRequest) Reset() {
+	*x = QueueStatsRequest{}
+	mi := &file_logs_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueStatsRequest) ProtoMessage() {}
+
+func (x *QueueStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueStatsRequest.ProtoReflect.Descriptor instead.
+func (*QueueStatsRequest) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{12}
+}
+
+type QueueStatsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// queued logs per lane (critical, default, debug)
+	Lanes         map[string]int64 `protobuf:"bytes,1,rep,name=lanes,proto3" json:"lanes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Total         int64            `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueStatsResponse) Reset() {
+	*x = QueueStatsResponse{}
+	mi := &file_logs_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueStatsResponse) ProtoMessage() {}
+
+func (x *QueueStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_logs_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueStatsResponse.ProtoReflect.Descriptor instead.
+func (*QueueStatsResponse) Descriptor() ([]byte, []int) {
+	return file_logs_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *QueueStatsResponse) GetLanes() map[string]int64 {
+	if x != nil {
+		return x.Lanes
+	}
+	return nil
+}
+
+func (x *QueueStatsResponse) GetTotal() int64 {
 	if x != nil {
 		return x.Total
 	}
@@ -355,7 +1077,7 @@ var File_logs_proto protoreflect.FileDescriptor
 const file_logs_proto_rawDesc = "" +
 	"\n" +
 	"\n" +
-	"logs.proto\x12\x04logs\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd1\x02\n" +
+	"logs.proto\x12\x04logs\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb4\x04\n" +
 	"\n" +
 	"LogRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x128\n" +
@@ -366,13 +1088,23 @@ const file_logs_proto_rawDesc = "" +
 	"\aservice\x18\x06 \x01(\tR\aservice\x12\x12\n" +
 	"\x04host\x18\a \x01(\tR\x04host\x12.\n" +
 	"\x04tags\x18\b \x03(\v2\x1a.logs.LogRequest.TagsEntryR\x04tags\x12\x1a\n" +
-	"\bmetadata\x18\t \x01(\tR\bmetadata\x1a7\n" +
+	"\bmetadata\x18\t \x01(\tR\bmetadata\x12\x12\n" +
+	"\x04rank\x18\n" +
+	" \x01(\x01R\x04rank\x12\x1c\n" +
+	"\thighlight\x18\v \x01(\tR\thighlight\x12\x12\n" +
+	"\x04cold\x18\f \x01(\bR\x04cold\x12\x1f\n" +
+	"\vtemplate_id\x18\r \x01(\tR\n" +
+	"templateId\x12 \n" +
+	"\vfingerprint\x18\x0e \x01(\tR\vfingerprint\x12\x19\n" +
+	"\btrace_id\x18\x0f \x01(\tR\atraceId\x12\x17\n" +
+	"\aspan_id\x18\x10 \x01(\tR\x06spanId\x12$\n" +
+	"\x0eparent_span_id\x18\x11 \x01(\tR\fparentSpanId\x1a7\n" +
 	"\tTagsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"A\n" +
 	"\vLogResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"\x95\x03\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xbf\x04\n" +
 	"\fQueryRequest\x129\n" +
 	"\n" +
 	"start_time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
@@ -385,18 +1117,77 @@ const file_logs_proto_rawDesc = "" +
 	"\x04tags\x18\b \x03(\v2\x1c.logs.QueryRequest.TagsEntryR\x04tags\x12\x14\n" +
 	"\x05limit\x18\t \x01(\x05R\x05limit\x12\x16\n" +
 	"\x06offset\x18\n" +
-	" \x01(\x05R\x06offset\x1a7\n" +
+	" \x01(\x05R\x06offset\x12\x1f\n" +
+	"\vsearch_mode\x18\v \x01(\tR\n" +
+	"searchMode\x12\x12\n" +
+	"\x04sort\x18\f \x01(\tR\x04sort\x12\x18\n" +
+	"\afilters\x18\r \x03(\tR\afilters\x12\x16\n" +
+	"\x06cursor\x18\x0e \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05order\x18\x0f \x01(\tR\x05order\x12\x1d\n" +
+	"\n" +
+	"total_mode\x18\x10 \x01(\tR\ttotalMode\x12\f\n" +
+	"\x01q\x18\x11 \x01(\tR\x01q\x1a7\n" +
 	"\tTagsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"K\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe2\x01\n" +
 	"\rQueryResponse\x12$\n" +
 	"\x04logs\x18\x01 \x03(\v2\x10.logs.LogRequestR\x04logs\x12\x14\n" +
-	"\x05total\x18\x02 \x01(\x05R\x05total2\xaa\x01\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\x12\x1f\n" +
+	"\vprev_cursor\x18\x04 \x01(\tR\n" +
+	"prevCursor\x12%\n" +
+	"\x0etotal_relation\x18\x05 \x01(\tR\rtotalRelation\x12,\n" +
+	"\x06counts\x18\x06 \x03(\v2\x14.logs.AggregateCountR\x06counts\"\x88\x01\n" +
+	"\x10AggregateRequest\x12(\n" +
+	"\x05query\x18\x01 \x01(\v2\x12.logs.QueryRequestR\x05query\x12\x19\n" +
+	"\bgroup_by\x18\x02 \x03(\tR\agroupBy\x12\x1a\n" +
+	"\binterval\x18\x03 \x01(\tR\binterval\x12\x13\n" +
+	"\x05top_n\x18\x04 \x01(\x05R\x04topN\"D\n" +
+	"\x11AggregateResponse\x12/\n" +
+	"\abuckets\x18\x01 \x03(\v2\x15.logs.AggregateBucketR\abuckets\"q\n" +
+	"\x0fAggregateBucket\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x06groups\x18\x02 \x03(\v2\x14.logs.AggregateCountR\x06groups\"\xad\x01\n" +
+	"\x0eAggregateCount\x125\n" +
+	"\x05group\x18\x01 \x03(\v2\x1f.logs.AggregateCount.GroupEntryR\x05group\x12\x14\n" +
+	"\x05other\x18\x02 \x01(\bR\x05other\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x03R\x05count\x1a8\n" +
+	"\n" +
+	"GroupEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"~\n" +
+	"\fFacetRequest\x12(\n" +
+	"\x05query\x18\x01 \x01(\v2\x12.logs.QueryRequestR\x05query\x12\x16\n" +
+	"\x06fields\x18\x02 \x03(\tR\x06fields\x12\x16\n" +
+	"\x06prefix\x18\x03 \x01(\tR\x06prefix\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\"4\n" +
+	"\rFacetResponse\x12#\n" +
+	"\x06facets\x18\x01 \x03(\v2\v.logs.FacetR\x06facets\"e\n" +
+	"\x05Facet\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12(\n" +
+	"\x06values\x18\x02 \x03(\v2\x10.logs.FacetValueR\x06values\x12\x1c\n" +
+	"\ttruncated\x18\x03 \x01(\bR\ttruncated\"8\n" +
+	"\n" +
+	"FacetValue\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\"\x13\n" +
+	"\x11QueueStatsRequest\"\x9f\x01\n" +
+	"\x12QueueStatsResponse\x129\n" +
+	"\x05lanes\x18\x01 \x03(\v2#.logs.QueueStatsResponse.LanesEntryR\x05lanes\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\x1a8\n" +
+	"\n" +
+	"LanesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x012\xe6\x02\n" +
 	"\n" +
 	"LogService\x12.\n" +
 	"\aSendLog\x12\x10.logs.LogRequest\x1a\x11.logs.LogResponse\x126\n" +
 	"\rSendLogStream\x12\x10.logs.LogRequest\x1a\x11.logs.LogResponse(\x01\x124\n" +
-	"\tQueryLogs\x12\x12.logs.QueryRequest\x1a\x13.logs.QueryResponseB\x0eZ\fSoCode/protob\x06proto3"
+	"\tQueryLogs\x12\x12.logs.QueryRequest\x1a\x13.logs.QueryResponse\x12@\n" +
+	"\rAggregateLogs\x12\x16.logs.AggregateRequest\x1a\x17.logs.AggregateResponse\x124\n" +
+	"\tGetFacets\x12\x12.logs.FacetRequest\x1a\x13.logs.FacetResponse\x12B\n" +
+	"\rGetQueueStats\x12\x17.logs.QueueStatsRequest\x1a\x18.logs.QueueStatsResponseB\x0eZ\fSoCode/protob\x06proto3"
 
 var (
 	file_logs_proto_rawDescOnce sync.Once
@@ -410,34 +1201,62 @@ func file_logs_proto_rawDescGZIP() []byte {
 	return file_logs_proto_rawDescData
 }
 
-var file_logs_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_logs_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_logs_proto_goTypes = []any{
 	(*LogRequest)(nil),            // 0: logs.LogRequest
 	(*LogResponse)(nil),           // 1: logs.LogResponse
 	(*QueryRequest)(nil),          // 2: logs.QueryRequest
 	(*QueryResponse)(nil),         // 3: logs.QueryResponse
-	nil,                           // 4: logs.LogRequest.TagsEntry
-	nil,                           // 5: logs.QueryRequest.TagsEntry
-	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(*AggregateRequest)(nil),      // 4: logs.AggregateRequest
+	(*AggregateResponse)(nil),     // 5: logs.AggregateResponse
+	(*AggregateBucket)(nil),       // 6: logs.AggregateBucket
+	(*AggregateCount)(nil),        // 7: logs.AggregateCount
+	(*FacetRequest)(nil),          // 8: logs.FacetRequest
+	(*FacetResponse)(nil),         // 9: logs.FacetResponse
+	(*Facet)(nil),                 // 10: logs.Facet
+	(*FacetValue)(nil),            // 11: logs.FacetValue
+	(*QueueStatsRequest)(nil),     // 12: logs.QueueStatsRequest
+	(*QueueStatsResponse)(nil),    // 13: logs.QueueStatsResponse
+	nil,                           // 14: logs.LogRequest.TagsEntry
+	nil,                           // 15: logs.QueryRequest.TagsEntry
+	nil,                           // 16: logs.AggregateCount.GroupEntry
+	nil,                           // 17: logs.QueueStatsResponse.LanesEntry
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
 }
 var file_logs_proto_depIdxs = []int32{
-	6, // 0: logs.LogRequest.timestamp:type_name -> google.protobuf.Timestamp
-	4, // 1: logs.LogRequest.tags:type_name -> logs.LogRequest.TagsEntry
-	6, // 2: logs.QueryRequest.start_time:type_name -> google.protobuf.Timestamp
-	6, // 3: logs.QueryRequest.end_time:type_name -> google.protobuf.Timestamp
-	5, // 4: logs.QueryRequest.tags:type_name -> logs.QueryRequest.TagsEntry
-	0, // 5: logs.QueryResponse.logs:type_name -> logs.LogRequest
-	0, // 6: logs.LogService.SendLog:input_type -> logs.LogRequest
-	0, // 7: logs.LogService.SendLogStream:input_type -> logs.LogRequest
-	2, // 8: logs.LogService.QueryLogs:input_type -> logs.QueryRequest
-	1, // 9: logs.LogService.SendLog:output_type -> logs.LogResponse
-	1, // 10: logs.LogService.SendLogStream:output_type -> logs.LogResponse
-	3, // 11: logs.LogService.QueryLogs:output_type -> logs.QueryResponse
-	9, // [9:12] is the sub-list for method output_type
-	6, // [6:9] is the sub-list for method input_type
-	6, // [6:6] is the sub-list for extension type_name
-	6, // [6:6] is the sub-list for extension extendee
-	0, // [0:6] is the sub-list for field type_name
+	18, // 0: logs.LogRequest.timestamp:type_name -> google.protobuf.Timestamp
+	14, // 1: logs.LogRequest.tags:type_name -> logs.LogRequest.TagsEntry
+	18, // 2: logs.QueryRequest.start_time:type_name -> google.protobuf.Timestamp
+	18, // 3: logs.QueryRequest.end_time:type_name -> google.protobuf.Timestamp
+	15, // 4: logs.QueryRequest.tags:type_name -> logs.QueryRequest.TagsEntry
+	0,  // 5: logs.QueryResponse.logs:type_name -> logs.LogRequest
+	7,  // 6: logs.QueryResponse.counts:type_name -> logs.AggregateCount
+	2,  // 7: logs.AggregateRequest.query:type_name -> logs.QueryRequest
+	6,  // 8: logs.AggregateResponse.buckets:type_name -> logs.AggregateBucket
+	18, // 9: logs.AggregateBucket.start:type_name -> google.protobuf.Timestamp
+	7,  // 10: logs.AggregateBucket.groups:type_name -> logs.AggregateCount
+	16, // 11: logs.AggregateCount.group:type_name -> logs.AggregateCount.GroupEntry
+	2,  // 12: logs.FacetRequest.query:type_name -> logs.QueryRequest
+	10, // 13: logs.FacetResponse.facets:type_name -> logs.Facet
+	11, // 14: logs.Facet.values:type_name -> logs.FacetValue
+	17, // 15: logs.QueueStatsResponse.lanes:type_name -> logs.QueueStatsResponse.LanesEntry
+	0,  // 16: logs.LogService.SendLog:input_type -> logs.LogRequest
+	0,  // 17: logs.LogService.SendLogStream:input_type -> logs.LogRequest
+	2,  // 18: logs.LogService.QueryLogs:input_type -> logs.QueryRequest
+	4,  // 19: logs.LogService.AggregateLogs:input_type -> logs.AggregateRequest
+	8,  // 20: logs.LogService.GetFacets:input_type -> logs.FacetRequest
+	12, // 21: logs.LogService.GetQueueStats:input_type -> logs.QueueStatsRequest
+	1,  // 22: logs.LogService.SendLog:output_type -> logs.LogResponse
+	1,  // 23: logs.LogService.SendLogStream:output_type -> logs.LogResponse
+	3,  // 24: logs.LogService.QueryLogs:output_type -> logs.QueryResponse
+	5,  // 25: logs.LogService.AggregateLogs:output_type -> logs.AggregateResponse
+	9,  // 26: logs.LogService.GetFacets:output_type -> logs.FacetResponse
+	13, // 27: logs.LogService.GetQueueStats:output_type -> logs.QueueStatsResponse
+	22, // [22:28] is the sub-list for method output_type
+	16, // [16:22] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_logs_proto_init() }
@@ -451,7 +1270,7 @@ func file_logs_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_logs_proto_rawDesc), len(file_logs_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
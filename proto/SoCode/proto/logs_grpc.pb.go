@@ -22,6 +22,9 @@ const (
 	LogService_SendLog_FullMethodName       = "/logs.LogService/SendLog"
 	LogService_SendLogStream_FullMethodName = "/logs.LogService/SendLogStream"
 	LogService_QueryLogs_FullMethodName     = "/logs.LogService/QueryLogs"
+	LogService_AggregateLogs_FullMethodName = "/logs.LogService/AggregateLogs"
+	LogService_GetFacets_FullMethodName     = "/logs.LogService/GetFacets"
+	LogService_GetQueueStats_FullMethodName = "/logs.LogService/GetQueueStats"
 )
 
 // LogServiceClient is the client API for LogService service.
@@ -31,6 +34,9 @@ type LogServiceClient interface {
 	SendLog(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (*LogResponse, error)
 	SendLogStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LogRequest, LogResponse], error)
 	QueryLogs(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	AggregateLogs(ctx context.Context, in *AggregateRequest, opts ...grpc.CallOption) (*AggregateResponse, error)
+	GetFacets(ctx context.Context, in *FacetRequest, opts ...grpc.CallOption) (*FacetResponse, error)
+	GetQueueStats(ctx context.Context, in *QueueStatsRequest, opts ...grpc.CallOption) (*QueueStatsResponse, error)
 }
 
 type logServiceClient struct {
@@ -74,6 +80,36 @@ func (c *logServiceClient) QueryLogs(ctx context.Context, in *QueryRequest, opts
 	return out, nil
 }
 
+func (c *logServiceClient) AggregateLogs(ctx context.Context, in *AggregateRequest, opts ...grpc.CallOption) (*AggregateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AggregateResponse)
+	err := c.cc.Invoke(ctx, LogService_AggregateLogs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *logServiceClient) GetFacets(ctx context.Context, in *FacetRequest, opts ...grpc.CallOption) (*FacetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FacetResponse)
+	err := c.cc.Invoke(ctx, LogService_GetFacets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *logServiceClient) GetQueueStats(ctx context.Context, in *QueueStatsRequest, opts ...grpc.CallOption) (*QueueStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueueStatsResponse)
+	err := c.cc.Invoke(ctx, LogService_GetQueueStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LogServiceServer is the server API for LogService service.
 // All implementations must embed UnimplementedLogServiceServer
 // for forward compatibility.
@@ -81,6 +117,9 @@ type LogServiceServer interface {
 	SendLog(context.Context, *LogRequest) (*LogResponse, error)
 	SendLogStream(grpc.ClientStreamingServer[LogRequest, LogResponse]) error
 	QueryLogs(context.Context, *QueryRequest) (*QueryResponse, error)
+	AggregateLogs(context.Context, *AggregateRequest) (*AggregateResponse, error)
+	GetFacets(context.Context, *FacetRequest) (*FacetResponse, error)
+	GetQueueStats(context.Context, *QueueStatsRequest) (*QueueStatsResponse, error)
 	mustEmbedUnimplementedLogServiceServer()
 }
 
@@ -100,6 +139,15 @@ func (UnimplementedLogServiceServer) SendLogStream(grpc.ClientStreamingServer[Lo
 func (UnimplementedLogServiceServer) QueryLogs(context.Context, *QueryRequest) (*QueryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method QueryLogs not implemented")
 }
+func (UnimplementedLogServiceServer) AggregateLogs(context.Context, *AggregateRequest) (*AggregateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AggregateLogs not implemented")
+}
+func (UnimplementedLogServiceServer) GetFacets(context.Context, *FacetRequest) (*FacetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFacets not implemented")
+}
+func (UnimplementedLogServiceServer) GetQueueStats(context.Context, *QueueStatsRequest) (*QueueStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQueueStats not implemented")
+}
 func (UnimplementedLogServiceServer) mustEmbedUnimplementedLogServiceServer() {}
 func (UnimplementedLogServiceServer) testEmbeddedByValue()                    {}
 
@@ -164,6 +212,60 @@ func _LogService_QueryLogs_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LogService_AggregateLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AggregateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogServiceServer).AggregateLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogService_AggregateLogs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogServiceServer).AggregateLogs(ctx, req.(*AggregateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LogService_GetFacets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FacetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogServiceServer).GetFacets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogService_GetFacets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogServiceServer).GetFacets(ctx, req.(*FacetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LogService_GetQueueStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueueStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogServiceServer).GetQueueStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogService_GetQueueStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogServiceServer).GetQueueStats(ctx, req.(*QueueStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // LogService_ServiceDesc is the grpc.ServiceDesc for LogService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -179,6 +281,18 @@ var LogService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "QueryLogs",
 			Handler:    _LogService_QueryLogs_Handler,
 		},
+		{
+			MethodName: "AggregateLogs",
+			Handler:    _LogService_AggregateLogs_Handler,
+		},
+		{
+			MethodName: "GetFacets",
+			Handler:    _LogService_GetFacets_Handler,
+		},
+		{
+			MethodName: "GetQueueStats",
+			Handler:    _LogService_GetQueueStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{